@@ -3,53 +3,173 @@ package assistants
 import (
 	"context"
 	"errors"
+	"fmt"
 
 	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
 )
 
+// defaultMaxToolSteps limits the tool calling loop when MaxToolSteps is not set.
+const defaultMaxToolSteps = 10
+
+var errNoStructuredResponses = errors.New("selected model does not support structured responses")
+
 type Assistant struct {
 	Name         string
 	SystemPrompt string
 	Llm          llm.LLMProvider
+	// Model, Temperature and MaxTokens are passed with every request, zero values
+	// leave the provider defaults in place.
+	Model       string
+	Temperature *float64
+	MaxTokens   int
+	// OnStream makes Chat and StructuredChat stream responses when set. It is called at
+	// the start of every response and the returned handler receives its deltas.
+	OnStream func() llm.StreamHandler
+	// Tools the model can call before giving its final answer.
+	Tools *ToolRegistry
+	// MaxToolSteps limits the number of responses with tool calls in a single chat.
+	MaxToolSteps int
 }
 
-func (a Assistant) Chat(ctx context.Context, msg string) (string, error) {
-	s := llm.ChatMessage{Role: "developer", Content: a.SystemPrompt}
-	m := llm.ChatMessage{Role: "user", Content: msg}
-
-	ans, err := a.Llm.GetCompletion(
-		ctx,
-		llm.ChatRequest{BaseChatRequest: llm.BaseChatRequest{
-			Messages: []llm.ChatMessage{s, m}},
-		},
-	)
-	if err != nil {
-		return "", err
+// Chat sends the message with the system prompt. The response carries the token usage
+// and latency of the call next to the answer.
+func (a Assistant) Chat(ctx context.Context, msg string) (llm.ChatResponse, error) {
+	if a.OnStream != nil {
+		return a.StreamChat(ctx, msg, a.OnStream())
 	}
 
-	return ans.Response, nil
+	return a.converse(ctx, msg, func(req llm.BaseChatRequest) (llm.ChatResponse, error) {
+		return a.Llm.GetCompletion(ctx, llm.ChatRequest{BaseChatRequest: req})
+	})
 }
 
-func (a Assistant) StructuredChat(ctx context.Context, msg string, name string, schema map[string]any) (string, error) {
+func (a Assistant) StructuredChat(ctx context.Context, msg string, name string, schema map[string]any) (llm.ChatResponse, error) {
+	if a.OnStream != nil {
+		return a.StreamStructuredChat(ctx, msg, name, schema, a.OnStream())
+	}
+
 	l, ok := a.Llm.(llm.StructuredLLMProvider)
 	if !ok {
-		return "", errors.New("selected model does not support structured responses")
+		return llm.ChatResponse{}, errNoStructuredResponses
 	}
 
-	s := llm.ChatMessage{Role: "developer", Content: a.SystemPrompt}
-	m := llm.ChatMessage{Role: "user", Content: msg}
+	return a.converse(ctx, msg, func(req llm.BaseChatRequest) (llm.ChatResponse, error) {
+		return l.GetResponse(ctx, llm.StructuredChatRequest{
+			BaseChatRequest: req,
+			Schema:          schema,
+			Name:            name,
+		})
+	})
+}
+
+// StreamChat is Chat delivering the response deltas to onDelta as they are generated.
+// Providers without streaming support deliver the whole response at once.
+func (a Assistant) StreamChat(ctx context.Context, msg string, onDelta llm.StreamHandler) (llm.ChatResponse, error) {
+	l := llm.Streaming(a.Llm)
+
+	return a.converse(ctx, msg, func(req llm.BaseChatRequest) (llm.ChatResponse, error) {
+		return l.StreamCompletion(ctx, llm.ChatRequest{BaseChatRequest: req}, onDelta)
+	})
+}
+
+// StreamStructuredChat is StructuredChat delivering the response deltas to onDelta.
+func (a Assistant) StreamStructuredChat(
+	ctx context.Context,
+	msg string,
+	name string,
+	schema map[string]any,
+	onDelta llm.StreamHandler,
+) (llm.ChatResponse, error) {
+	sl, ok := a.Llm.(llm.StructuredLLMProvider)
+	if !ok {
+		return llm.ChatResponse{}, errNoStructuredResponses
+	}
+	l := llm.StreamingStructured(sl)
+
+	return a.converse(ctx, msg, func(req llm.BaseChatRequest) (llm.ChatResponse, error) {
+		return l.StreamResponse(ctx, llm.StructuredChatRequest{
+			BaseChatRequest: req,
+			Schema:          schema,
+			Name:            name,
+		}, onDelta)
+	})
+}
 
-	ans, err := l.GetResponse(
-		ctx,
-		llm.StructuredChatRequest{BaseChatRequest: llm.BaseChatRequest{
-			Messages: []llm.ChatMessage{s, m}},
-			Schema: schema,
-			Name:   name,
-		},
+// converse sends the message with the system prompt and runs the requested tools until
+// the model gives its final answer. The returned response sums up the usage of all steps.
+// On error it holds only the usage of the steps done so far, see UsageSoFar.
+func (a Assistant) converse(
+	ctx context.Context,
+	msg string,
+	send func(llm.BaseChatRequest) (llm.ChatResponse, error),
+) (llm.ChatResponse, error) {
+	req := a.newBaseChatRequest(
+		llm.ChatMessage{Role: "developer", Content: a.SystemPrompt},
+		llm.ChatMessage{Role: "user", Content: msg},
 	)
-	if err != nil {
-		return "", err
+	req.Tools = a.Tools.definitions()
+
+	maxSteps := a.MaxToolSteps
+	if maxSteps <= 0 {
+		maxSteps = defaultMaxToolSteps
+	}
+
+	var total llm.ChatResponse
+	for step := 0; ; step++ {
+		ans, err := send(req)
+		if err != nil {
+			return withoutAnswer(total), err
+		}
+
+		total.Response = ans.Response
+		total.ToolCalls = ans.ToolCalls
+		total.Model = ans.Model
+		total.TokenUsage = total.TokenUsage.Add(ans.TokenUsage)
+		total.TimeTaken += ans.TimeTaken
+		total.Calls += max(ans.Calls, 1)
+
+		if len(ans.ToolCalls) == 0 {
+			return total, nil
+		}
+		if step+1 >= maxSteps {
+			return withoutAnswer(total), fmt.Errorf("no final answer after %d tool calling steps", maxSteps)
+		}
+
+		req.Messages = append(req.Messages, llm.ChatMessage{
+			Role:      "assistant",
+			Content:   ans.Response,
+			ToolCalls: ans.ToolCalls,
+		})
+		for _, call := range ans.ToolCalls {
+			req.Messages = append(req.Messages, llm.ToolResultMessage(call.ID, a.Tools.call(ctx, call)))
+		}
+	}
+}
+
+// withoutAnswer drops the answer of an unfinished chat, the steps it took are still paid for.
+func withoutAnswer(total llm.ChatResponse) llm.ChatResponse {
+	return llm.ChatResponse{
+		Model:      total.Model,
+		TokenUsage: total.TokenUsage,
+		TimeTaken:  total.TimeTaken,
+		Calls:      total.Calls,
 	}
+}
 
-	return ans.Response, nil
+// UsageSoFar returns the usage of the response of a failed chat, it is zero when the chat
+// failed before any call finished.
+func UsageSoFar(ans llm.ChatResponse) llm.Usage {
+	if ans.Calls == 0 {
+		return llm.Usage{}
+	}
+	return ans.Usage()
+}
+
+func (a Assistant) newBaseChatRequest(messages ...llm.ChatMessage) llm.BaseChatRequest {
+	return llm.BaseChatRequest{
+		Messages:    messages,
+		MaxTokens:   a.MaxTokens,
+		Model:       a.Model,
+		Temperature: a.Temperature,
+	}
 }
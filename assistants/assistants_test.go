@@ -25,8 +25,8 @@ func TestAssistantChat(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if resp != "Mocked response" {
-		t.Errorf("expected 'Mocked response', got '%s'", resp)
+	if resp.Response != "Mocked response" {
+		t.Errorf("expected 'Mocked response', got '%s'", resp.Response)
 	}
 }
 
@@ -48,7 +48,40 @@ func TestAssistantStructuredChat(t *testing.T) {
 		t.Fatalf("unexpected error: %v", err)
 	}
 
-	if resp != "Mocked structured response" {
-		t.Errorf("expected 'Mocked structured response', got '%s'", resp)
+	if resp.Response != "Mocked structured response" {
+		t.Errorf("expected 'Mocked structured response', got '%s'", resp.Response)
+	}
+}
+
+func TestAssistantChatStreams(t *testing.T) {
+	mockLLM := &llm.MockLLMProvider{
+		GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			return llm.ChatResponse{Response: "Mocked response"}, nil
+		},
+	}
+
+	var streamed []string
+	streams := 0
+	assistant := Assistant{
+		Name: "TestAssistant",
+		Llm:  mockLLM,
+		OnStream: func() llm.StreamHandler {
+			streams++
+			return func(delta string) {
+				streamed = append(streamed, delta)
+			}
+		},
+	}
+
+	resp, err := assistant.Chat(context.Background(), "Hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.Response != "Mocked response" {
+		t.Errorf("expected 'Mocked response', got '%s'", resp.Response)
+	}
+	if streams != 1 || len(streamed) != 1 || streamed[0] != "Mocked response" {
+		t.Errorf("expected the response streamed once, got %d streams with deltas %v", streams, streamed)
 	}
 }
@@ -4,8 +4,16 @@ import (
 	"context"
 	"fmt"
 	"sync"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
+var tracer = otel.Tracer("github.com/aszmajdzinski/llm-feedback-loop-executor/assistants")
+
 type ExpertsTeamInterface interface {
 	Ask(ctx context.Context, prompt string) []ExpertAnswer
 }
@@ -15,14 +23,21 @@ type ExpertsTeam struct {
 }
 
 type ExpertAnswer struct {
+	Expert string
 	Answer string
+	Usage  llm.Usage
 	Error  error
 }
 
+// Ask asks all experts concurrently. Every expert gets a span, so the fan-out is visible
+// in traces.
 func (et *ExpertsTeam) Ask(ctx context.Context, prompt string) []ExpertAnswer {
+	ctx, span := tracer.Start(ctx, "ExpertsTeam.Ask", trace.WithAttributes(attribute.Int("experts", len(et.Experts))))
+	defer span.End()
+
 	type result struct {
 		index  int
-		answer string
+		answer llm.ChatResponse
 		error  error
 	}
 
@@ -34,11 +49,17 @@ func (et *ExpertsTeam) Ask(ctx context.Context, prompt string) []ExpertAnswer {
 
 		go func(index int, assistant Assistant) {
 			defer wg.Done()
+			ctx, expertSpan := tracer.Start(ctx, "expert", trace.WithAttributes(attribute.String("expert", assistant.Name)))
+			defer expertSpan.End()
+
 			ans, err := assistant.Chat(ctx, prompt)
 			if err != nil {
+				expertSpan.RecordError(err)
+				expertSpan.SetStatus(codes.Error, err.Error())
 				ch <- result{
-					index: index,
-					error: fmt.Errorf("cannot get response from chat %s: %w", assistant.Name, err),
+					index:  index,
+					answer: ans,
+					error:  fmt.Errorf("cannot get response from chat %s: %w", assistant.Name, err),
 				}
 				return
 			}
@@ -52,12 +73,21 @@ func (et *ExpertsTeam) Ask(ctx context.Context, prompt string) []ExpertAnswer {
 
 	answers := make([]ExpertAnswer, len(et.Experts))
 
+	errorsCount := 0
 	for res := range ch {
+		usage := res.answer.Usage()
+		if res.error != nil {
+			errorsCount++
+			usage = UsageSoFar(res.answer)
+		}
 		answers[res.index] = ExpertAnswer{
-			Answer: res.answer,
+			Expert: et.Experts[res.index].Name,
+			Answer: res.answer.Response,
+			Usage:  usage,
 			Error:  res.error,
 		}
 	}
+	span.SetAttributes(attribute.Int("errors", errorsCount))
 
 	return answers
 }
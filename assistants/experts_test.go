@@ -0,0 +1,59 @@
+package assistants
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/tracing/tracingtest"
+	"go.opentelemetry.io/otel/codes"
+)
+
+func TestExpertsTeamAskSpans(t *testing.T) {
+	exporter := tracingtest.InMemory()
+	exporter.Reset()
+
+	answering := &llm.MockLLMProvider{
+		GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			return llm.ChatResponse{Response: "looks good"}, nil
+		},
+	}
+	failing := &llm.MockLLMProvider{
+		GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			return llm.ChatResponse{}, errors.New("rate limited")
+		},
+	}
+	team := ExpertsTeam{Experts: []Assistant{{Name: "reviewer", Llm: answering}, {Name: "tester", Llm: failing}}}
+
+	answers := team.Ask(context.Background(), "review")
+	if answers[0].Answer != "looks good" || answers[1].Error == nil {
+		t.Fatalf("unexpected answers %+v", answers)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 3 {
+		t.Fatalf("expected 3 spans, got %d", len(spans))
+	}
+	ask := spans[len(spans)-1]
+	if ask.Name != "ExpertsTeam.Ask" {
+		t.Fatalf("expected the Ask span to end last, got %s", ask.Name)
+	}
+	attrs := map[string]int64{}
+	for _, a := range ask.Attributes {
+		attrs[string(a.Key)] = a.Value.AsInt64()
+	}
+	if attrs["experts"] != 2 || attrs["errors"] != 1 {
+		t.Errorf("unexpected Ask attributes %v", ask.Attributes)
+	}
+
+	for _, s := range spans[:2] {
+		if s.Name != "expert" || s.Parent.SpanID() != ask.SpanContext.SpanID() {
+			t.Errorf("expected an expert span under Ask, got %s", s.Name)
+		}
+		failed := s.Status.Code == codes.Error
+		if name := s.Attributes[0].Value.AsString(); failed != (name == "tester") {
+			t.Errorf("unexpected status %v of expert %s", s.Status, name)
+		}
+	}
+}
@@ -0,0 +1,174 @@
+package assistants
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+)
+
+const (
+	ReadFileToolName  = "read_file"
+	ListFilesToolName = "list_files"
+
+	maxReadFileSize = 256 * 1024
+	maxListedFiles  = 1000
+)
+
+// FileTools returns the built-in tools giving the model read-only access to the files
+// under dir. Paths leaving dir, also through symlinks, are rejected.
+func FileTools(dir string) []Tool {
+	return []Tool{
+		{
+			Tool: llm.Tool{
+				Name:        ReadFileToolName,
+				Description: "Read a file from the input directory. The path is relative to the input directory.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path": map[string]any{"type": "string", "description": "Relative path of the file"},
+					},
+					"required":             []string{"path"},
+					"additionalProperties": false,
+				},
+			},
+			Run: func(_ context.Context, arguments string) (string, error) {
+				var args struct {
+					Path string `json:"path"`
+				}
+				if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+					return "", fmt.Errorf("invalid arguments: %w", err)
+				}
+				return readFile(dir, args.Path)
+			},
+		},
+		{
+			Tool: llm.Tool{
+				Name:        ListFilesToolName,
+				Description: "List files in the input directory recursively, with their sizes. The path is relative to the input directory, empty means all files.",
+				Parameters: map[string]any{
+					"type": "object",
+					"properties": map[string]any{
+						"path": map[string]any{"type": "string", "description": "Relative path of a directory"},
+					},
+					"additionalProperties": false,
+				},
+			},
+			Run: func(_ context.Context, arguments string) (string, error) {
+				var args struct {
+					Path string `json:"path"`
+				}
+				if arguments != "" {
+					if err := json.Unmarshal([]byte(arguments), &args); err != nil {
+						return "", fmt.Errorf("invalid arguments: %w", err)
+					}
+				}
+				return listFiles(dir, args.Path)
+			},
+		},
+	}
+}
+
+func readFile(dir string, path string) (string, error) {
+	fullPath, err := resolveInDir(dir, path)
+	if err != nil {
+		return "", err
+	}
+
+	f, err := os.Open(fullPath)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	content, err := io.ReadAll(io.LimitReader(f, maxReadFileSize+1))
+	if err != nil {
+		return "", err
+	}
+	if len(content) > maxReadFileSize {
+		return string(content[:maxReadFileSize]) + fmt.Sprintf("\n[truncated after %d bytes]", maxReadFileSize), nil
+	}
+	return string(content), nil
+}
+
+func listFiles(dir string, path string) (string, error) {
+	root, err := resolveInDir(dir, path)
+	if err != nil {
+		return "", err
+	}
+
+	var builder strings.Builder
+	count := 0
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if d.IsDir() {
+			if d.Name() == ".git" {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.Type().IsRegular() {
+			return nil
+		}
+
+		if count == maxListedFiles {
+			builder.WriteString("[more files not listed]\n")
+			return filepath.SkipAll
+		}
+		count++
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		rel, err := filepath.Rel(root, p)
+		if err != nil {
+			return err
+		}
+		fmt.Fprintf(&builder, "%s (%d bytes)\n", filepath.ToSlash(filepath.Join(path, rel)), info.Size())
+		return nil
+	})
+	if err != nil {
+		return "", err
+	}
+	if count == 0 {
+		return "no files", nil
+	}
+	return builder.String(), nil
+}
+
+// resolveInDir returns the real path of path relative to dir, or an error when it points
+// outside of dir.
+func resolveInDir(dir string, path string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(path))
+	if !filepath.IsLocal(clean) {
+		return "", fmt.Errorf("path %s is outside of the input directory", path)
+	}
+
+	realDir, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		return "", err
+	}
+	realPath, err := filepath.EvalSymlinks(filepath.Join(realDir, clean))
+	if errors.Is(err, fs.ErrNotExist) {
+		return "", fmt.Errorf("%s does not exist", path)
+	}
+	if err != nil {
+		return "", err
+	}
+
+	rel, err := filepath.Rel(realDir, realPath)
+	if err != nil || !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path %s is outside of the input directory", path)
+	}
+	return realPath, nil
+}
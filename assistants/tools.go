@@ -0,0 +1,81 @@
+package assistants
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
+)
+
+// Tool is a function an assistant can call. Run gets the JSON encoded arguments and
+// returns the result passed back to the model.
+type Tool struct {
+	llm.Tool
+	Run func(ctx context.Context, arguments string) (string, error)
+}
+
+// ToolRegistry holds the tools of an assistant. A nil registry has no tools.
+type ToolRegistry struct {
+	tools map[string]Tool
+	// order keeps the definitions sent to the model stable
+	order []string
+}
+
+func NewToolRegistry(tools ...Tool) (*ToolRegistry, error) {
+	r := &ToolRegistry{tools: map[string]Tool{}}
+	for _, t := range tools {
+		if err := r.Register(t); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+func (r *ToolRegistry) Register(tool Tool) error {
+	if tool.Name == "" || tool.Run == nil {
+		return fmt.Errorf("tool %q must have a name and a function", tool.Name)
+	}
+	if _, ok := r.tools[tool.Name]; ok {
+		return fmt.Errorf("tool %s is already registered", tool.Name)
+	}
+
+	r.tools[tool.Name] = tool
+	r.order = append(r.order, tool.Name)
+	return nil
+}
+
+func (r *ToolRegistry) definitions() []llm.Tool {
+	if r == nil {
+		return nil
+	}
+
+	definitions := make([]llm.Tool, 0, len(r.order))
+	for _, name := range r.order {
+		definitions = append(definitions, r.tools[name].Tool)
+	}
+	return definitions
+}
+
+// call runs the requested tool. Errors are returned to the model as the result, so it
+// can correct the call instead of failing the whole chat.
+func (r *ToolRegistry) call(ctx context.Context, call llm.ToolCall) string {
+	logger := loggerutils.GetLogger(ctx)
+
+	var tool Tool
+	ok := false
+	if r != nil {
+		tool, ok = r.tools[call.Name]
+	}
+	if !ok {
+		return fmt.Sprintf("error: unknown tool %s", call.Name)
+	}
+
+	logger.Debug("Calling tool", "name", call.Name, "arguments", call.Arguments)
+	result, err := tool.Run(ctx, call.Arguments)
+	if err != nil {
+		logger.Warn("Tool call failed", "name", call.Name, "error", err)
+		return fmt.Sprintf("error: %s", err)
+	}
+	return result
+}
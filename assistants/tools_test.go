@@ -0,0 +1,128 @@
+package assistants
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+)
+
+func TestAssistantChatCallsTools(t *testing.T) {
+	var requests []llm.ChatRequest
+	mockLLM := &llm.MockLLMProvider{
+		GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			requests = append(requests, req)
+			if len(requests) == 1 {
+				return llm.ChatResponse{
+					ToolCalls:  []llm.ToolCall{{ID: "call_1", Name: "echo", Arguments: `"ping"`}},
+					TokenUsage: llm.TokenUsage{TotalTokens: 5},
+				}, nil
+			}
+			return llm.ChatResponse{Response: "final", TokenUsage: llm.TokenUsage{TotalTokens: 7}}, nil
+		},
+	}
+
+	tools, err := NewToolRegistry(Tool{
+		Tool: llm.Tool{Name: "echo"},
+		Run: func(ctx context.Context, arguments string) (string, error) {
+			return "echo " + arguments, nil
+		},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	assistant := Assistant{Name: "TestAssistant", Llm: mockLLM, Tools: tools}
+	resp, err := assistant.Chat(context.Background(), "Hello")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.Response != "final" {
+		t.Errorf("expected 'final', got '%s'", resp.Response)
+	}
+	if usage := resp.Usage(); usage.Calls != 2 || usage.TokenUsage.TotalTokens != 12 {
+		t.Errorf("expected usage of 2 calls and 12 tokens, got %+v", usage)
+	}
+
+	if len(requests[0].Tools) != 1 || requests[0].Tools[0].Name != "echo" {
+		t.Errorf("tools not sent: %+v", requests[0].Tools)
+	}
+	last := requests[1].Messages[len(requests[1].Messages)-1]
+	if last.Role != llm.RoleTool || last.ToolCallID != "call_1" || last.Content != `echo "ping"` {
+		t.Errorf("unexpected tool result message %+v", last)
+	}
+}
+
+func TestAssistantChatToolStepsLimit(t *testing.T) {
+	mockLLM := &llm.MockLLMProvider{
+		GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			return llm.ChatResponse{
+				ToolCalls:  []llm.ToolCall{{ID: "call", Name: "unknown"}},
+				TokenUsage: llm.TokenUsage{TotalTokens: 5},
+			}, nil
+		},
+	}
+
+	assistant := Assistant{Llm: mockLLM, MaxToolSteps: 3}
+	resp, err := assistant.Chat(context.Background(), "Hello")
+	if err == nil {
+		t.Fatalf("expected error when the model never gives a final answer")
+	}
+	// the steps are paid for even though there is no answer
+	if usage := UsageSoFar(resp); usage.Calls != 3 || usage.TokenUsage.TotalTokens != 15 {
+		t.Errorf("expected usage of 3 calls and 15 tokens, got %+v", usage)
+	}
+	if len(resp.ToolCalls) != 0 {
+		t.Errorf("expected no tool calls in a failed response, got %+v", resp.ToolCalls)
+	}
+}
+
+func TestFileTools(t *testing.T) {
+	base := t.TempDir()
+	dir := filepath.Join(base, "input")
+	if err := os.MkdirAll(filepath.Join(dir, "pkg"), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "main.py"), []byte("import pkg"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "pkg", "util.py"), []byte("x = 1"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(base, "secret.txt"), []byte("secret"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Symlink(filepath.Join(base, "secret.txt"), filepath.Join(dir, "link.txt")); err != nil {
+		t.Fatal(err)
+	}
+
+	tools, err := NewToolRegistry(FileTools(dir)...)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	call := func(name, arguments string) string {
+		return tools.call(context.Background(), llm.ToolCall{Name: name, Arguments: arguments})
+	}
+
+	if got := call(ReadFileToolName, `{"path": "pkg/util.py"}`); got != "x = 1" {
+		t.Errorf("expected file content, got %q", got)
+	}
+
+	listing := call(ListFilesToolName, `{}`)
+	for _, f := range []string{"main.py (10 bytes)", "pkg/util.py (5 bytes)"} {
+		if !strings.Contains(listing, f) {
+			t.Errorf("expected %q in listing %q", f, listing)
+		}
+	}
+
+	for _, path := range []string{"../secret.txt", "/etc/passwd", "link.txt"} {
+		got := call(ReadFileToolName, `{"path": "`+path+`"}`)
+		if !strings.HasPrefix(got, "error:") {
+			t.Errorf("%s: expected error, got %q", path, got)
+		}
+	}
+}
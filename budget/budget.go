@@ -0,0 +1,106 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+)
+
+// ErrExceeded is returned instead of calling the LLM when a budget limit is reached.
+var ErrExceeded = errors.New("budget exceeded")
+
+// Limits of a run or a block, zero values mean no limit.
+type Limits struct {
+	MaxTokens   int           `yaml:"maxTokens"`
+	MaxUSD      float64       `yaml:"maxUSD"`
+	MaxDuration time.Duration `yaml:"maxDuration"`
+	MaxCalls    int           `yaml:"maxCalls"`
+}
+
+// Tracker counts LLM usage against its limits and the limits of its parent, so a block
+// tracker also enforces the limits of the whole run.
+type Tracker struct {
+	name    string
+	limits  Limits
+	pricing usage.Pricing
+	parent  *Tracker
+	start   time.Time
+
+	mu      sync.Mutex
+	tokens  int
+	costUSD float64
+	calls   int
+}
+
+func NewTracker(name string, limits Limits, pricing usage.Pricing, parent *Tracker) *Tracker {
+	return &Tracker{
+		name:    name,
+		limits:  limits,
+		pricing: pricing,
+		parent:  parent,
+		start:   time.Now(),
+	}
+}
+
+// Check returns an error wrapping ErrExceeded when any limit of the tracker or its
+// parents is reached. Limits are checked before calls, so parallel calls started just
+// before reaching a limit can exceed it slightly.
+func (t *Tracker) Check() error {
+	for tr := t; tr != nil; tr = tr.parent {
+		if err := tr.check(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (t *Tracker) check() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	switch {
+	case t.limits.MaxTokens > 0 && t.tokens >= t.limits.MaxTokens:
+		return fmt.Errorf("%w: %s used %d of %d tokens", ErrExceeded, t.name, t.tokens, t.limits.MaxTokens)
+	case t.limits.MaxUSD > 0 && t.costUSD >= t.limits.MaxUSD:
+		return fmt.Errorf("%w: %s spent $%.4f of $%.4f", ErrExceeded, t.name, t.costUSD, t.limits.MaxUSD)
+	case t.limits.MaxCalls > 0 && t.calls >= t.limits.MaxCalls:
+		return fmt.Errorf("%w: %s made %d of %d calls", ErrExceeded, t.name, t.calls, t.limits.MaxCalls)
+	case t.limits.MaxDuration > 0 && time.Since(t.start) >= t.limits.MaxDuration:
+		return fmt.Errorf("%w: %s has been running for more than %s", ErrExceeded, t.name, t.limits.MaxDuration)
+	}
+	return nil
+}
+
+// Record adds the usage of a call to the tracker and its parents.
+func (t *Tracker) Record(resp llm.ChatResponse) {
+	cost, _ := t.pricing.Cost(resp.Model, resp.TokenUsage)
+	for tr := t; tr != nil; tr = tr.parent {
+		tr.mu.Lock()
+		tr.tokens += resp.TokenUsage.TotalTokens
+		tr.costUSD += cost
+		tr.calls++
+		tr.mu.Unlock()
+	}
+}
+
+// Wrap returns a provider that checks the budget before and records usage after every
+// call, see llm.Intercept.
+func (t *Tracker) Wrap(provider llm.LLMProvider) llm.LLMProvider {
+	return llm.Intercept(provider, func(_ context.Context, _ llm.Call, send func() (llm.ChatResponse, error)) (llm.ChatResponse, error) {
+		if err := t.Check(); err != nil {
+			return llm.ChatResponse{}, err
+		}
+
+		resp, err := send()
+		if err != nil {
+			return resp, err
+		}
+		t.Record(resp)
+		return resp, nil
+	})
+}
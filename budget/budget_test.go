@@ -0,0 +1,67 @@
+package budget
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+)
+
+func TestTrackerLimits(t *testing.T) {
+	mock := &llm.MockStructuredLLMProvider{
+		GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+			return llm.ChatResponse{Model: "m", TokenUsage: llm.TokenUsage{InputTokens: 500_000, TotalTokens: 500_000}}, nil
+		},
+	}
+	pricing := usage.Pricing{"m": {Input: 1}}
+
+	tests := map[string]Limits{
+		"tokens": {MaxTokens: 1_000_000},
+		"usd":    {MaxUSD: 1},
+		"calls":  {MaxCalls: 2},
+	}
+
+	for name, limits := range tests {
+		t.Run(name, func(t *testing.T) {
+			run := NewTracker("run", Limits{}, pricing, nil)
+			block := NewTracker("block", limits, pricing, run)
+
+			provider, ok := block.Wrap(mock).(llm.StructuredLLMProvider)
+			if !ok {
+				t.Fatalf("expected structured output support to be preserved")
+			}
+
+			for range 2 {
+				if _, err := provider.GetResponse(context.Background(), llm.StructuredChatRequest{}); err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+			}
+
+			_, err := provider.GetResponse(context.Background(), llm.StructuredChatRequest{})
+			if !errors.Is(err, ErrExceeded) {
+				t.Fatalf("expected budget to be exceeded, got %v", err)
+			}
+		})
+	}
+}
+
+func TestTrackerParentLimits(t *testing.T) {
+	mock := &llm.MockLLMProvider{
+		GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+			return llm.ChatResponse{TokenUsage: llm.TokenUsage{TotalTokens: 10}}, nil
+		},
+	}
+
+	run := NewTracker("run", Limits{MaxCalls: 1}, usage.Pricing{}, nil)
+	first := run
+	second := NewTracker("second block", Limits{}, usage.Pricing{}, run)
+
+	if _, err := first.Wrap(mock).GetCompletion(context.Background(), llm.ChatRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := second.Wrap(mock).GetCompletion(context.Background(), llm.ChatRequest{}); !errors.Is(err, ErrExceeded) {
+		t.Fatalf("expected the run budget to be exceeded, got %v", err)
+	}
+}
@@ -0,0 +1,137 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	thinkingblock "github.com/aszmajdzinski/llm-feedback-loop-executor/thinking_block"
+)
+
+const (
+	checkpointsDirName     = "checkpoints"
+	blockCheckpointName    = "block.json"
+	iterationCheckpointFmt = "iteration-%03d.json"
+)
+
+// iterationCheckpoint is a single finished iteration of a thinking block.
+type iterationCheckpoint struct {
+	Iteration int                         `json:"iteration"`
+	Prompts   thinkingblock.Prompts       `json:"prompts"`
+	Answer    thinkingblock.PartialAnswer `json:"answer"`
+}
+
+// checkpointStore persists block outputs and iterations of a single block as JSON, so an
+// interrupted run can be resumed.
+type checkpointStore struct {
+	dir string
+}
+
+func newCheckpointStore(outputDir string, blockDirName string) checkpointStore {
+	return checkpointStore{dir: filepath.Join(outputDir, checkpointsDirName, blockDirName)}
+}
+
+// reset removes checkpoints left by a previous run in the same output directory.
+func (cs checkpointStore) reset() error {
+	return os.RemoveAll(cs.dir)
+}
+
+func (cs checkpointStore) saveIteration(
+	_ context.Context,
+	iteration int,
+	prompts thinkingblock.Prompts,
+	answer thinkingblock.PartialAnswer,
+) error {
+	return cs.write(fmt.Sprintf(iterationCheckpointFmt, iteration), iterationCheckpoint{
+		Iteration: iteration,
+		Prompts:   prompts,
+		Answer:    answer,
+	})
+}
+
+func (cs checkpointStore) saveBlock(output thinkingblock.ThinkingBlockOutput) error {
+	return cs.write(blockCheckpointName, output)
+}
+
+// loadBlock returns the output of a completed block, ok is false when the block has not
+// been completed.
+func (cs checkpointStore) loadBlock() (output thinkingblock.ThinkingBlockOutput, ok bool, err error) {
+	data, err := os.ReadFile(filepath.Join(cs.dir, blockCheckpointName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return output, false, nil
+	}
+	if err != nil {
+		return output, false, err
+	}
+
+	if err := json.Unmarshal(data, &output); err != nil {
+		return output, false, fmt.Errorf("cannot parse block checkpoint: %w", err)
+	}
+	return output, true, nil
+}
+
+// loadIterations returns the consecutive finished iterations of an unfinished block.
+func (cs checkpointStore) loadIterations() (thinkingblock.ThinkingBlockOutput, error) {
+	var output thinkingblock.ThinkingBlockOutput
+
+	entries, err := os.ReadDir(cs.dir)
+	if errors.Is(err, fs.ErrNotExist) {
+		return output, nil
+	}
+	if err != nil {
+		return output, err
+	}
+
+	var names []string
+	for _, e := range entries {
+		if strings.HasPrefix(e.Name(), "iteration-") && strings.HasSuffix(e.Name(), ".json") {
+			names = append(names, e.Name())
+		}
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		data, err := os.ReadFile(filepath.Join(cs.dir, name))
+		if err != nil {
+			return output, err
+		}
+
+		var ic iterationCheckpoint
+		if err := json.Unmarshal(data, &ic); err != nil {
+			return output, fmt.Errorf("cannot parse checkpoint %s: %w", name, err)
+		}
+		// a missing iteration means the rest cannot be trusted
+		if ic.Iteration != len(output.PartAnswers) {
+			break
+		}
+
+		output.Prompts = append(output.Prompts, ic.Prompts)
+		output.PartAnswers = append(output.PartAnswers, ic.Answer)
+	}
+
+	return output, nil
+}
+
+func (cs checkpointStore) write(name string, v any) error {
+	if err := os.MkdirAll(cs.dir, 0o755); err != nil {
+		return err
+	}
+
+	data, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	// write to a temporary file first so an interrupted write does not leave a broken checkpoint
+	tmpFile := filepath.Join(cs.dir, name+".tmp")
+	if err := os.WriteFile(tmpFile, data, 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmpFile, filepath.Join(cs.dir, name))
+}
@@ -0,0 +1,175 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/metrics"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/tracing"
+)
+
+// command is a subcommand of the CLI, run gets the arguments after its name.
+type command struct {
+	name    string
+	args    string
+	summary string
+	run     func(ctx context.Context, args []string) error
+}
+
+func commands() []command {
+	return []command{
+		{name: "run", args: "-config <file> [flags]", summary: "Run the blocks of an app setup", run: runCommand},
+		{name: "validate", args: "-config <file>", summary: "Check an app setup without calling any API", run: validateCommand},
+		{name: "plan", args: "-config <file>", summary: "Print the blocks, roles, models and estimated token cost", run: planCommand},
+		{name: "inspect", args: "<run dir>", summary: "Summarize the output directory of a run", run: inspectCommand},
+	}
+}
+
+var errUsage = errors.New("invalid usage")
+
+// runCLI runs the subcommand named by the first argument. Arguments starting with a flag
+// are passed to run, so the invocations from before the subcommands keep working.
+func runCLI(ctx context.Context, args []string) error {
+	if len(args) == 0 {
+		writeUsage(os.Stderr)
+		return fmt.Errorf("%w: missing command", errUsage)
+	}
+
+	help := slices.Contains([]string{"help", "-h", "-help", "--help"}, args[0])
+	if strings.HasPrefix(args[0], "-") && !help {
+		return runCommand(ctx, args)
+	}
+	for _, c := range commands() {
+		if c.name == args[0] {
+			return c.run(ctx, args[1:])
+		}
+	}
+
+	writeUsage(os.Stderr)
+	if help {
+		return nil
+	}
+	return fmt.Errorf("%w: unknown command %q", errUsage, args[0])
+}
+
+func writeUsage(w io.Writer) {
+	name := filepath.Base(os.Args[0])
+	fmt.Fprintf(w, "usage: %s <command> [arguments]\n\ncommands:\n", name)
+	for _, c := range commands() {
+		fmt.Fprintf(w, "  %-9s %s\n", c.name, c.summary)
+	}
+	fmt.Fprintf(w, "\nRun '%s <command> -h' for the flags of a command.\n", name)
+}
+
+func newFlagSet(c string) *flag.FlagSet {
+	fs := flag.NewFlagSet(c, flag.ExitOnError)
+	fs.Usage = func() {
+		for _, cmd := range commands() {
+			if cmd.name == c {
+				fmt.Fprintf(fs.Output(), "usage: %s %s %s\n\n%s.\n\nflags:\n",
+					filepath.Base(os.Args[0]), cmd.name, cmd.args, cmd.summary)
+			}
+		}
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// loadAppSetupFlag returns the app setup of the -config flag, it fails when it is not set.
+func loadAppSetupFlag(fs *flag.FlagSet, appSetupFile string) (AppSetup, error) {
+	if appSetupFile == "" {
+		fs.Usage()
+		return AppSetup{}, fmt.Errorf("%w: -config is required", errUsage)
+	}
+
+	appSetup, err := getAppData(appSetupFile)
+	if err != nil {
+		return AppSetup{}, fmt.Errorf("failed loading app setup file: %w", err)
+	}
+	return appSetup, nil
+}
+
+func runCommand(ctx context.Context, args []string) error {
+	fs := newFlagSet("run")
+	appSetupFile := fs.String("config", "", "Path to the app setup file")
+	output := fs.String("output", "", "Output directory of the run (default $OUTPUT_DIRECTORY, or the working directory)")
+	only := fs.String("only", "", "Comma-separated blocks to run, the other blocks reuse their outputs in the output directory")
+	from := fs.String("from", "", "Run this block and the blocks depending on it, the other blocks reuse their outputs in the output directory")
+	resumeDir := fs.String("resume", "", "Output directory of an interrupted run to resume")
+	stream := fs.Bool("stream", true, "Stream responses and show the progress of workers and experts on stderr")
+	traceFile := fs.String("trace-file", "", "Write OpenTelemetry spans to this file as JSON")
+	traceOTLP := fs.Bool("trace-otlp", false, "Export OpenTelemetry spans over OTLP/HTTP, configured with OTEL_EXPORTER_OTLP_* variables")
+	metricsAddr := fs.String("metrics-addr", "", "Serve Prometheus metrics on /metrics of this address, e.g. :9090")
+	logLevel := fs.String("log-level", "", "Log level: debug, info, warn or error (default debug)")
+	logFormat := fs.String("log-format", "", "Log format: text or json (default text)")
+	logFile := fs.String("log-file", "", "Append the logs to this file instead of stdout")
+	_ = fs.Parse(args)
+
+	if *resumeDir != "" && *output != "" {
+		fs.Usage()
+		return fmt.Errorf("%w: -resume already sets the output directory, it cannot be used with -output", errUsage)
+	}
+	appSetup, err := loadAppSetupFlag(fs, *appSetupFile)
+	if err != nil {
+		return err
+	}
+
+	logSetup := appSetup.Logging
+	logSetup.Level = valueOrDefault(*logLevel, logSetup.Level)
+	logSetup.Format = valueOrDefault(*logFormat, logSetup.Format)
+	logSetup.File = valueOrDefault(*logFile, logSetup.File)
+	logger, closeLog, err := loggerutils.SetupLogger(logSetup, providerAPIKeys(appSetup.Providers))
+	if err != nil {
+		return fmt.Errorf("failed setting up logging: %w", err)
+	}
+	defer closeLog()
+	ctx = loggerutils.WithLogger(ctx, logger)
+
+	providers, err := newProviderRegistry(appSetup.Providers)
+	if err != nil {
+		return fmt.Errorf("failed creating providers: %w", err)
+	}
+
+	opts := runOptions{OutputDir: valueOrDefault(*output, valueOrDefault(os.Getenv("OUTPUT_DIRECTORY"), "."))}
+	if *resumeDir != "" {
+		opts.OutputDir, opts.Resume = *resumeDir, true
+	}
+	if *only != "" {
+		for _, name := range strings.Split(*only, ",") {
+			opts.Only = append(opts.Only, strings.TrimSpace(name))
+		}
+	}
+	opts.From = *from
+	if *stream {
+		opts.Progress = newProgressPrinter(os.Stderr)
+	}
+	if *metricsAddr != "" {
+		opts.Metrics = metrics.New()
+		shutdownMetrics, err := opts.Metrics.Listen(*metricsAddr)
+		if err != nil {
+			return fmt.Errorf("failed serving metrics: %w", err)
+		}
+		defer shutdownMetrics(context.Background())
+		logger.Info("Serving metrics", "address", *metricsAddr)
+	}
+
+	shutdownTracing, err := tracing.Start(ctx, tracing.Setup{OTLP: *traceOTLP, File: *traceFile})
+	if err != nil {
+		return fmt.Errorf("failed setting up tracing: %w", err)
+	}
+	defer func() {
+		if err := shutdownTracing(context.Background()); err != nil {
+			logger.Error("error exporting spans", "error", err)
+		}
+	}()
+
+	return RunApp(ctx, appSetup, opts, providers)
+}
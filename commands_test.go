@@ -0,0 +1,14 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestRunCommandRejectsResumeWithOutput(t *testing.T) {
+	err := runCommand(context.Background(), []string{"-config", "setup.yaml", "-resume", "out", "-output", "other"})
+	if !errors.Is(err, errUsage) {
+		t.Fatalf("expected a usage error, got %v", err)
+	}
+}
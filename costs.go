@@ -0,0 +1,67 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+
+	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
+	thinkingblock "github.com/aszmajdzinski/llm-feedback-loop-executor/thinking_block"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+)
+
+const usageFileName = "usage.json"
+
+// usageRecords splits the usage of a block per iteration and assistant.
+func usageRecords(b Block, ans thinkingblock.ThinkingBlockOutput, pricing usage.Pricing) []usage.Record {
+	var records []usage.Record
+	for i, pa := range ans.PartAnswers {
+		records = append(records, usage.NewRecord(b.Name, i, "worker", b.Worker.Name, pa.WorkerUsage, pricing))
+		for eIdx, eu := range pa.ExpertsUsage {
+			name := ""
+			if eIdx < len(pa.ExpertNames) {
+				name = pa.ExpertNames[eIdx]
+			}
+			records = append(records, usage.NewRecord(b.Name, i, "expert", name, eu, pricing))
+		}
+		for eIdx, eu := range pa.FailedExpertsUsage {
+			name := ""
+			if eIdx < len(pa.FailedExperts) {
+				name = pa.FailedExperts[eIdx]
+			}
+			records = append(records, usage.NewRecord(b.Name, i, "expert", name, eu, pricing))
+		}
+		records = append(records, usage.NewRecord(b.Name, i, "oracle", b.Oracle.Name, pa.OracleUsage, pricing))
+	}
+	return records
+}
+
+// reportUsage writes usage.json to the output directory and prints the summary table.
+func reportUsage(
+	ctx context.Context,
+	appSetup AppSetup,
+	opts runOptions,
+	answers []thinkingblock.ThinkingBlockOutput,
+) {
+	logger := loggerutils.GetLogger(ctx)
+	pricing := usage.DefaultPricing().Override(appSetup.Pricing)
+
+	var records []usage.Record
+	for bn, ans := range answers {
+		records = append(records, usageRecords(appSetup.Blocks[bn], ans, pricing)...)
+	}
+	report := usage.NewReport(records)
+
+	err := os.MkdirAll(opts.OutputDir, 0o755)
+	if err == nil {
+		err = report.WriteJSON(filepath.Join(opts.OutputDir, usageFileName))
+	}
+	if err != nil {
+		logger.Error("error writing usage report", "error", err)
+	}
+
+	err = report.WriteTable(os.Stdout)
+	if err != nil {
+		logger.Error("error printing usage report", "error", err)
+	}
+}
@@ -0,0 +1,211 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"text/template"
+
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+	"gopkg.in/yaml.v3"
+)
+
+// errDependencyFailed marks blocks that were not run because one of their inputs failed.
+var errDependencyFailed = errors.New("dependency failed")
+
+// errRunCanceled marks blocks stopped because another block failed and the run was
+// canceled, they did not fail on their own.
+var errRunCanceled = errors.New("canceled, another block failed")
+
+// errNotSelected marks blocks that were not run because of the `-only` and `-from` filters
+// and whose outputs are not needed by the blocks that run.
+var errNotSelected = errors.New("block not selected")
+
+// filesInputName is the input name of the files read by `inputs.files`.
+const filesInputName = "files"
+
+// BlockInputs is the `inputs:` section of a block. It is either a list of block names or
+// a mapping with `blocks` and `files`.
+type BlockInputs struct {
+	// Blocks lists the blocks whose final answers are passed as DATA. nil means the
+	// previous block.
+	Blocks []string `yaml:"blocks"`
+	// Files reads existing files into DATA as a fileutils.FileList JSON, named `files`.
+	Files fileutils.CollectSetup `yaml:"files"`
+}
+
+func (in *BlockInputs) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.SequenceNode {
+		in.Blocks = []string{}
+		return value.Decode(&in.Blocks)
+	}
+
+	type plain BlockInputs
+	var p plain
+	if err := value.Decode(&p); err != nil {
+		return err
+	}
+	// a mapping without blocks keeps the previous block, `blocks: []` makes it independent
+	*in = BlockInputs(p)
+	return nil
+}
+
+// blockDependencies returns indexes of the input blocks of every block. A block without
+// `inputs` depends on the previous block, which keeps linear configurations working;
+// `inputs: []` makes it independent.
+func blockDependencies(blocks []Block) ([][]int, error) {
+	indexes := make(map[string]int, len(blocks))
+	for bn, b := range blocks {
+		if _, ok := indexes[b.Name]; ok {
+			return nil, fmt.Errorf("duplicated block name %s", b.Name)
+		}
+		indexes[b.Name] = bn
+	}
+
+	deps := make([][]int, len(blocks))
+	for bn, b := range blocks {
+		if b.Inputs.Blocks == nil {
+			if bn > 0 {
+				deps[bn] = []int{bn - 1}
+			}
+			continue
+		}
+
+		for _, input := range b.Inputs.Blocks {
+			idx, ok := indexes[input]
+			if !ok {
+				return nil, fmt.Errorf("block %s: unknown input block %s", b.Name, input)
+			}
+			if idx == bn {
+				return nil, fmt.Errorf("block %s: block cannot be its own input", b.Name)
+			}
+			deps[bn] = append(deps[bn], idx)
+		}
+	}
+
+	if err := checkCycles(blocks, deps); err != nil {
+		return nil, err
+	}
+
+	return deps, nil
+}
+
+func checkCycles(blocks []Block, deps [][]int) error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(blocks))
+
+	var visit func(bn int, path []string) error
+	visit = func(bn int, path []string) error {
+		path = append(path, blocks[bn].Name)
+		switch state[bn] {
+		case visiting:
+			return fmt.Errorf("blocks dependency cycle: %s", strings.Join(path, " -> "))
+		case visited:
+			return nil
+		}
+
+		state[bn] = visiting
+		for _, d := range deps[bn] {
+			if err := visit(d, path); err != nil {
+				return err
+			}
+		}
+		state[bn] = visited
+		return nil
+	}
+
+	for bn := range blocks {
+		if err := visit(bn, nil); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// selectBlocks returns which blocks run for the `-only` and `-from` filters. only lists
+// the blocks to run, from selects a block with all blocks depending on it, directly or
+// through other blocks. A block runs when it passes both filters, without filters all
+// blocks run.
+func selectBlocks(blocks []Block, deps [][]int, only []string, from string) ([]bool, error) {
+	indexes := make(map[string]int, len(blocks))
+	for bn, b := range blocks {
+		indexes[b.Name] = bn
+	}
+
+	selected := make([]bool, len(blocks))
+	for bn := range selected {
+		selected[bn] = len(only) == 0
+	}
+	for _, name := range only {
+		bn, ok := indexes[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown block %s in -only", name)
+		}
+		selected[bn] = true
+	}
+
+	if from == "" {
+		return selected, nil
+	}
+	start, ok := indexes[from]
+	if !ok {
+		return nil, fmt.Errorf("unknown block %s in -from", from)
+	}
+
+	downstream := make([]bool, len(blocks))
+	downstream[start] = true
+	// inputs can be listed in any order, so dependents are marked until nothing changes
+	for changed := true; changed; {
+		changed = false
+		for bn := range blocks {
+			if downstream[bn] {
+				continue
+			}
+			for _, d := range deps[bn] {
+				if downstream[d] {
+					downstream[bn] = true
+					changed = true
+					break
+				}
+			}
+		}
+	}
+
+	for bn := range selected {
+		selected[bn] = selected[bn] && downstream[bn]
+	}
+	return selected, nil
+}
+
+// blockInputData builds the DATA of a block from the final answers of its input blocks.
+// A single input is passed as is, multiple inputs are concatenated and tagged with the
+// block names unless the block defines a dataTemplate.
+func blockInputData(b Block, inputNames []string, inputs map[string]string) (string, error) {
+	if b.DataTemplate != "" {
+		tmpl, err := template.New("data").Parse(b.DataTemplate)
+		if err != nil {
+			return "", fmt.Errorf("cannot parse data template: %w", err)
+		}
+
+		var builder strings.Builder
+		err = tmpl.Execute(&builder, struct{ Inputs map[string]string }{Inputs: inputs})
+		if err != nil {
+			return "", fmt.Errorf("cannot render data template: %w", err)
+		}
+		return builder.String(), nil
+	}
+
+	if len(inputNames) == 1 {
+		return inputs[inputNames[0]], nil
+	}
+
+	var builder strings.Builder
+	for _, name := range inputNames {
+		fmt.Fprintf(&builder, "<INPUT %s>\n%s\n</INPUT %s>\n", name, inputs[name], name)
+	}
+	return builder.String(), nil
+}
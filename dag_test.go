@@ -0,0 +1,194 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+)
+
+func TestBlockDependencies(t *testing.T) {
+	blocks := []Block{
+		{Name: "backend", Inputs: BlockInputs{Blocks: []string{}}},
+		{Name: "frontend", Inputs: BlockInputs{Blocks: []string{}}},
+		{Name: "docs", Inputs: BlockInputs{Blocks: []string{"backend", "frontend"}}},
+		{Name: "summary"},
+	}
+
+	deps, err := blockDependencies(blocks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := [][]int{nil, nil, {0, 1}, {2}}
+	for bn := range blocks {
+		if len(deps[bn]) != len(expected[bn]) {
+			t.Fatalf("block %s: expected dependencies %v, got %v", blocks[bn].Name, expected[bn], deps[bn])
+		}
+		for i := range deps[bn] {
+			if deps[bn][i] != expected[bn][i] {
+				t.Errorf("block %s: expected dependencies %v, got %v", blocks[bn].Name, expected[bn], deps[bn])
+			}
+		}
+	}
+}
+
+func TestBlockDependenciesErrors(t *testing.T) {
+	tests := map[string][]Block{
+		"unknown input": {{Name: "a", Inputs: BlockInputs{Blocks: []string{"b"}}}},
+		"self input":    {{Name: "a", Inputs: BlockInputs{Blocks: []string{"a"}}}},
+		"duplicated":    {{Name: "a"}, {Name: "a"}},
+		"cycle": {
+			{Name: "a", Inputs: BlockInputs{Blocks: []string{"c"}}},
+			{Name: "b", Inputs: BlockInputs{Blocks: []string{"a"}}},
+			{Name: "c", Inputs: BlockInputs{Blocks: []string{"b"}}},
+		},
+	}
+
+	for name, blocks := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := blockDependencies(blocks); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestSelectBlocks(t *testing.T) {
+	blocks := []Block{
+		{Name: "backend", Inputs: BlockInputs{Blocks: []string{}}},
+		{Name: "frontend", Inputs: BlockInputs{Blocks: []string{}}},
+		{Name: "docs", Inputs: BlockInputs{Blocks: []string{"backend", "frontend"}}},
+		{Name: "summary"},
+	}
+	deps, err := blockDependencies(blocks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := map[string]struct {
+		only     []string
+		from     string
+		expected []bool
+	}{
+		"all":       {expected: []bool{true, true, true, true}},
+		"only":      {only: []string{"frontend", "summary"}, expected: []bool{false, true, false, true}},
+		"from":      {from: "frontend", expected: []bool{false, true, true, true}},
+		"from last": {from: "summary", expected: []bool{false, false, false, true}},
+		"both":      {only: []string{"backend", "docs"}, from: "frontend", expected: []bool{false, false, true, false}},
+	}
+
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			selected, err := selectBlocks(blocks, deps, tt.only, tt.from)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			for bn := range blocks {
+				if selected[bn] != tt.expected[bn] {
+					t.Errorf("expected %v, got %v", tt.expected, selected)
+					break
+				}
+			}
+		})
+	}
+
+	if _, err := selectBlocks(blocks, deps, []string{"api"}, ""); err == nil {
+		t.Errorf("expected an error for an unknown block")
+	}
+	if _, err := selectBlocks(blocks, deps, nil, "api"); err == nil {
+		t.Errorf("expected an error for an unknown block")
+	}
+}
+
+func TestBlockInputData(t *testing.T) {
+	inputs := map[string]string{"backend": "B", "frontend": "F"}
+
+	data, err := blockInputData(Block{}, []string{"backend"}, inputs)
+	if err != nil || data != "B" {
+		t.Errorf("expected single input to be passed as is, got '%s' (%v)", data, err)
+	}
+
+	data, err = blockInputData(Block{}, []string{"backend", "frontend"}, inputs)
+	expected := "<INPUT backend>\nB\n</INPUT backend>\n<INPUT frontend>\nF\n</INPUT frontend>\n"
+	if err != nil || data != expected {
+		t.Errorf("expected concatenated inputs, got '%s' (%v)", data, err)
+	}
+
+	b := Block{DataTemplate: `{{index .Inputs "frontend"}}+{{index .Inputs "backend"}}`}
+	data, err = blockInputData(b, []string{"backend", "frontend"}, inputs)
+	if err != nil || data != "F+B" {
+		t.Errorf("expected templated inputs, got '%s' (%v)", data, err)
+	}
+}
+
+func TestBlockInputsUnmarshal(t *testing.T) {
+	var blocks []Block
+	err := yaml.Unmarshal([]byte(`
+- name: a
+- name: b
+  inputs: [a]
+- name: c
+  inputs: []
+- name: d
+  inputs:
+    files:
+      root: ./src
+      include: ["*.py"]
+- name: e
+  inputs:
+    blocks: [a, b]
+    files:
+      root: ./src
+- name: f
+  inputs:
+    blocks: []
+    files:
+      root: ./src
+`), &blocks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if blocks[0].Inputs.Blocks != nil {
+		t.Errorf("expected no inputs to mean the previous block, got %v", blocks[0].Inputs.Blocks)
+	}
+	if len(blocks[1].Inputs.Blocks) != 1 || blocks[1].Inputs.Blocks[0] != "a" {
+		t.Errorf("unexpected list inputs %v", blocks[1].Inputs.Blocks)
+	}
+	if blocks[2].Inputs.Blocks == nil || len(blocks[2].Inputs.Blocks) != 0 {
+		t.Errorf("expected empty inputs, got %v", blocks[2].Inputs.Blocks)
+	}
+	if blocks[3].Inputs.Blocks != nil || blocks[3].Inputs.Files.Root != "./src" || blocks[3].Inputs.Files.Include[0] != "*.py" {
+		t.Errorf("expected files inputs with the previous block, got %+v", blocks[3].Inputs)
+	}
+	if len(blocks[4].Inputs.Blocks) != 2 || blocks[4].Inputs.Files.Root != "./src" {
+		t.Errorf("unexpected mixed inputs %+v", blocks[4].Inputs)
+	}
+	if blocks[5].Inputs.Blocks == nil || len(blocks[5].Inputs.Blocks) != 0 || blocks[5].Inputs.Files.Root != "./src" {
+		t.Errorf("expected files inputs without blocks, got %+v", blocks[5].Inputs)
+	}
+}
+
+func TestCanceledBlockError(t *testing.T) {
+	canceled := fmt.Errorf("error chatting with worker: %w", context.Canceled)
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	cancel(errRunCanceled)
+	if err := canceledBlockError(ctx, canceled); !errors.Is(err, errRunCanceled) {
+		t.Errorf("expected a block canceled by another block, got %v", err)
+	}
+	failed := errors.New("non-200 status code: 500")
+	if err := canceledBlockError(ctx, failed); err != failed {
+		t.Errorf("expected the error of a failed block to be kept, got %v", err)
+	}
+
+	// the run itself was canceled, e.g. by an interrupt
+	ctx, stop := context.WithCancel(context.Background())
+	stop()
+	if err := canceledBlockError(ctx, canceled); err != canceled {
+		t.Errorf("expected the cancellation of the run to be kept, got %v", err)
+	}
+}
@@ -0,0 +1,259 @@
+package executor
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"slices"
+	"strings"
+	"time"
+
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
+)
+
+const (
+	defaultTimeout   = 2 * time.Minute
+	defaultMaxOutput = 8 * 1024
+	// waitDelay is how long a timed out command gets to exit after it is killed.
+	waitDelay = 5 * time.Second
+)
+
+// Isolation decides how generated files are executed.
+type Isolation string
+
+const (
+	// IsolationBwrap runs commands in a bubblewrap sandbox: no network, read-only system
+	// directories and the files in a tmpfs working directory. It is the default.
+	IsolationBwrap Isolation = "bwrap"
+	// IsolationNone runs commands as plain subprocesses with full access to the host file
+	// system and network. It has to be chosen explicitly.
+	IsolationNone Isolation = "none"
+)
+
+// Setup is the `executor:` section of the app setup.
+type Setup struct {
+	Isolation Isolation `yaml:"isolation"`
+	// Env lists the variables of the host environment passed to the commands. Commands
+	// get only PATH, HOME, LANG and TMPDIR otherwise, so the API keys of the run cannot
+	// end up in their output.
+	Env []string `yaml:"env"`
+	// Binds lists host paths mounted read-only in the sandbox besides the system
+	// directories, e.g. a virtualenv with the tools the commands need.
+	Binds []string `yaml:"binds"`
+	// Files limits the files written to the working directory, it is set from the
+	// `outputFiles` section of the app setup.
+	Files fileutils.SaveLimits `yaml:"-"`
+}
+
+// Limits of a single command, zero values use the defaults or mean no limit.
+type Limits struct {
+	// Timeout is the wall-clock limit, 2 minutes by default.
+	Timeout time.Duration `yaml:"timeout"`
+	// MaxOutput limits stdout and stderr in bytes, 8 KiB by default.
+	MaxOutput int `yaml:"maxOutput"`
+	// CPUTime is the CPU time limit of every process, rounded up to seconds.
+	CPUTime time.Duration `yaml:"cpuTime"`
+	// MemoryMB limits the virtual memory of every process.
+	MemoryMB int `yaml:"memoryMB"`
+}
+
+// Result of a single command. ExitCode is -1 when the command did not finish.
+type Result struct {
+	Command   string        `json:"command"`
+	ExitCode  int           `json:"exitCode"`
+	Stdout    string        `json:"stdout"`
+	Stderr    string        `json:"stderr"`
+	TimedOut  bool          `json:"timedOut"`
+	Duration  time.Duration `json:"duration"`
+	Isolation Isolation     `json:"isolation"`
+}
+
+func (r Result) Passed() bool {
+	return r.ExitCode == 0 && !r.TimedOut
+}
+
+// Executor runs commands over generated files in isolation.
+type Executor struct {
+	isolation Isolation
+	limits    Limits
+	bwrapPath string
+	files     fileutils.SaveLimits
+	env       []string
+	binds     []string
+}
+
+// systemDirs are mounted read-only in the sandbox. The rest of the host, e.g. the home
+// directory or the .env file of the run, is not visible to the commands.
+var systemDirs = []string{"/usr", "/bin", "/sbin", "/lib", "/lib32", "/lib64", "/etc"}
+
+// New returns an executor, it fails when the sandbox is not available instead of falling
+// back to running the files without isolation.
+func New(setup Setup, limits Limits) (*Executor, error) {
+	e := &Executor{
+		isolation: setup.Isolation,
+		limits:    limits,
+		files:     setup.Files,
+		env:       setup.Env,
+		binds:     setup.Binds,
+	}
+	if e.isolation == "" {
+		e.isolation = IsolationBwrap
+	}
+	if e.limits.Timeout <= 0 {
+		e.limits.Timeout = defaultTimeout
+	}
+	if e.limits.MaxOutput <= 0 {
+		e.limits.MaxOutput = defaultMaxOutput
+	}
+
+	switch e.isolation {
+	case IsolationBwrap:
+		path, err := exec.LookPath("bwrap")
+		if err != nil {
+			return nil, fmt.Errorf(
+				"bubblewrap (bwrap) is needed to run generated files in a sandbox, "+
+					"install it or set `isolation: %s` to run them without isolation: %w",
+				IsolationNone, err,
+			)
+		}
+		e.bwrapPath = path
+	case IsolationNone:
+	default:
+		return nil, fmt.Errorf("unknown isolation %q", e.isolation)
+	}
+
+	return e, nil
+}
+
+// Run writes the files to a fresh working directory and runs the command there with
+// `sh -c`. The returned error is set only when the command could not be run at all.
+func (e *Executor) Run(ctx context.Context, files fileutils.FileList, command string) (Result, error) {
+	logger := loggerutils.GetLogger(ctx)
+
+	dir, err := os.MkdirTemp("", "executor-*")
+	if err != nil {
+		return Result{}, fmt.Errorf("cannot create working directory: %w", err)
+	}
+	defer os.RemoveAll(dir)
+
+	skipped, err := fileutils.SaveFiles(dir, files, e.files)
+	if err != nil {
+		return Result{}, fmt.Errorf("cannot save files: %w", err)
+	}
+	for _, s := range skipped {
+		logger.Warn("Executor: file not written", "file", s.FileName, "reason", s.Reason)
+	}
+
+	cmdCtx, cancel := context.WithTimeout(ctx, e.limits.Timeout)
+	defer cancel()
+
+	stdout := newOutputBuffer(e.limits.MaxOutput)
+	stderr := newOutputBuffer(e.limits.MaxOutput)
+	cmd := e.command(cmdCtx, dir, command)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.WaitDelay = waitDelay
+	killProcessGroup(cmd)
+
+	start := time.Now()
+	err = cmd.Run()
+	res := Result{
+		Command:   command,
+		Stdout:    stdout.String(),
+		Stderr:    stderr.String(),
+		Duration:  time.Since(start),
+		Isolation: e.isolation,
+	}
+
+	var exitErr *exec.ExitError
+	switch {
+	case ctx.Err() != nil:
+		return res, ctx.Err()
+	case cmdCtx.Err() != nil:
+		res.TimedOut = true
+		res.ExitCode = -1
+	case errors.As(err, &exitErr):
+		res.ExitCode = exitErr.ExitCode()
+	case err != nil:
+		return res, fmt.Errorf("cannot run %q: %w", command, err)
+	}
+	return res, nil
+}
+
+func (e *Executor) command(ctx context.Context, dir string, command string) *exec.Cmd {
+	limits := e.ulimits()
+
+	if e.isolation == IsolationNone {
+		cmd := exec.CommandContext(ctx, "sh", "-c", limits+`exec sh -c "$1"`, "sh", command)
+		cmd.Dir = dir
+		cmd.Env = e.environment(dir, os.TempDir())
+		return cmd
+	}
+
+	// the files are copied from a read-only bind mount to the tmpfs working directory, so
+	// the command can modify them without touching the host
+	script := "cp -R /tmp/src/. /tmp/work/ && " + limits + `exec sh -c "$1"`
+	args := []string{
+		"--unshare-all",
+		"--die-with-parent",
+		"--new-session",
+		"--clearenv",
+	}
+	for _, path := range append(slices.Clone(systemDirs), e.binds...) {
+		args = append(args, "--ro-bind-try", path, path)
+	}
+	args = append(args,
+		"--dev", "/dev",
+		"--proc", "/proc",
+		"--tmpfs", "/tmp",
+		"--dir", "/tmp/work",
+		"--ro-bind", dir, "/tmp/src",
+		"--chdir", "/tmp/work",
+	)
+	for _, v := range e.environment("/tmp/work", "/tmp") {
+		name, value, _ := strings.Cut(v, "=")
+		args = append(args, "--setenv", name, value)
+	}
+	args = append(args, "--", "sh", "-c", script, "sh", command)
+	return exec.CommandContext(ctx, e.bwrapPath, args...)
+}
+
+// environment returns the variables of a command: a minimal environment with the working
+// directory as HOME and the variables of the Env allowlist that are set on the host.
+func (e *Executor) environment(home, tmpDir string) []string {
+	env := []string{
+		"PATH=" + valueOrDefault(os.Getenv("PATH"), "/usr/local/bin:/usr/bin:/bin"),
+		"HOME=" + home,
+		"LANG=" + valueOrDefault(os.Getenv("LANG"), "C.UTF-8"),
+		"TMPDIR=" + tmpDir,
+	}
+	for _, name := range e.env {
+		if value, ok := os.LookupEnv(name); ok {
+			env = append(env, name+"="+value)
+		}
+	}
+	return env
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+// ulimits returns the shell commands setting the resource limits of the command.
+func (e *Executor) ulimits() string {
+	var builder strings.Builder
+	if e.limits.CPUTime > 0 {
+		seconds := int64((e.limits.CPUTime + time.Second - 1) / time.Second)
+		fmt.Fprintf(&builder, "ulimit -t %d && ", seconds)
+	}
+	if e.limits.MemoryMB > 0 {
+		fmt.Fprintf(&builder, "ulimit -v %d && ", e.limits.MemoryMB*1024)
+	}
+	return builder.String()
+}
@@ -0,0 +1,147 @@
+package executor
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+)
+
+var testFiles = fileutils.FileList{Files: []fileutils.File{
+	{FileName: "main.sh", FileContent: "echo hello from main"},
+	{FileName: "pkg/data.txt", FileContent: "data"},
+}}
+
+func TestRunUnsandboxed(t *testing.T) {
+	e, err := New(Setup{Isolation: IsolationNone}, Limits{MaxOutput: 100, CPUTime: 10 * time.Second, MemoryMB: 1024})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res, err := e.Run(context.Background(), testFiles, "sh main.sh && cat pkg/data.txt >&2 && exit 4")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if res.ExitCode != 4 || res.Stdout != "hello from main\n" || res.Stderr != "data" {
+		t.Errorf("unexpected result %+v", res)
+	}
+	if res.Isolation != IsolationNone {
+		t.Errorf("expected isolation %q, got %q", IsolationNone, res.Isolation)
+	}
+
+	res, err = e.Run(context.Background(), testFiles, "head -c 100000 /dev/zero | tr '\\0' a")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(res.Stdout, "[... 99900 bytes truncated ...]") || len(res.Stdout) > 200 {
+		t.Errorf("output not truncated: %q", res.Stdout)
+	}
+}
+
+func TestRunEnvironment(t *testing.T) {
+	t.Setenv("OPENAI_API_KEY", "sk-secret")
+	t.Setenv("PIP_INDEX_URL", "https://pypi.example.com")
+	e, err := New(Setup{Isolation: IsolationNone, Env: []string{"PIP_INDEX_URL"}}, Limits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	res, err := e.Run(context.Background(), testFiles, "env")
+	if err != nil || !res.Passed() {
+		t.Fatalf("unexpected result %+v, error %v", res, err)
+	}
+	if strings.Contains(res.Stdout, "sk-secret") {
+		t.Errorf("API key visible to the command: %q", res.Stdout)
+	}
+	if !strings.Contains(res.Stdout, "PIP_INDEX_URL=https://pypi.example.com") {
+		t.Errorf("allowed variable not passed to the command: %q", res.Stdout)
+	}
+}
+
+func TestRunTimeout(t *testing.T) {
+	e, err := New(Setup{Isolation: IsolationNone}, Limits{Timeout: 100 * time.Millisecond})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	start := time.Now()
+	res, err := e.Run(context.Background(), testFiles, "sleep 10")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !res.TimedOut || res.Passed() {
+		t.Errorf("expected timed out command, got %+v", res)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("command was not killed after the timeout")
+	}
+}
+
+func TestNewRequiresSandbox(t *testing.T) {
+	if _, err := exec.LookPath("bwrap"); err == nil {
+		t.Skip("bwrap is installed")
+	}
+
+	if _, err := New(Setup{}, Limits{}); err == nil {
+		t.Errorf("expected error without bwrap instead of running files unsandboxed")
+	}
+}
+
+func TestRunSandboxed(t *testing.T) {
+	e, err := New(Setup{Isolation: IsolationBwrap}, Limits{})
+	if err != nil {
+		t.Skipf("sandbox not available: %v", err)
+	}
+	if res, err := e.Run(context.Background(), testFiles, "true"); err != nil || !res.Passed() {
+		t.Skipf("sandbox cannot be started here: %v %+v", err, res)
+	}
+
+	t.Setenv("OPENAI_API_KEY", "sk-secret")
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	tests := []struct {
+		name    string
+		command string
+		passes  bool
+	}{
+		{"files are available and writable", "cat pkg/data.txt && echo more >> pkg/data.txt", true},
+		{"root is read-only", "touch /usr/sandbox-test", false},
+		{"no network", "getent hosts example.com || exit 1", false},
+		{"API keys are not visible", `test -n "$OPENAI_API_KEY"`, false},
+		{"host files are not visible", "ls " + wd, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res, err := e.Run(context.Background(), testFiles, tt.command)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if res.Passed() != tt.passes {
+				t.Errorf("expected passed=%v, got %+v", tt.passes, res)
+			}
+		})
+	}
+}
+
+func TestOutputBuffer(t *testing.T) {
+	b := newOutputBuffer(10)
+	b.Write([]byte("short"))
+	if b.String() != "short" {
+		t.Errorf("expected unchanged output, got %q", b.String())
+	}
+
+	b = newOutputBuffer(10)
+	b.Write([]byte(strings.Repeat("a", 10)))
+	b.Write([]byte(strings.Repeat("b", 10)))
+	expected := "aaaaa\n[... 10 bytes truncated ...]\nbbbbb"
+	if b.String() != expected {
+		t.Errorf("expected %q, got %q", expected, b.String())
+	}
+}
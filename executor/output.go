@@ -0,0 +1,46 @@
+package executor
+
+import (
+	"fmt"
+	"strings"
+)
+
+// outputBuffer keeps the beginning and the end of a command output, where compilers and
+// test runners usually put the most useful lines, and drops the middle once the output
+// is longer than max bytes.
+type outputBuffer struct {
+	half  int
+	head  []byte
+	tail  []byte
+	total int
+}
+
+func newOutputBuffer(max int) *outputBuffer {
+	return &outputBuffer{half: max / 2}
+}
+
+func (b *outputBuffer) Write(p []byte) (int, error) {
+	written := len(p)
+	b.total += written
+
+	if n := min(b.half-len(b.head), len(p)); n > 0 {
+		b.head = append(b.head, p[:n]...)
+		p = p[n:]
+	}
+
+	b.tail = append(b.tail, p...)
+	if len(b.tail) > b.half {
+		b.tail = append(b.tail[:0], b.tail[len(b.tail)-b.half:]...)
+	}
+	return written, nil
+}
+
+func (b *outputBuffer) String() string {
+	dropped := b.total - len(b.head) - len(b.tail)
+	if dropped == 0 {
+		return string(b.head) + string(b.tail)
+	}
+	return strings.ToValidUTF8(string(b.head), "") +
+		fmt.Sprintf("\n[... %d bytes truncated ...]\n", dropped) +
+		strings.ToValidUTF8(string(b.tail), "")
+}
@@ -0,0 +1,8 @@
+//go:build !unix
+
+package executor
+
+import "os/exec"
+
+// killProcessGroup is a no-op, only the command itself is killed when it is canceled.
+func killProcessGroup(cmd *exec.Cmd) {}
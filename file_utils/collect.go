@@ -0,0 +1,135 @@
+package fileutils
+
+import (
+	"bytes"
+	"fmt"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"unicode/utf8"
+)
+
+// DefaultMaxFileSize is used when CollectSetup.MaxFileSize is not set.
+const DefaultMaxFileSize = 256 * 1024
+
+// binarySniffSize is the number of leading bytes searched for a NUL byte.
+const binarySniffSize = 8000
+
+// CollectSetup selects the files read from a directory.
+type CollectSetup struct {
+	// Root is the directory the files are read from, file names are relative to it.
+	Root string `yaml:"root"`
+	// Include lists the patterns (.gitignore syntax) of the files to read, all files
+	// when empty.
+	Include []string `yaml:"include"`
+	// Exclude lists the patterns (.gitignore syntax) of the files to skip. The
+	// .gitignore files found in Root are always honored.
+	Exclude []string `yaml:"exclude"`
+	// MaxFileSize skips larger files, in bytes.
+	MaxFileSize int64 `yaml:"maxFileSize"`
+}
+
+// SkippedFile is a file matching the setup that was not read.
+type SkippedFile struct {
+	FileName string
+	Reason   string
+}
+
+// CollectFiles reads the text files selected by the setup. Ignored files and the .git
+// directory are left out silently; files too large, binary or not regular are returned
+// as skipped.
+func CollectFiles(setup CollectSetup) (FileList, []SkippedFile, error) {
+	maxFileSize := setup.MaxFileSize
+	if maxFileSize <= 0 {
+		maxFileSize = DefaultMaxFileSize
+	}
+
+	var include, exclude, gitignore IgnoreRules
+	include.AddPatterns("", setup.Include)
+	exclude.AddPatterns("", setup.Exclude)
+
+	fileList := FileList{Files: []File{}}
+	var skipped []SkippedFile
+
+	err := filepath.WalkDir(setup.Root, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(setup.Root, path)
+		if err != nil {
+			return err
+		}
+		rel = filepath.ToSlash(rel)
+
+		if d.IsDir() {
+			if rel == "." {
+				return addGitignore(&gitignore, "", path)
+			}
+			if d.Name() == ".git" || gitignore.Match(rel, true) || exclude.Match(rel, true) {
+				return filepath.SkipDir
+			}
+			return addGitignore(&gitignore, rel, path)
+		}
+
+		if gitignore.Match(rel, false) || exclude.Match(rel, false) {
+			return nil
+		}
+		if len(setup.Include) > 0 && !include.Match(rel, false) {
+			return nil
+		}
+
+		if !d.Type().IsRegular() {
+			skipped = append(skipped, SkippedFile{FileName: rel, Reason: "not a regular file"})
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			return err
+		}
+		if info.Size() > maxFileSize {
+			skipped = append(skipped, SkippedFile{
+				FileName: rel,
+				Reason:   fmt.Sprintf("larger than %d bytes", maxFileSize),
+			})
+			return nil
+		}
+
+		content, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		if isBinary(content) {
+			skipped = append(skipped, SkippedFile{FileName: rel, Reason: "binary"})
+			return nil
+		}
+
+		fileList.Files = append(fileList.Files, File{FileName: rel, FileContent: string(content)})
+		return nil
+	})
+	if err != nil {
+		return FileList{}, nil, fmt.Errorf("error collecting files from %s: %w", setup.Root, err)
+	}
+
+	return fileList, skipped, nil
+}
+
+func addGitignore(rules *IgnoreRules, base string, dir string) error {
+	f, err := os.Open(filepath.Join(dir, ".gitignore"))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	return rules.Add(base, f)
+}
+
+// isBinary tells whether the content is not text: it has a NUL byte at the beginning or
+// is not valid UTF-8.
+func isBinary(content []byte) bool {
+	return bytes.IndexByte(content[:min(len(content), binarySniffSize)], 0) >= 0 || !utf8.Valid(content)
+}
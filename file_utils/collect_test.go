@@ -0,0 +1,125 @@
+package fileutils
+
+import (
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+)
+
+func writeTestFiles(t *testing.T, dir string, files map[string]string) {
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0o644); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	}
+}
+
+func TestCollectFiles(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{
+		".gitignore":          "build/\n*.log\n!keep.log\n",
+		"main.py":             "print(1)",
+		"keep.log":            "kept",
+		"debug.log":           "ignored",
+		"build/out.py":        "ignored",
+		"pkg/.gitignore":      "/local.py\n",
+		"pkg/util.py":         "def util(): pass",
+		"pkg/local.py":        "ignored",
+		"pkg/sub/local.py":    "kept",
+		"pkg/image.py":        "\x00\x01\x02",
+		"pkg/big.py":          "0123456789",
+		"pkg/test_util.py":    "excluded",
+		"docs/readme.md":      "not included",
+		".git/HEAD":           "ref: refs/heads/main",
+		"vendor/lib/a.py":     "excluded dir",
+		"pkg/latin1.py":       "caf\xe9",
+		"pkg/sub/nested.py":   "nested",
+		"pkg/sub/.gitignore":  "nested.py\n",
+		"pkg/sub/visible.txt": "not included",
+	})
+
+	fileList, skipped, err := CollectFiles(CollectSetup{
+		Root:        dir,
+		Include:     []string{"*.py", "*.log"},
+		Exclude:     []string{"test_*.py", "vendor/"},
+		MaxFileSize: 9,
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var names []string
+	for _, f := range fileList.Files {
+		names = append(names, f.FileName)
+	}
+	expected := []string{"keep.log", "main.py", "pkg/sub/local.py"}
+	if !slices.Equal(names, expected) {
+		t.Errorf("expected files %v, got %v", expected, names)
+	}
+	if fileList.Files[1].FileContent != "print(1)" {
+		t.Errorf("unexpected content %q", fileList.Files[1].FileContent)
+	}
+
+	skippedReasons := map[string]string{}
+	for _, s := range skipped {
+		skippedReasons[s.FileName] = s.Reason
+	}
+	expectedSkipped := map[string]string{
+		"pkg/big.py":    "larger than 9 bytes",
+		"pkg/image.py":  "binary",
+		"pkg/latin1.py": "binary",
+		"pkg/util.py":   "larger than 9 bytes",
+	}
+	if len(skippedReasons) != len(expectedSkipped) {
+		t.Errorf("expected skipped %v, got %v", expectedSkipped, skippedReasons)
+	}
+	for name, reason := range expectedSkipped {
+		if skippedReasons[name] != reason {
+			t.Errorf("expected %s skipped as %q, got %q", name, reason, skippedReasons[name])
+		}
+	}
+}
+
+func TestCollectFilesMissingRoot(t *testing.T) {
+	_, _, err := CollectFiles(CollectSetup{Root: filepath.Join(t.TempDir(), "missing")})
+	if err == nil {
+		t.Fatalf("expected error for a missing root")
+	}
+}
+
+func TestIgnoreRules(t *testing.T) {
+	var rules IgnoreRules
+	rules.AddPatterns("", []string{"# comment", "*.tmp", "/root.txt", "docs/**/*.md", "!docs/keep.md", "cache/", `\#hash`})
+	rules.AddPatterns("sub", []string{"local"})
+
+	tests := []struct {
+		path    string
+		isDir   bool
+		ignored bool
+	}{
+		{"a.tmp", false, true},
+		{"x/y/a.tmp", false, true},
+		{"root.txt", false, true},
+		{"x/root.txt", false, false},
+		{"docs/a.md", false, true},
+		{"docs/x/y/a.md", false, true},
+		{"docs/keep.md", false, false},
+		{"cache", true, true},
+		{"cache", false, false},
+		{"x/cache", true, true},
+		{"#hash", false, true},
+		{"sub/local", false, true},
+		{"sub/x/local", false, true},
+		{"local", false, false},
+	}
+	for _, tt := range tests {
+		if got := rules.Match(tt.path, tt.isDir); got != tt.ignored {
+			t.Errorf("Match(%q, %v) = %v, expected %v", tt.path, tt.isDir, got, tt.ignored)
+		}
+	}
+}
@@ -0,0 +1,149 @@
+package fileutils
+
+import (
+	"slices"
+	"strings"
+)
+
+// maxDiffEdits bounds the work of DiffLines, larger differences are reported as the whole
+// changed part removed and added.
+const maxDiffEdits = 2000
+
+// DiffOp is the kind of a DiffLine.
+type DiffOp int
+
+const (
+	DiffEqual DiffOp = iota
+	DiffDelete
+	DiffInsert
+)
+
+// DiffLine is a line of a diff, deleted lines come from the old text and inserted ones
+// from the new text.
+type DiffLine struct {
+	Op   DiffOp
+	Text string
+}
+
+// SplitLines splits text into lines without the line endings.
+func SplitLines(text string) []string {
+	if text == "" {
+		return nil
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
+
+// DiffStat counts the lines added and removed between two texts.
+func DiffStat(oldText, newText string) (added int, removed int) {
+	for _, l := range DiffLines(SplitLines(oldText), SplitLines(newText)) {
+		switch l.Op {
+		case DiffInsert:
+			added++
+		case DiffDelete:
+			removed++
+		}
+	}
+	return added, removed
+}
+
+// DiffLines returns a shortest line diff turning a into b (Myers' algorithm).
+func DiffLines(a, b []string) []DiffLine {
+	prefix := 0
+	for prefix < len(a) && prefix < len(b) && a[prefix] == b[prefix] {
+		prefix++
+	}
+	suffix := 0
+	for suffix < len(a)-prefix && suffix < len(b)-prefix && a[len(a)-1-suffix] == b[len(b)-1-suffix] {
+		suffix++
+	}
+
+	var diff []DiffLine
+	for _, l := range a[:prefix] {
+		diff = append(diff, DiffLine{Op: DiffEqual, Text: l})
+	}
+	diff = append(diff, myers(a[prefix:len(a)-suffix], b[prefix:len(b)-suffix])...)
+	for _, l := range a[len(a)-suffix:] {
+		diff = append(diff, DiffLine{Op: DiffEqual, Text: l})
+	}
+	return diff
+}
+
+func myers(a, b []string) []DiffLine {
+	n, m := len(a), len(b)
+	if n == 0 || m == 0 {
+		return replaceAll(a, b)
+	}
+
+	// v[k] is the furthest x reached on diagonal k, trace[d] keeps v[-d..d] before step d
+	offset := n + m
+	v := make([]int, 2*offset+2)
+	var trace [][]int
+	for d := 0; d <= min(n+m, maxDiffEdits); d++ {
+		trace = append(trace, slices.Clone(v[offset-d:offset+d+1]))
+		for k := -d; k <= d; k += 2 {
+			var x int
+			if k == -d || (k != d && v[offset+k-1] < v[offset+k+1]) {
+				x = v[offset+k+1]
+			} else {
+				x = v[offset+k-1] + 1
+			}
+			y := x - k
+			for x < n && y < m && a[x] == b[y] {
+				x++
+				y++
+			}
+			v[offset+k] = x
+			if x >= n && y >= m {
+				return backtrack(a, b, trace)
+			}
+		}
+	}
+	return replaceAll(a, b)
+}
+
+func backtrack(a, b []string, trace [][]int) []DiffLine {
+	var diff []DiffLine
+	x, y := len(a), len(b)
+	for d := len(trace) - 1; d >= 0; d-- {
+		v := func(k int) int { return trace[d][k+d] }
+		k := x - y
+
+		prevK := k - 1
+		if k == -d || (k != d && v(k-1) < v(k+1)) {
+			prevK = k + 1
+		}
+		prevX, prevY := 0, 0
+		if d > 0 {
+			prevX = v(prevK)
+			prevY = prevX - prevK
+		}
+
+		for x > prevX && y > prevY {
+			diff = append(diff, DiffLine{Op: DiffEqual, Text: a[x-1]})
+			x--
+			y--
+		}
+		if d > 0 {
+			if x == prevX {
+				diff = append(diff, DiffLine{Op: DiffInsert, Text: b[y-1]})
+			} else {
+				diff = append(diff, DiffLine{Op: DiffDelete, Text: a[x-1]})
+			}
+		}
+		x, y = prevX, prevY
+	}
+
+	slices.Reverse(diff)
+	return diff
+}
+
+func replaceAll(a, b []string) []DiffLine {
+	diff := make([]DiffLine, 0, len(a)+len(b))
+	for _, l := range a {
+		diff = append(diff, DiffLine{Op: DiffDelete, Text: l})
+	}
+	for _, l := range b {
+		diff = append(diff, DiffLine{Op: DiffInsert, Text: l})
+	}
+	return diff
+}
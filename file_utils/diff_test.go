@@ -0,0 +1,71 @@
+package fileutils
+
+import (
+	"math/rand"
+	"slices"
+	"testing"
+)
+
+func TestDiffLines(t *testing.T) {
+	a := []string{"a", "b", "c", "a", "b", "b", "a"}
+	b := []string{"c", "b", "a", "b", "a", "c"}
+
+	diff := DiffLines(a, b)
+	changes := 0
+	for _, l := range diff {
+		if l.Op != DiffEqual {
+			changes++
+		}
+	}
+	// the example of the Myers paper, the shortest edit script has 5 changes
+	if changes != 5 {
+		t.Errorf("expected 5 changes, got %d: %+v", changes, diff)
+	}
+	checkDiff(t, a, b, diff)
+}
+
+func TestDiffLinesRandom(t *testing.T) {
+	r := rand.New(rand.NewSource(1))
+	randomLines := func() []string {
+		lines := make([]string, r.Intn(30))
+		for i := range lines {
+			lines[i] = string(rune('a' + r.Intn(4)))
+		}
+		return lines
+	}
+
+	for range 500 {
+		a, b := randomLines(), randomLines()
+		checkDiff(t, a, b, DiffLines(a, b))
+	}
+}
+
+func TestDiffStat(t *testing.T) {
+	added, removed := DiffStat("one\ntwo\nthree\n", "one\n2\nthree\nfour\n")
+	if added != 2 || removed != 1 {
+		t.Errorf("expected +2 -1, got +%d -%d", added, removed)
+	}
+
+	added, removed = DiffStat("", "new\nfile")
+	if added != 2 || removed != 0 {
+		t.Errorf("expected +2 -0, got +%d -%d", added, removed)
+	}
+}
+
+// checkDiff checks that the diff turns a into b.
+func checkDiff(t *testing.T, a, b []string, diff []DiffLine) {
+	t.Helper()
+
+	var gotA, gotB []string
+	for _, l := range diff {
+		if l.Op != DiffInsert {
+			gotA = append(gotA, l.Text)
+		}
+		if l.Op != DiffDelete {
+			gotB = append(gotB, l.Text)
+		}
+	}
+	if !slices.Equal(gotA, a) || !slices.Equal(gotB, b) {
+		t.Errorf("diff %+v does not turn %v into %v", diff, a, b)
+	}
+}
@@ -53,40 +53,38 @@ func CreateTxtFilename(directory string, iteration int, name string, suffix stri
 	)
 }
 
-func SaveFilesFromJson(outputDir string, jsonData []byte) error {
-	files, err := createFilesFromJson(jsonData)
-	if err != nil {
-		return err
+// SaveFilesFromJson writes the files of a FileList JSON to outputDir, see SaveFiles.
+func SaveFilesFromJson(outputDir string, jsonData []byte, limits SaveLimits) ([]SkippedFile, error) {
+	var fileList FileList
+	if err := json.Unmarshal(jsonData, &fileList); err != nil {
+		return nil, err
 	}
 
-	for name, content := range files {
-		fullPath := filepath.Join(outputDir, name)
-
-		err := os.MkdirAll(filepath.Dir(fullPath), 0755)
-		if err != nil {
-			return err
-		}
+	return SaveFiles(outputDir, fileList, limits)
+}
 
-		err = os.WriteFile(fullPath, []byte(content), 0666)
-		if err != nil {
-			return err
+// SaveFiles writes the files to outputDir, file names are relative to it. File names come
+// from models and are not trusted: absolute names and backslashes are rewritten, entries
+// leaving outputDir (also through symlinks) or over the limits are not written and are
+// returned as skipped.
+func SaveFiles(outputDir string, fileList FileList, limits SaveLimits) ([]SkippedFile, error) {
+	safe, skipped := SafeFiles(outputDir, fileList, limits)
+	for _, file := range safe.Files {
+		if err := writeFile(outputDir, file); err != nil {
+			return skipped, err
 		}
 	}
 
-	return nil
+	return skipped, nil
 }
 
-func createFilesFromJson(jsonData []byte) (map[string]string, error) {
-	output := map[string]string{}
+func writeFile(outputDir string, file File) error {
+	fullPath := filepath.Join(outputDir, file.FileName)
 
-	var fileList FileList
-	if err := json.Unmarshal(jsonData, &fileList); err != nil {
-		return map[string]string{}, err
-	}
-
-	for _, file := range fileList.Files {
-		output[file.FileName] = file.FileContent
+	err := os.MkdirAll(filepath.Dir(fullPath), 0755)
+	if err != nil {
+		return err
 	}
 
-	return output, nil
+	return os.WriteFile(fullPath, []byte(file.FileContent), 0666)
 }
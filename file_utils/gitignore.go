@@ -0,0 +1,144 @@
+package fileutils
+
+import (
+	"bufio"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// ignorePattern is a single line of a .gitignore file.
+type ignorePattern struct {
+	// base is the slash separated directory of the .gitignore file, "" for the root.
+	base    string
+	re      *regexp.Regexp
+	negate  bool
+	dirOnly bool
+}
+
+// IgnoreRules matches paths against .gitignore style patterns. Later patterns win, so
+// rules of nested .gitignore files must be added after the ones of their parents.
+type IgnoreRules struct {
+	patterns []ignorePattern
+}
+
+// Add parses patterns in the .gitignore syntax. base is the slash separated directory
+// the patterns are relative to, "" for the root.
+func (r *IgnoreRules) Add(base string, patterns io.Reader) error {
+	scanner := bufio.NewScanner(patterns)
+	for scanner.Scan() {
+		if p, ok := parseIgnorePattern(base, scanner.Text()); ok {
+			r.patterns = append(r.patterns, p)
+		}
+	}
+	return scanner.Err()
+}
+
+// AddPatterns is Add for patterns given one per string.
+func (r *IgnoreRules) AddPatterns(base string, patterns []string) {
+	for _, line := range patterns {
+		if p, ok := parseIgnorePattern(base, line); ok {
+			r.patterns = append(r.patterns, p)
+		}
+	}
+}
+
+// Match tells whether the slash separated path relative to the root is ignored.
+func (r *IgnoreRules) Match(path string, isDir bool) bool {
+	ignored := false
+	for _, p := range r.patterns {
+		if p.dirOnly && !isDir {
+			continue
+		}
+
+		rel := path
+		if p.base != "" {
+			if !strings.HasPrefix(path, p.base+"/") {
+				continue
+			}
+			rel = strings.TrimPrefix(path, p.base+"/")
+		}
+
+		if p.re.MatchString(rel) {
+			ignored = !p.negate
+		}
+	}
+	return ignored
+}
+
+func parseIgnorePattern(base string, line string) (ignorePattern, bool) {
+	line = strings.TrimRight(strings.TrimSuffix(line, "\r"), " ")
+	if line == "" || strings.HasPrefix(line, "#") {
+		return ignorePattern{}, false
+	}
+
+	p := ignorePattern{base: base}
+	if strings.HasPrefix(line, "!") {
+		p.negate = true
+		line = line[1:]
+	} else if strings.HasPrefix(line, `\`) {
+		line = line[1:]
+	}
+
+	if strings.HasSuffix(line, "/") {
+		p.dirOnly = true
+		line = strings.TrimSuffix(line, "/")
+	}
+	if line == "" {
+		return ignorePattern{}, false
+	}
+
+	// a slash at the beginning or in the middle anchors the pattern to its directory,
+	// otherwise it matches at any level
+	anchored := strings.Contains(line, "/")
+	line = strings.TrimPrefix(line, "/")
+
+	expr := globToRegexp(line)
+	if !anchored {
+		expr = "(.*/)?" + expr
+	}
+	p.re = regexp.MustCompile("^" + expr + "$")
+	return p, true
+}
+
+// globToRegexp converts a glob with `**`, `*`, `?` and character classes to a regular
+// expression.
+func globToRegexp(glob string) string {
+	var builder strings.Builder
+	for i := 0; i < len(glob); i++ {
+		c := glob[i]
+		switch {
+		case strings.HasPrefix(glob[i:], "**/"):
+			builder.WriteString("(.*/)?")
+			i += 2
+		case strings.HasPrefix(glob[i:], "/**") && i+3 == len(glob):
+			builder.WriteString("/.*")
+			i += 2
+		case strings.HasPrefix(glob[i:], "**"):
+			builder.WriteString(".*")
+			i++
+		case c == '*':
+			builder.WriteString("[^/]*")
+		case c == '?':
+			builder.WriteString("[^/]")
+		case c == '[':
+			end := strings.IndexByte(glob[i+1:], ']')
+			if end < 0 {
+				builder.WriteString(`\[`)
+				continue
+			}
+			class := glob[i+1 : i+1+end]
+			if strings.HasPrefix(class, "!") {
+				class = "^" + class[1:]
+			}
+			builder.WriteString("[" + strings.ReplaceAll(class, `\`, `\\`) + "]")
+			i += end + 1
+		case c == '\\' && i+1 < len(glob):
+			i++
+			builder.WriteString(regexp.QuoteMeta(string(glob[i])))
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	return builder.String()
+}
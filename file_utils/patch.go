@@ -0,0 +1,175 @@
+package fileutils
+
+import (
+	"encoding/json"
+	"fmt"
+	"strings"
+)
+
+// EditAction tells what a FileEdit does with its file.
+type EditAction string
+
+const (
+	// EditModify applies the hunks to an existing file.
+	EditModify EditAction = "modify"
+	// EditWrite creates the file or replaces its whole content with FileContent.
+	EditWrite EditAction = "write"
+	// EditDelete removes the file.
+	EditDelete EditAction = "delete"
+)
+
+// Hunk replaces the only occurrence of Search with Replace.
+type Hunk struct {
+	Search  string `json:"search"`
+	Replace string `json:"replace"`
+}
+
+// FileEdit is a change of a single file. FileContent is used by EditWrite, Hunks by
+// EditModify.
+type FileEdit struct {
+	FileName    string     `json:"fileName"`
+	Action      EditAction `json:"action"`
+	FileContent string     `json:"fileContent"`
+	Hunks       []Hunk     `json:"hunks"`
+}
+
+// EditList is a set of changes of a FileList.
+type EditList struct {
+	Edits []FileEdit `json:"edits"`
+}
+
+// FailedEdit is an edit, or a single hunk of it, that could not be applied. Hunk is the
+// index of the hunk, -1 when the whole edit failed.
+type FailedEdit struct {
+	FileName string `json:"fileName"`
+	Hunk     int    `json:"hunk"`
+	Reason   string `json:"reason"`
+}
+
+func (f FailedEdit) String() string {
+	if f.FileName == "" && f.Hunk < 0 {
+		return f.Reason
+	}
+	if f.Hunk < 0 {
+		return fmt.Sprintf("%s: %s", f.FileName, f.Reason)
+	}
+	return fmt.Sprintf("%s, hunk %d: %s", f.FileName, f.Hunk, f.Reason)
+}
+
+// ApplyEdits returns the files with the edits applied in order. Failed edits and hunks
+// are skipped, the remaining ones are still applied. The order of the files is kept,
+// new files are appended.
+func ApplyEdits(fileList FileList, edits EditList) (FileList, []FailedEdit) {
+	files := make([]File, len(fileList.Files))
+	copy(files, fileList.Files)
+
+	index := func(name string) int {
+		for i, f := range files {
+			if f.FileName == name {
+				return i
+			}
+		}
+		return -1
+	}
+
+	var failed []FailedEdit
+	for _, edit := range edits.Edits {
+		fail := func(hunk int, reason string) {
+			failed = append(failed, FailedEdit{FileName: edit.FileName, Hunk: hunk, Reason: reason})
+		}
+
+		if edit.FileName == "" {
+			fail(-1, "empty file name")
+			continue
+		}
+
+		i := index(edit.FileName)
+		switch edit.Action {
+		case EditWrite:
+			if i < 0 {
+				files = append(files, File{FileName: edit.FileName, FileContent: edit.FileContent})
+			} else {
+				files[i].FileContent = edit.FileContent
+			}
+		case EditDelete:
+			if i < 0 {
+				fail(-1, "file does not exist")
+				continue
+			}
+			files = append(files[:i], files[i+1:]...)
+		case EditModify:
+			if i < 0 {
+				fail(-1, "file does not exist, use the write action to create it")
+				continue
+			}
+			for h, hunk := range edit.Hunks {
+				content, err := applyHunk(files[i].FileContent, hunk)
+				if err != "" {
+					fail(h, err)
+					continue
+				}
+				files[i].FileContent = content
+			}
+		default:
+			fail(-1, fmt.Sprintf("unknown action %q", edit.Action))
+		}
+	}
+
+	return FileList{Files: files}, failed
+}
+
+// applyHunk returns the content with the hunk applied, or the reason it cannot be.
+func applyHunk(content string, hunk Hunk) (string, string) {
+	if hunk.Search == "" {
+		return content, "empty search text"
+	}
+
+	switch n := strings.Count(content, hunk.Search); n {
+	case 0:
+		return content, "search text not found"
+	case 1:
+		return strings.Replace(content, hunk.Search, hunk.Replace, 1), ""
+	default:
+		return content, fmt.Sprintf("search text found %d times, it has to be unique", n)
+	}
+}
+
+// ApplyEditsJson applies the edits of an EditList JSON to the files of a FileList JSON
+// and returns the result as a FileList JSON. Edits that cannot be parsed fail as a
+// whole and the files are returned unchanged.
+func ApplyEditsJson(filesJson string, editsJson string) (string, []FailedEdit, error) {
+	var fileList FileList
+	if err := json.Unmarshal([]byte(filesJson), &fileList); err != nil {
+		return "", nil, fmt.Errorf("cannot parse files: %w", err)
+	}
+
+	var failed []FailedEdit
+	var edits EditList
+	if err := json.Unmarshal([]byte(editsJson), &edits); err != nil {
+		failed = append(failed, FailedEdit{Hunk: -1, Reason: fmt.Sprintf("cannot parse edits: %s", err)})
+	} else {
+		fileList, failed = ApplyEdits(fileList, edits)
+	}
+
+	result, err := MarshalFileList(fileList)
+	if err != nil {
+		return "", nil, err
+	}
+	return result, failed, nil
+}
+
+// MarshalFileList encodes the files as JSON without escaping HTML characters, the JSON
+// ends up in prompts.
+func MarshalFileList(fileList FileList) (string, error) {
+	if fileList.Files == nil {
+		fileList.Files = []File{}
+	}
+
+	var builder strings.Builder
+	encoder := json.NewEncoder(&builder)
+	encoder.SetEscapeHTML(false)
+	if err := encoder.Encode(fileList); err != nil {
+		return "", fmt.Errorf("error encoding files: %w", err)
+	}
+	return strings.TrimSuffix(builder.String(), "\n"), nil
+}
@@ -0,0 +1,90 @@
+package fileutils
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestApplyEdits(t *testing.T) {
+	files := FileList{Files: []File{
+		{FileName: "a.go", FileContent: "one\ntwo\nthree\ntwo\n"},
+		{FileName: "b.go", FileContent: "b"},
+		{FileName: "c.go", FileContent: "c"},
+	}}
+
+	result, failed := ApplyEdits(files, EditList{Edits: []FileEdit{
+		{FileName: "a.go", Action: EditModify, Hunks: []Hunk{
+			{Search: "one\n", Replace: "1\n"},
+			{Search: "two\n", Replace: "2\n"},
+			{Search: "three", Replace: "3"},
+			{Search: "", Replace: "x"},
+		}},
+		{FileName: "b.go", Action: EditDelete},
+		{FileName: "c.go", Action: EditWrite, FileContent: "C"},
+		{FileName: "d.go", Action: EditWrite, FileContent: "d"},
+		{FileName: "e.go", Action: EditModify, Hunks: []Hunk{{Search: "e", Replace: "E"}}},
+		{FileName: "e.go", Action: EditDelete},
+		{FileName: "f.go", Action: "rename"},
+	}})
+
+	expected := []File{
+		{FileName: "a.go", FileContent: "1\ntwo\n3\ntwo\n"},
+		{FileName: "c.go", FileContent: "C"},
+		{FileName: "d.go", FileContent: "d"},
+	}
+	if len(result.Files) != len(expected) {
+		t.Fatalf("expected files %+v, got %+v", expected, result.Files)
+	}
+	for i := range expected {
+		if result.Files[i] != expected[i] {
+			t.Errorf("expected file %+v, got %+v", expected[i], result.Files[i])
+		}
+	}
+
+	expectedFailed := []string{
+		"a.go, hunk 1: search text found 2 times, it has to be unique",
+		"a.go, hunk 3: empty search text",
+		"e.go: file does not exist, use the write action to create it",
+		"e.go: file does not exist",
+		`f.go: unknown action "rename"`,
+	}
+	if len(failed) != len(expectedFailed) {
+		t.Fatalf("expected failed edits %v, got %v", expectedFailed, failed)
+	}
+	for i := range expectedFailed {
+		if failed[i].String() != expectedFailed[i] {
+			t.Errorf("expected failed edit %q, got %q", expectedFailed[i], failed[i])
+		}
+	}
+
+	if files.Files[1].FileName != "b.go" || files.Files[0].FileContent != "one\ntwo\nthree\ntwo\n" {
+		t.Errorf("expected the original files to be unchanged, got %+v", files.Files)
+	}
+}
+
+func TestApplyEditsJson(t *testing.T) {
+	files := `{"files": [{"fileName": "a.html", "fileContent": "<p>a</p>"}]}`
+
+	result, failed, err := ApplyEditsJson(files, `{"edits": [{"fileName": "a.html", "action": "modify", "hunks": [{"search": "a", "replace": "b"}]}]}`)
+	if err != nil || len(failed) != 0 {
+		t.Fatalf("unexpected error: %v %v", err, failed)
+	}
+	if result != `{"files":[{"fileName":"a.html","fileContent":"<p>b</p>"}]}` {
+		t.Errorf("unexpected result %s", result)
+	}
+
+	result, failed, err = ApplyEditsJson(files, `not json`)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(failed) != 1 || !strings.HasPrefix(failed[0].Reason, "cannot parse edits") {
+		t.Errorf("expected unparsable edits to fail, got %v", failed)
+	}
+	if result != `{"files":[{"fileName":"a.html","fileContent":"<p>a</p>"}]}` {
+		t.Errorf("expected unchanged files, got %s", result)
+	}
+
+	if _, _, err := ApplyEditsJson("not json", `{"edits": []}`); err == nil {
+		t.Errorf("expected an error for unparsable files")
+	}
+}
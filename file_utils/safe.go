@@ -0,0 +1,117 @@
+package fileutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+)
+
+// Defaults of SaveLimits.
+const (
+	DefaultMaxSavedFiles    = 1000
+	DefaultMaxSavedFileSize = 1024 * 1024
+)
+
+// SaveLimits bound the files written by SaveFiles. Zero values use the defaults.
+type SaveLimits struct {
+	MaxFiles    int   `yaml:"maxFiles"`
+	MaxFileSize int64 `yaml:"maxFileSize"`
+}
+
+func (l SaveLimits) withDefaults() SaveLimits {
+	if l.MaxFiles <= 0 {
+		l.MaxFiles = DefaultMaxSavedFiles
+	}
+	if l.MaxFileSize <= 0 {
+		l.MaxFileSize = DefaultMaxSavedFileSize
+	}
+	return l
+}
+
+// SafeFiles returns the files that can be written to dir with local file names within the
+// limits, and the skipped ones. dir is empty for files that are not written to disk, e.g.
+// committed to git, then symlinks are not checked.
+func SafeFiles(dir string, fileList FileList, limits SaveLimits) (FileList, []SkippedFile) {
+	limits = limits.withDefaults()
+
+	var safe FileList
+	var skipped []SkippedFile
+	for _, file := range fileList.Files {
+		reject := func(reason string) {
+			skipped = append(skipped, SkippedFile{FileName: file.FileName, Reason: reason})
+		}
+
+		name, err := SafeFileName(file.FileName)
+		if err != nil {
+			reject(err.Error())
+			continue
+		}
+		if len(safe.Files) >= limits.MaxFiles {
+			reject(fmt.Sprintf("more than %d files", limits.MaxFiles))
+			continue
+		}
+		if int64(len(file.FileContent)) > limits.MaxFileSize {
+			reject(fmt.Sprintf("larger than %d bytes", limits.MaxFileSize))
+			continue
+		}
+		if dir != "" {
+			if err := checkNoSymlinks(dir, name); err != nil {
+				reject(err.Error())
+				continue
+			}
+		}
+
+		safe.Files = append(safe.Files, File{FileName: name, FileContent: file.FileContent})
+	}
+	return safe, skipped
+}
+
+// SafeFileName returns the file name as a local path. Backslashes are taken as
+// separators and absolute names are made relative, names leaving the directory or
+// going into a .git directory are rejected.
+func SafeFileName(name string) (string, error) {
+	if strings.ContainsRune(name, 0) {
+		return "", errors.New("file name contains a NUL byte")
+	}
+
+	name = strings.ReplaceAll(name, `\`, "/")
+	name = path.Clean(strings.TrimLeft(name, "/"))
+	if name == "." {
+		return "", errors.New("empty file name")
+	}
+	if !filepath.IsLocal(name) {
+		return "", errors.New("file name leaves the output directory")
+	}
+	// files in .git, e.g. hooks, would run on the next git command in the directory
+	for _, element := range strings.Split(name, "/") {
+		if strings.EqualFold(element, ".git") {
+			return "", errors.New("file name goes into a .git directory")
+		}
+	}
+
+	return filepath.FromSlash(name), nil
+}
+
+// checkNoSymlinks fails when an existing element of the local path name inside dir is a
+// symlink, writing through it could leave dir.
+func checkNoSymlinks(dir string, name string) error {
+	current := dir
+	for _, element := range strings.Split(name, string(filepath.Separator)) {
+		current = filepath.Join(current, element)
+
+		info, err := os.Lstat(current)
+		if os.IsNotExist(err) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		if info.Mode()&os.ModeSymlink != 0 {
+			return errors.New("file name goes through a symlink")
+		}
+	}
+	return nil
+}
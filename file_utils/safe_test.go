@@ -0,0 +1,123 @@
+package fileutils
+
+import (
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSaveFiles(t *testing.T) {
+	root := t.TempDir()
+	outputDir := filepath.Join(root, "out")
+	if err := os.Mkdir(outputDir, 0o755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := os.Symlink(root, filepath.Join(outputDir, "link")); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	skipped, err := SaveFiles(outputDir, FileList{Files: []File{
+		{FileName: "src/main.py", FileContent: "main"},
+		{FileName: "/abs/file.txt", FileContent: "abs"},
+		{FileName: `win\path.txt`, FileContent: "win"},
+		{FileName: "../../.bashrc", FileContent: "evil"},
+		{FileName: "a/../../escape.txt", FileContent: "evil"},
+		{FileName: "link/escape.txt", FileContent: "evil"},
+		{FileName: "", FileContent: "empty"},
+		{FileName: "sub/.git/hooks/pre-commit", FileContent: "evil"},
+		{FileName: "big.txt", FileContent: "0123456789"},
+		{FileName: "third.txt", FileContent: "3"},
+		{FileName: "fourth.txt", FileContent: "4"},
+	}}, SaveLimits{MaxFiles: 4, MaxFileSize: 9})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for name, content := range map[string]string{
+		"src/main.py":   "main",
+		"abs/file.txt":  "abs",
+		"win/path.txt":  "win",
+		"third.txt":     "3",
+		"../escape.txt": "",
+		"../.bashrc":    "",
+		"fourth.txt":    "",
+	} {
+		data, err := os.ReadFile(filepath.Join(outputDir, name))
+		if content == "" {
+			if err == nil {
+				t.Errorf("expected %s not to be written", name)
+			}
+			continue
+		}
+		if err != nil || string(data) != content {
+			t.Errorf("expected %s with %q, got %q (%v)", name, content, data, err)
+		}
+	}
+
+	expected := map[string]string{
+		"../../.bashrc":             "file name leaves the output directory",
+		"a/../../escape.txt":        "file name leaves the output directory",
+		"link/escape.txt":           "file name goes through a symlink",
+		"":                          "empty file name",
+		"sub/.git/hooks/pre-commit": "file name goes into a .git directory",
+		"big.txt":                   "larger than 9 bytes",
+		"fourth.txt":                "more than 4 files",
+	}
+	if len(skipped) != len(expected) {
+		t.Errorf("expected %d skipped files, got %v", len(expected), skipped)
+	}
+	for _, s := range skipped {
+		if expected[s.FileName] != s.Reason {
+			t.Errorf("expected %q skipped as %q, got %q", s.FileName, expected[s.FileName], s.Reason)
+		}
+	}
+}
+
+func FuzzSafeFileName(f *testing.F) {
+	for _, name := range []string{"main.go", "../x", "/etc/passwd", `..\..\x`, "a/./b/../c", ".", "a\x00b"} {
+		f.Add(name)
+	}
+
+	f.Fuzz(func(t *testing.T, name string) {
+		safe, err := SafeFileName(name)
+		if err != nil {
+			return
+		}
+		if !filepath.IsLocal(safe) {
+			t.Errorf("SafeFileName(%q) = %q, which is not local", name, safe)
+		}
+	})
+}
+
+func FuzzSaveFiles(f *testing.F) {
+	for _, name := range []string{"main.go", "../x", "/etc/passwd", `..\x`, "a/../../x", "dir/", "a//b"} {
+		f.Add(name, "content")
+	}
+
+	f.Fuzz(func(t *testing.T, name string, content string) {
+		root := t.TempDir()
+		outputDir := filepath.Join(root, "out")
+		if err := os.Mkdir(outputDir, 0o755); err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+
+		// errors, e.g. names too long for the file system, are fine as long as nothing
+		// is written outside the output directory
+		_, _ = SaveFiles(outputDir, FileList{Files: []File{{FileName: name, FileContent: content}}}, SaveLimits{})
+
+		err := filepath.WalkDir(root, func(path string, d fs.DirEntry, err error) error {
+			if err != nil {
+				return err
+			}
+			if path != root && path != outputDir && !strings.HasPrefix(path, outputDir+string(filepath.Separator)) {
+				t.Errorf("file name %q wrote %s outside the output directory", name, path)
+			}
+			return nil
+		})
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+	})
+}
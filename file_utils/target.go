@@ -0,0 +1,159 @@
+package fileutils
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// WritePolicy decides what happens to existing files of a target directory.
+type WritePolicy string
+
+const (
+	// PolicyFailIfExists writes nothing when a file exists with a different content. It
+	// is the default.
+	PolicyFailIfExists WritePolicy = "fail-if-exists"
+	// PolicyOverwrite replaces existing files.
+	PolicyOverwrite WritePolicy = "overwrite"
+	// PolicyBackup replaces existing files after copying them to the backup directory.
+	PolicyBackup WritePolicy = "backup"
+	// PolicyMergeNewOnly writes only the files that do not exist yet.
+	PolicyMergeNewOnly WritePolicy = "merge-new-only"
+)
+
+// ChangeStatus tells what writing a file does to the target directory.
+type ChangeStatus string
+
+const (
+	ChangeNew       ChangeStatus = "new"
+	ChangeModified  ChangeStatus = "modified"
+	ChangeUnchanged ChangeStatus = "unchanged"
+	// ChangeKept is an existing file left as it is by PolicyMergeNewOnly.
+	ChangeKept ChangeStatus = "kept"
+)
+
+// FileChange describes a file of a target directory, Added and Removed count the lines
+// changed by writing it.
+type FileChange struct {
+	FileName string
+	Status   ChangeStatus
+	Added    int
+	Removed  int
+}
+
+// TargetChanges lists the changes of a target directory in the order of the files.
+type TargetChanges []FileChange
+
+// Summary formats the changes, one file per line followed by the totals.
+func (c TargetChanges) Summary() string {
+	var builder strings.Builder
+	counts := map[ChangeStatus]int{}
+	for _, fc := range c {
+		counts[fc.Status]++
+		fmt.Fprintf(&builder, "%-10s %s", fc.Status, fc.FileName)
+		if fc.Status == ChangeNew || fc.Status == ChangeModified {
+			fmt.Fprintf(&builder, " (+%d -%d)", fc.Added, fc.Removed)
+		}
+		builder.WriteString("\n")
+	}
+	fmt.Fprintf(&builder, "%d new, %d modified, %d unchanged, %d kept\n",
+		counts[ChangeNew], counts[ChangeModified], counts[ChangeUnchanged], counts[ChangeKept])
+	return builder.String()
+}
+
+// TargetPlan holds the changes writing files to a target directory would make.
+type TargetPlan struct {
+	Dir     string
+	Policy  WritePolicy
+	Changes TargetChanges
+	// Skipped lists the unsafe files, see SaveFiles, they are not part of the plan.
+	Skipped []SkippedFile
+	// files are the safe files, in Changes order
+	files []File
+}
+
+// PlanTarget compares the files with the target directory without writing anything.
+func PlanTarget(dir string, fileList FileList, policy WritePolicy, limits SaveLimits) (*TargetPlan, error) {
+	switch policy {
+	case "":
+		policy = PolicyFailIfExists
+	case PolicyFailIfExists, PolicyOverwrite, PolicyBackup, PolicyMergeNewOnly:
+	default:
+		return nil, fmt.Errorf("unknown write policy %q", policy)
+	}
+
+	safe, skipped := SafeFiles(dir, fileList, limits)
+	plan := &TargetPlan{Dir: dir, Policy: policy, Skipped: skipped, files: safe.Files}
+	for _, file := range safe.Files {
+		old, err := os.ReadFile(filepath.Join(dir, file.FileName))
+		if errors.Is(err, os.ErrNotExist) {
+			added, _ := DiffStat("", file.FileContent)
+			plan.Changes = append(plan.Changes, FileChange{FileName: file.FileName, Status: ChangeNew, Added: added})
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		change := FileChange{FileName: file.FileName, Status: ChangeModified}
+		switch {
+		case string(old) == file.FileContent:
+			change.Status = ChangeUnchanged
+		case policy == PolicyMergeNewOnly:
+			change.Status = ChangeKept
+		default:
+			change.Added, change.Removed = DiffStat(string(old), file.FileContent)
+		}
+		plan.Changes = append(plan.Changes, change)
+	}
+	return plan, nil
+}
+
+// Write applies the plan following its policy. With PolicyFailIfExists nothing is
+// written when a file would be modified. backupDir is used by PolicyBackup.
+func (p *TargetPlan) Write(backupDir string) error {
+	switch p.Policy {
+	case PolicyFailIfExists:
+		var existing []string
+		for _, fc := range p.Changes {
+			if fc.Status == ChangeModified {
+				existing = append(existing, fc.FileName)
+			}
+		}
+		if len(existing) > 0 {
+			return fmt.Errorf("files already exist in %s: %s", p.Dir, strings.Join(existing, ", "))
+		}
+	case PolicyBackup:
+		if backupDir == "" {
+			return errors.New("no backup directory")
+		}
+	}
+
+	for i, file := range p.files {
+		switch p.Changes[i].Status {
+		case ChangeUnchanged, ChangeKept:
+			continue
+		case ChangeModified:
+			if p.Policy == PolicyBackup {
+				if err := backupFile(p.Dir, backupDir, file.FileName); err != nil {
+					return fmt.Errorf("cannot back up %s: %w", file.FileName, err)
+				}
+			}
+		}
+
+		if err := writeFile(p.Dir, file); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func backupFile(dir string, backupDir string, name string) error {
+	content, err := os.ReadFile(filepath.Join(dir, name))
+	if err != nil {
+		return err
+	}
+	return writeFile(backupDir, File{FileName: name, FileContent: string(content)})
+}
@@ -0,0 +1,98 @@
+package fileutils
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestWriteTarget(t *testing.T) {
+	files := FileList{Files: []File{
+		{FileName: "new.txt", FileContent: "new\n"},
+		{FileName: "changed.txt", FileContent: "one\n2\n"},
+		{FileName: "same.txt", FileContent: "same\n"},
+		{FileName: "../escape.txt", FileContent: "evil"},
+	}}
+
+	tests := []struct {
+		policy   WritePolicy
+		fails    bool
+		expected map[string]string
+	}{
+		{PolicyFailIfExists, true, map[string]string{"new.txt": "", "changed.txt": "one\ntwo\n"}},
+		{PolicyOverwrite, false, map[string]string{"new.txt": "new\n", "changed.txt": "one\n2\n"}},
+		{PolicyBackup, false, map[string]string{"new.txt": "new\n", "changed.txt": "one\n2\n"}},
+		{PolicyMergeNewOnly, false, map[string]string{"new.txt": "new\n", "changed.txt": "one\ntwo\n"}},
+	}
+	for _, tt := range tests {
+		t.Run(string(tt.policy), func(t *testing.T) {
+			dir := filepath.Join(t.TempDir(), "project")
+			backupDir := filepath.Join(t.TempDir(), "backup")
+			writeTestFiles(t, dir, map[string]string{"changed.txt": "one\ntwo\n", "same.txt": "same\n"})
+
+			plan, err := PlanTarget(dir, files, tt.policy, SaveLimits{})
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+			if len(plan.Skipped) != 1 || plan.Skipped[0].FileName != "../escape.txt" {
+				t.Errorf("expected the escaping file to be skipped, got %v", plan.Skipped)
+			}
+
+			err = plan.Write(backupDir)
+			if tt.fails != (err != nil) {
+				t.Fatalf("expected failure %v, got %v", tt.fails, err)
+			}
+
+			for name, content := range tt.expected {
+				data, err := os.ReadFile(filepath.Join(dir, name))
+				if content == "" {
+					if err == nil {
+						t.Errorf("expected %s not to be written", name)
+					}
+					continue
+				}
+				if string(data) != content {
+					t.Errorf("expected %s with %q, got %q (%v)", name, content, data, err)
+				}
+			}
+
+			backup, err := os.ReadFile(filepath.Join(backupDir, "changed.txt"))
+			if (tt.policy == PolicyBackup) != (err == nil) {
+				t.Errorf("unexpected backup %q (%v)", backup, err)
+			}
+			if tt.policy == PolicyBackup && string(backup) != "one\ntwo\n" {
+				t.Errorf("expected the original content in the backup, got %q", backup)
+			}
+		})
+	}
+}
+
+func TestPlanTargetSummary(t *testing.T) {
+	dir := t.TempDir()
+	writeTestFiles(t, dir, map[string]string{"changed.txt": "one\ntwo\n", "same.txt": "same\n"})
+
+	plan, err := PlanTarget(dir, FileList{Files: []File{
+		{FileName: "new.txt", FileContent: "a\nb\n"},
+		{FileName: "changed.txt", FileContent: "one\n2\nthree\n"},
+		{FileName: "same.txt", FileContent: "same\n"},
+	}}, PolicyOverwrite, SaveLimits{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := strings.Join([]string{
+		"new        new.txt (+2 -0)",
+		"modified   changed.txt (+2 -1)",
+		"unchanged  same.txt",
+		"1 new, 1 modified, 1 unchanged, 0 kept",
+		"",
+	}, "\n")
+	if summary := plan.Changes.Summary(); summary != expected {
+		t.Errorf("unexpected summary:\n%s\nexpected:\n%s", summary, expected)
+	}
+
+	if _, err := PlanTarget(dir, FileList{}, "replace", SaveLimits{}); err == nil {
+		t.Errorf("expected an error for an unknown policy")
+	}
+}
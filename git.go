@@ -0,0 +1,111 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+	gitutils "github.com/aszmajdzinski/llm-feedback-loop-executor/git_utils"
+	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
+	thinkingblock "github.com/aszmajdzinski/llm-feedback-loop-executor/thinking_block"
+)
+
+// defaultBranchPrefix starts the branch names of blocks without `git.branch`.
+const defaultBranchPrefix = "llm-loop/"
+
+// openBlockGit prepares committing the output files of a block, nil when the block has
+// no `git` section. The files are limited like the saved ones, an existing branch is
+// continued only when resuming.
+func openBlockGit(
+	ctx context.Context,
+	b Block,
+	limits fileutils.SaveLimits,
+	resume bool,
+) (*gitutils.Committer, error) {
+	if b.Git == nil {
+		return nil, nil
+	}
+	if err := checkBlockGit(b); err != nil {
+		return nil, err
+	}
+
+	repo := valueOrDefault(b.Git.Repo, b.Target.Dir)
+	branch := valueOrDefault(b.Git.Branch, defaultBranchPrefix+fileutils.ToKebabCase(b.Name))
+
+	return gitutils.Open(ctx, repo, branch, b.Git.Base, limits, resume)
+}
+
+// checkBlockGit reports a `git` section that cannot work with the rest of the block.
+func checkBlockGit(b Block) error {
+	if !b.FilesOutput {
+		return fmt.Errorf("git needs filesOutput")
+	}
+	if valueOrDefault(b.Git.Repo, b.Target.Dir) == "" {
+		return fmt.Errorf("git needs a repo or a target directory")
+	}
+	return nil
+}
+
+// commitFiles commits the files of a solution (a fileutils.FileList JSON).
+func commitFiles(ctx context.Context, committer *gitutils.Committer, solution string, message string) error {
+	logger := loggerutils.GetLogger(ctx)
+
+	var fileList fileutils.FileList
+	if err := json.Unmarshal([]byte(solution), &fileList); err != nil {
+		return fmt.Errorf("cannot parse files: %w", err)
+	}
+
+	commit, skipped, err := committer.Commit(ctx, fileList, message)
+	if err != nil {
+		return err
+	}
+	for _, s := range skipped {
+		logger.Warn("File not committed", "file", s.FileName, "reason", s.Reason)
+	}
+	if commit == "" {
+		logger.Info("Nothing to commit, the files did not change")
+		return nil
+	}
+	logger.Info("Committed files", "commit", commit, "subject", strings.SplitN(message, "\n", 2)[0])
+	return nil
+}
+
+// commitIterations returns onIteration committing every finished iteration after it.
+// Solutions that are not valid files JSON are only logged, they fail the block later.
+func commitIterations(
+	committer *gitutils.Committer,
+	blockName string,
+	onIteration func(context.Context, int, thinkingblock.Prompts, thinkingblock.PartialAnswer) error,
+) func(context.Context, int, thinkingblock.Prompts, thinkingblock.PartialAnswer) error {
+	return func(ctx context.Context, iteration int, prompts thinkingblock.Prompts, answer thinkingblock.PartialAnswer) error {
+		if err := onIteration(ctx, iteration, prompts, answer); err != nil {
+			return err
+		}
+
+		err := commitFiles(ctx, committer, answer.WorkerSolution, iterationCommitMessage(blockName, iteration, answer))
+		if err != nil {
+			loggerutils.GetLogger(ctx).Warn("Cannot commit iteration", "iteration", iteration, "error", err)
+		}
+		return nil
+	}
+}
+
+func iterationCommitMessage(blockName string, iteration int, answer thinkingblock.PartialAnswer) string {
+	verdict := "score " + formatScore(answer.OracleVerdict.Score)
+	if answer.OracleVerdict.Accept {
+		verdict += ", accepted"
+	}
+	return fmt.Sprintf("%s: iteration %d (%s)\n\n%s\n", blockName, iteration, verdict, answer.OracleSummary)
+}
+
+func finalCommitMessage(blockName string, output thinkingblock.ThinkingBlockOutput) string {
+	answer := output.PartAnswers[output.FinalIteration]
+	return fmt.Sprintf("%s: solution of iteration %d (%s, score %s)\n\n%s\n",
+		blockName, output.FinalIteration, output.StopReason, formatScore(answer.OracleVerdict.Score), answer.OracleSummary)
+}
+
+func formatScore(score float64) string {
+	return strings.TrimSuffix(fmt.Sprintf("%.1f", score), ".0")
+}
@@ -0,0 +1,54 @@
+package main
+
+import (
+	"context"
+	"testing"
+
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+	gitutils "github.com/aszmajdzinski/llm-feedback-loop-executor/git_utils"
+	thinkingblock "github.com/aszmajdzinski/llm-feedback-loop-executor/thinking_block"
+)
+
+func TestOpenBlockGit(t *testing.T) {
+	ctx := context.Background()
+
+	committer, err := openBlockGit(ctx, Block{Name: "a"}, fileutils.SaveLimits{}, false)
+	if committer != nil || err != nil {
+		t.Errorf("expected no committer without git, got %v (%v)", committer, err)
+	}
+
+	tests := map[string]Block{
+		"no files output": {Name: "a", Git: &gitutils.Setup{Repo: "."}},
+		"no repo":         {Name: "a", FilesOutput: true, Git: &gitutils.Setup{}},
+	}
+	for name, b := range tests {
+		t.Run(name, func(t *testing.T) {
+			if _, err := openBlockGit(ctx, b, fileutils.SaveLimits{}, false); err == nil {
+				t.Errorf("expected an error")
+			}
+		})
+	}
+}
+
+func TestCommitMessages(t *testing.T) {
+	answer := thinkingblock.PartialAnswer{
+		OracleSummary: "Good job\nMUST FIX:\n- tests",
+		OracleVerdict: thinkingblock.OracleVerdict{Score: 7.5},
+	}
+
+	expected := "docs: iteration 2 (score 7.5)\n\nGood job\nMUST FIX:\n- tests\n"
+	if msg := iterationCommitMessage("docs", 2, answer); msg != expected {
+		t.Errorf("unexpected iteration message %q", msg)
+	}
+
+	answer.OracleVerdict = thinkingblock.OracleVerdict{Accept: true, Score: 9}
+	output := thinkingblock.ThinkingBlockOutput{
+		PartAnswers:    []thinkingblock.PartialAnswer{{}, answer},
+		FinalIteration: 1,
+		StopReason:     thinkingblock.StopAccepted,
+	}
+	expected = "docs: solution of iteration 1 (accepted, score 9)\n\nGood job\nMUST FIX:\n- tests\n"
+	if msg := finalCommitMessage("docs", output); msg != expected {
+		t.Errorf("unexpected final message %q", msg)
+	}
+}
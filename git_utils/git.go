@@ -0,0 +1,263 @@
+package gitutils
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+)
+
+// defaultIdentity is used for commits when git has no user configured.
+var defaultIdentity = []string{
+	"GIT_AUTHOR_NAME=llm-feedback-loop-executor",
+	"GIT_AUTHOR_EMAIL=llm-feedback-loop-executor@localhost",
+	"GIT_COMMITTER_NAME=llm-feedback-loop-executor",
+	"GIT_COMMITTER_EMAIL=llm-feedback-loop-executor@localhost",
+}
+
+// Setup is the `git:` section of a block.
+type Setup struct {
+	// Repo is a directory of a local repository, the files are committed relative to it.
+	// It defaults to the target directory of the block.
+	Repo string `yaml:"repo"`
+	// Branch receives the commits, it is created from Base.
+	Branch string `yaml:"branch"`
+	// Base is the revision the branch starts from, HEAD by default.
+	Base string `yaml:"base"`
+	// Iterations commits the solution of every iteration, not only the final one.
+	Iterations bool `yaml:"iterations"`
+}
+
+// Committer commits files to a branch with git plumbing commands, so the working tree,
+// the index and the checked out branch of the repository are left untouched.
+type Committer struct {
+	dir    string
+	prefix string
+	branch string
+	// parent is the commit the next one is based on, empty in a repository without commits
+	parent string
+	// created tells whether the branch exists
+	created bool
+	env     []string
+	limits  fileutils.SaveLimits
+	// committed holds the paths of the last commit relative to dir, files missing in the
+	// next one are removed
+	committed []string
+	mu        sync.Mutex
+}
+
+// Open prepares committing to the branch of the repository containing dir. A new branch
+// starts from base (HEAD when empty); an existing branch is continued only when
+// allowExisting is set, e.g. when resuming a run. The committed files are limited like
+// the saved ones, see fileutils.SafeFiles.
+func Open(
+	ctx context.Context,
+	dir string,
+	branch string,
+	base string,
+	limits fileutils.SaveLimits,
+	allowExisting bool,
+) (*Committer, error) {
+	if _, err := exec.LookPath("git"); err != nil {
+		return nil, fmt.Errorf("git is needed to commit files: %w", err)
+	}
+	if branch == "" {
+		return nil, errors.New("no branch")
+	}
+
+	c := &Committer{dir: dir, branch: branch, env: os.Environ(), limits: limits}
+	if _, err := c.git(ctx, nil, "check-ref-format", "--branch", branch); err != nil {
+		return nil, fmt.Errorf("invalid branch name %s: %w", branch, err)
+	}
+
+	prefix, err := c.git(ctx, nil, "rev-parse", "--show-prefix")
+	if err != nil {
+		return nil, fmt.Errorf("%s is not in a git repository: %w", dir, err)
+	}
+	c.prefix = prefix
+
+	if _, err := c.git(ctx, nil, "var", "GIT_COMMITTER_IDENT"); err != nil {
+		c.env = append(c.env, defaultIdentity...)
+	}
+
+	tip, err := c.git(ctx, nil, "rev-parse", "--verify", "--quiet", "refs/heads/"+branch)
+	switch {
+	case err == nil && !allowExisting:
+		return nil, fmt.Errorf("branch %s already exists", branch)
+	case err == nil:
+		c.parent = tip
+		c.created = true
+		// files of the branch dropped by the next solution have to be removed as well
+		c.committed, err = c.branchFiles(ctx, valueOrDefault(base, "HEAD"), tip)
+		if err != nil {
+			return nil, fmt.Errorf("cannot list the files of branch %s: %w", branch, err)
+		}
+		return c, nil
+	}
+
+	if base == "" {
+		base = "HEAD"
+		// a repository without commits gets a root commit
+		if _, err := c.git(ctx, nil, "rev-parse", "--verify", "--quiet", "HEAD"); err != nil {
+			return c, nil
+		}
+	}
+	c.parent, err = c.git(ctx, nil, "rev-parse", "--verify", base+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("invalid base %s: %w", base, err)
+	}
+	return c, nil
+}
+
+// branchFiles returns the files added or modified on the branch since it left base,
+// relative to dir. The other files of the tree come from base and are kept.
+func (c *Committer) branchFiles(ctx context.Context, base string, tip string) ([]string, error) {
+	forkPoint, err := c.git(ctx, nil, "merge-base", base, tip)
+	if err != nil {
+		// the branch has nothing in common with base, e.g. it starts with a root commit
+		forkPoint = ""
+	}
+
+	args := []string{"ls-tree", "-r", "-z", "--name-only", tip}
+	if forkPoint != "" {
+		args = []string{"diff", "--name-only", "-z", "--no-renames", "--diff-filter=AM", "--relative", forkPoint, tip}
+	}
+	out, err := c.git(ctx, nil, args...)
+	if err != nil {
+		return nil, err
+	}
+
+	var files []string
+	for _, name := range strings.Split(out, "\x00") {
+		if name != "" {
+			files = append(files, name)
+		}
+	}
+	return files, nil
+}
+
+// Commit replaces the files committed so far with the given ones and commits them to
+// the branch. Unsafe file names and files over the limits are skipped, see
+// fileutils.SafeFiles. It returns the commit hash, empty when nothing changed.
+func (c *Committer) Commit(ctx context.Context, fileList fileutils.FileList, message string) (string, []fileutils.SkippedFile, error) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	tmpDir, err := os.MkdirTemp("", "git-index-*")
+	if err != nil {
+		return "", nil, err
+	}
+	defer os.RemoveAll(tmpDir)
+	// a separate index keeps the one of the working tree untouched
+	env := append(slices.Clone(c.env), "GIT_INDEX_FILE="+filepath.Join(tmpDir, "index"))
+
+	if c.parent != "" {
+		if _, err := c.gitEnv(ctx, env, nil, "read-tree", c.parent); err != nil {
+			return "", nil, err
+		}
+	}
+
+	safe, skipped := fileutils.SafeFiles("", fileList, c.limits)
+	var paths []string
+	var indexInfo strings.Builder
+	for _, file := range safe.Files {
+		path := filepath.ToSlash(file.FileName)
+
+		blob, err := c.gitEnv(ctx, env, strings.NewReader(file.FileContent), "hash-object", "-w", "--stdin")
+		if err != nil {
+			return "", skipped, err
+		}
+		// index info paths are relative to the top of the repository
+		fmt.Fprintf(&indexInfo, "100644 %s\t%s\x00", blob, c.prefix+path)
+		paths = append(paths, path)
+	}
+
+	// files of the previous commit missing now were removed, the paths are relative to dir
+	var removed strings.Builder
+	for _, path := range c.committed {
+		if !slices.Contains(paths, path) {
+			removed.WriteString(path + "\x00")
+		}
+	}
+
+	if removed.Len() > 0 {
+		_, err := c.gitEnv(ctx, env, strings.NewReader(removed.String()), "update-index", "--force-remove", "-z", "--stdin")
+		if err != nil {
+			return "", skipped, err
+		}
+	}
+	if _, err := c.gitEnv(ctx, env, strings.NewReader(indexInfo.String()), "update-index", "-z", "--index-info"); err != nil {
+		return "", skipped, err
+	}
+	tree, err := c.gitEnv(ctx, env, nil, "write-tree")
+	if err != nil {
+		return "", skipped, err
+	}
+	c.committed = paths
+
+	args := []string{"commit-tree", tree}
+	if c.parent != "" {
+		parentTree, err := c.git(ctx, nil, "rev-parse", c.parent+"^{tree}")
+		if err != nil {
+			return "", skipped, err
+		}
+		if parentTree == tree {
+			return "", skipped, nil
+		}
+		args = append(args, "-p", c.parent)
+	}
+	args = append(args, "-F", "-")
+	commit, err := c.git(ctx, strings.NewReader(message), args...)
+	if err != nil {
+		return "", skipped, err
+	}
+
+	// the old value makes the update fail when someone else moved the branch meanwhile,
+	// an empty one when the branch was created meanwhile
+	oldValue := ""
+	if c.created {
+		oldValue = c.parent
+	}
+	if _, err := c.git(ctx, nil, "update-ref", "refs/heads/"+c.branch, commit, oldValue); err != nil {
+		return "", skipped, err
+	}
+	c.parent = commit
+	c.created = true
+	return commit, skipped, nil
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
+
+func (c *Committer) git(ctx context.Context, stdin *strings.Reader, args ...string) (string, error) {
+	return c.gitEnv(ctx, c.env, stdin, args...)
+}
+
+func (c *Committer) gitEnv(ctx context.Context, env []string, stdin *strings.Reader, args ...string) (string, error) {
+	cmd := exec.CommandContext(ctx, "git", args...)
+	cmd.Dir = c.dir
+	cmd.Env = env
+	if stdin != nil {
+		cmd.Stdin = stdin
+	}
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+
+	if err := cmd.Run(); err != nil {
+		return "", fmt.Errorf("git %s: %w: %s", args[0], err, strings.TrimSpace(stderr.String()))
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
@@ -0,0 +1,182 @@
+package gitutils
+
+import (
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+)
+
+// newTestRepo returns a repository with a single commit of README.md.
+func newTestRepo(t *testing.T) string {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not installed")
+	}
+
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("readme\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	runGit(t, dir, "init", "-q", "-b", "main")
+	runGit(t, dir, "add", "README.md")
+	runGit(t, dir, "-c", "user.name=test", "-c", "user.email=test@localhost", "commit", "-q", "-m", "initial")
+	return dir
+}
+
+func runGit(t *testing.T, dir string, args ...string) string {
+	t.Helper()
+
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	out, err := cmd.CombinedOutput()
+	if err != nil {
+		t.Fatalf("git %v: %v: %s", args, err, out)
+	}
+	return strings.TrimSpace(string(out))
+}
+
+func TestCommitter(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	sub := filepath.Join(repo, "app")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c, err := Open(ctx, sub, "llm/test", "", fileutils.SaveLimits{}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	commit, skipped, err := c.Commit(ctx, fileutils.FileList{Files: []fileutils.File{
+		{FileName: "main.go", FileContent: "package main\n"},
+		{FileName: "old.go", FileContent: "package old\n"},
+		{FileName: "../escape.go", FileContent: "evil"},
+	}}, "Iteration 0\n\nFirst solution")
+	if err != nil || commit == "" {
+		t.Fatalf("unexpected commit %q: %v", commit, err)
+	}
+	if len(skipped) != 1 || skipped[0].FileName != "../escape.go" {
+		t.Errorf("expected the escaping file to be skipped, got %v", skipped)
+	}
+
+	commit, _, err = c.Commit(ctx, fileutils.FileList{Files: []fileutils.File{
+		{FileName: "main.go", FileContent: "package main\n\nfunc main() {}\n"},
+	}}, "Iteration 1")
+	if err != nil || commit == "" {
+		t.Fatalf("unexpected commit %q: %v", commit, err)
+	}
+
+	files := runGit(t, repo, "ls-tree", "-r", "--name-only", "llm/test")
+	if files != "README.md\napp/main.go" {
+		t.Errorf("unexpected files on the branch:\n%s", files)
+	}
+	if log := runGit(t, repo, "log", "--format=%s", "llm/test"); log != "Iteration 1\nIteration 0\ninitial" {
+		t.Errorf("unexpected log:\n%s", log)
+	}
+	if content := runGit(t, repo, "show", "llm/test:app/main.go"); content != "package main\n\nfunc main() {}" {
+		t.Errorf("unexpected content %q", content)
+	}
+
+	// the working tree, the index and the checked out branch are untouched
+	if status := runGit(t, repo, "status", "--porcelain"); status != "" {
+		t.Errorf("expected a clean working tree, got:\n%s", status)
+	}
+	if branch := runGit(t, repo, "branch", "--show-current"); branch != "main" {
+		t.Errorf("expected main to stay checked out, got %s", branch)
+	}
+
+	commit, _, err = c.Commit(ctx, fileutils.FileList{Files: []fileutils.File{
+		{FileName: "main.go", FileContent: "package main\n\nfunc main() {}\n"},
+	}}, "Unchanged")
+	if err != nil || commit != "" {
+		t.Errorf("expected no commit without changes, got %q: %v", commit, err)
+	}
+}
+
+func TestOpenExistingBranch(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	runGit(t, repo, "branch", "llm/existing")
+
+	if _, err := Open(ctx, repo, "llm/existing", "", fileutils.SaveLimits{}, false); err == nil {
+		t.Errorf("expected an error for an existing branch")
+	}
+	if _, err := Open(ctx, repo, "llm/existing", "", fileutils.SaveLimits{}, true); err != nil {
+		t.Errorf("unexpected error continuing an existing branch: %v", err)
+	}
+	if _, err := Open(ctx, repo, "llm/new", "missing", fileutils.SaveLimits{}, false); err == nil {
+		t.Errorf("expected an error for an unknown base")
+	}
+	if _, err := Open(ctx, t.TempDir(), "llm/new", "", fileutils.SaveLimits{}, false); err == nil {
+		t.Errorf("expected an error outside a repository")
+	}
+}
+
+func TestCommitterLimits(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+
+	c, err := Open(ctx, repo, "llm/limits", "", fileutils.SaveLimits{MaxFiles: 2, MaxFileSize: 8}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, skipped, err := c.Commit(ctx, fileutils.FileList{Files: []fileutils.File{
+		{FileName: "a.txt", FileContent: "a"},
+		{FileName: "big.txt", FileContent: "too big for the limit"},
+		{FileName: "b.txt", FileContent: "b"},
+		{FileName: "c.txt", FileContent: "c"},
+	}}, "Limited")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(skipped) != 2 || skipped[0].FileName != "big.txt" || skipped[1].FileName != "c.txt" {
+		t.Errorf("expected the big and the extra file to be skipped, got %v", skipped)
+	}
+	if files := runGit(t, repo, "ls-tree", "-r", "--name-only", "llm/limits"); files != "README.md\na.txt\nb.txt" {
+		t.Errorf("unexpected files on the branch:\n%s", files)
+	}
+}
+
+func TestCommitterResume(t *testing.T) {
+	repo := newTestRepo(t)
+	ctx := context.Background()
+	sub := filepath.Join(repo, "app")
+	if err := os.Mkdir(sub, 0o755); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	c, err := Open(ctx, sub, "llm/resume", "", fileutils.SaveLimits{}, false)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, _, err = c.Commit(ctx, fileutils.FileList{Files: []fileutils.File{
+		{FileName: "main.go", FileContent: "package main\n"},
+		{FileName: "old.go", FileContent: "package old\n"},
+	}}, "Iteration 0")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// a resumed run removes the files of the branch dropped by the next solution, the files
+	// of the base are kept
+	c, err = Open(ctx, sub, "llm/resume", "", fileutils.SaveLimits{}, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	_, _, err = c.Commit(ctx, fileutils.FileList{Files: []fileutils.File{
+		{FileName: "main.go", FileContent: "package main\n"},
+	}}, "Iteration 1")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if files := runGit(t, repo, "ls-tree", "-r", "--name-only", "llm/resume"); files != "README.md\napp/main.go" {
+		t.Errorf("unexpected files on the branch:\n%s", files)
+	}
+}
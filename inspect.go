@@ -0,0 +1,300 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/transcript"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+)
+
+// Statuses of a block in the summary of a run.
+const (
+	blockFinished    = "finished"
+	blockFailed      = "failed"
+	blockInterrupted = "interrupted"
+	blockCanceled    = "canceled"
+	blockNotRun      = "not run"
+)
+
+// runSummary is what inspect tells about a run, it is built from the transcript and the
+// usage report in the output directory.
+type runSummary struct {
+	Dir     string
+	Started time.Time
+	// Finished is zero when the run was interrupted.
+	Finished time.Time
+	// Resumes counts the runs that continued the first one in the same directory.
+	Resumes int
+	Error   string
+	Blocks  []blockSummary
+	// Usage is nil when the run did not get to write usage.json.
+	Usage  *usage.Report
+	Report string
+}
+
+type blockSummary struct {
+	Name           string
+	Status         string
+	StopReason     string
+	Iterations     int
+	FinalIteration int
+	// Score is the oracle score of the final iteration, nil when it is not known.
+	Score        *float64
+	ExpertErrors int
+	Duration     time.Duration
+	Error        string
+	Usage        *usage.Record
+}
+
+// inspectCommand prints the summary of a finished or interrupted run.
+func inspectCommand(_ context.Context, args []string) error {
+	fs := newFlagSet("inspect")
+	_ = fs.Parse(args)
+	if fs.NArg() != 1 {
+		fs.Usage()
+		return fmt.Errorf("%w: expected the output directory of a run", errUsage)
+	}
+
+	summary, err := inspectRun(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return writeRunSummary(os.Stdout, summary)
+}
+
+// transcriptEvent is an event of the transcript with its data left to be decoded by type.
+type transcriptEvent struct {
+	Time      time.Time            `json:"time"`
+	Type      transcript.EventType `json:"type"`
+	Block     string               `json:"block"`
+	Iteration *int                 `json:"iteration"`
+	Data      json.RawMessage      `json:"data"`
+}
+
+func inspectRun(dir string) (runSummary, error) {
+	summary := runSummary{Dir: dir}
+
+	f, err := os.Open(filepath.Join(dir, transcript.FileName))
+	if err != nil {
+		return summary, fmt.Errorf("cannot read the run transcript: %w", err)
+	}
+	defer f.Close()
+
+	blocks := map[string]*blockSummary{}
+	started := map[string]time.Time{}
+	scores := map[string]map[int]float64{}
+	block := func(name string) *blockSummary {
+		if blocks[name] == nil {
+			blocks[name] = &blockSummary{Name: name, Status: blockNotRun}
+		}
+		return blocks[name]
+	}
+
+	decoder := json.NewDecoder(f)
+	for {
+		var e transcriptEvent
+		err := decoder.Decode(&e)
+		// an interrupted run can leave the last line incomplete
+		if errors.Is(err, io.EOF) || errors.Is(err, io.ErrUnexpectedEOF) {
+			break
+		}
+		if err != nil {
+			return summary, fmt.Errorf("cannot parse the run transcript: %w", err)
+		}
+
+		if err := summary.add(e, block, started, scores); err != nil {
+			return summary, fmt.Errorf("cannot parse %s event: %w", e.Type, err)
+		}
+	}
+	if summary.Started.IsZero() {
+		return summary, errors.New("the run transcript has no run_started event")
+	}
+
+	report, err := readUsageReport(dir)
+	if err != nil {
+		return summary, err
+	}
+	summary.Usage = report
+
+	for i, b := range summary.Blocks {
+		if bs, ok := blocks[b.Name]; ok {
+			b = *bs
+		}
+		if score, ok := scores[b.Name][b.FinalIteration]; ok && b.Status == blockFinished {
+			b.Score = &score
+		}
+		if report != nil {
+			var records []usage.Record
+			for _, r := range report.ByRole {
+				if r.Block == b.Name {
+					records = append(records, r)
+				}
+			}
+			total := usage.NewReport(records).Total
+			b.Usage = &total
+		}
+		summary.Blocks[i] = b
+	}
+
+	if _, err := os.Stat(filepath.Join(dir, reportFileName)); err == nil {
+		summary.Report = filepath.Join(dir, reportFileName)
+	}
+
+	return summary, nil
+}
+
+// add applies an event to the summary. Resumed runs append to the transcript, so the
+// later events of a block replace the earlier ones.
+func (s *runSummary) add(
+	e transcriptEvent,
+	block func(name string) *blockSummary,
+	started map[string]time.Time,
+	scores map[string]map[int]float64,
+) error {
+	switch e.Type {
+	case transcript.EventRunStarted:
+		var data transcript.RunStarted
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return err
+		}
+		if s.Started.IsZero() {
+			s.Started = e.Time
+		} else {
+			s.Resumes++
+		}
+		s.Finished, s.Error = time.Time{}, ""
+		s.Blocks = nil
+		for _, name := range data.Blocks {
+			s.Blocks = append(s.Blocks, blockSummary{Name: name, Status: blockNotRun})
+		}
+	case transcript.EventRunFinished:
+		var data transcript.RunFinished
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return err
+		}
+		s.Finished, s.Error = e.Time, data.Error
+	case transcript.EventBlockStarted:
+		block(e.Block).Status = blockInterrupted
+		started[e.Block] = e.Time
+	case transcript.EventBlockFinished:
+		var data transcript.BlockFinished
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return err
+		}
+		b := block(e.Block)
+		switch data.Error {
+		case "":
+			b.Status = blockFinished
+		case errRunCanceled.Error():
+			b.Status = blockCanceled
+		default:
+			b.Status = blockFailed
+		}
+		b.StopReason, b.Error = data.StopReason, data.Error
+		b.Iterations, b.FinalIteration = data.Iterations, data.FinalIteration
+		b.Duration = e.Time.Sub(started[e.Block])
+	case transcript.EventExpertError:
+		block(e.Block).ExpertErrors++
+	case transcript.EventOracleVerdict:
+		var data transcript.OracleVerdict
+		if err := json.Unmarshal(e.Data, &data); err != nil {
+			return err
+		}
+		if e.Iteration != nil {
+			if scores[e.Block] == nil {
+				scores[e.Block] = map[int]float64{}
+			}
+			scores[e.Block][*e.Iteration] = data.Score
+		}
+	}
+	return nil
+}
+
+// readUsageReport reads usage.json of the run, nil when there is none.
+func readUsageReport(dir string) (*usage.Report, error) {
+	data, err := os.ReadFile(filepath.Join(dir, usageFileName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("cannot read the usage report: %w", err)
+	}
+
+	var report usage.Report
+	if err := json.Unmarshal(data, &report); err != nil {
+		return nil, fmt.Errorf("cannot parse the usage report: %w", err)
+	}
+	return &report, nil
+}
+
+func writeRunSummary(w io.Writer, s runSummary) error {
+	fmt.Fprintf(w, "Run:       %s\n", s.Dir)
+	fmt.Fprintf(w, "Started:   %s\n", s.Started.Format(time.RFC3339))
+	if s.Resumes > 0 {
+		fmt.Fprintf(w, "Resumes:   %d\n", s.Resumes)
+	}
+	switch {
+	case s.Finished.IsZero():
+		fmt.Fprintf(w, "Status:    interrupted, continue it with `run -resume %s`\n", s.Dir)
+	case s.Error != "":
+		fmt.Fprintf(w, "Finished:  %s\n", s.Finished.Format(time.RFC3339))
+		fmt.Fprintf(w, "Status:    failed: %s\n", s.Error)
+	default:
+		fmt.Fprintf(w, "Finished:  %s\n", s.Finished.Format(time.RFC3339))
+		fmt.Fprintln(w, "Status:    succeeded")
+	}
+	if s.Usage != nil {
+		fmt.Fprintf(w, "Tokens:    %d in %d calls\n", s.Usage.Total.TotalTokens, s.Usage.Total.Calls)
+		fmt.Fprintf(w, "Cost USD:  %s\n", s.Usage.Total.FormatCost())
+	}
+	if s.Report != "" {
+		fmt.Fprintf(w, "Report:    %s\n", s.Report)
+	}
+	fmt.Fprintln(w)
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BLOCK\tSTATUS\tSTOP REASON\tITERATIONS\tFINAL\tSCORE\tEXPERT ERRORS\tDURATION\tTOKENS\tCOST USD")
+	for _, b := range s.Blocks {
+		final, score, tokens, cost := "-", "-", "-", "-"
+		if b.Status == blockFinished {
+			final = fmt.Sprint(b.FinalIteration)
+		}
+		if b.Score != nil {
+			score = formatScore(*b.Score)
+		}
+		if b.Usage != nil {
+			tokens, cost = fmt.Sprint(b.Usage.TotalTokens), b.Usage.FormatCost()
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%d\t%s\t%s\t%d\t%s\t%s\t%s\n",
+			b.Name,
+			b.Status,
+			valueOrDefault(b.StopReason, "-"),
+			b.Iterations,
+			final,
+			score,
+			b.ExpertErrors,
+			b.Duration.Round(time.Second),
+			tokens,
+			cost,
+		)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	for _, b := range s.Blocks {
+		if b.Error != "" {
+			fmt.Fprintf(w, "\n%s: %s\n", b.Name, b.Error)
+		}
+	}
+	return nil
+}
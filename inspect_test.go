@@ -0,0 +1,91 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/transcript"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+)
+
+func TestInspectRun(t *testing.T) {
+	dir := t.TempDir()
+	events := `{"time":"2026-01-02T10:00:00Z","type":"run_started","data":{"blocks":["design","docs","summary"],"resume":false}}
+{"time":"2026-01-02T10:00:01Z","type":"block_started","block":"design","data":{"inputs":[]}}
+{"time":"2026-01-02T10:01:00Z","type":"expert_error","block":"design","iteration":0,"role":"expert","assistant":"reviewer","data":{"error":"timeout"}}
+{"time":"2026-01-02T10:02:00Z","type":"oracle_verdict","block":"design","iteration":0,"data":{"accept":false,"score":6}}
+{"time":"2026-01-02T10:03:00Z","type":"run_started","data":{"blocks":["design","docs","summary"],"resume":true}}
+{"time":"2026-01-02T10:03:01Z","type":"block_started","block":"design","data":{"inputs":[]}}
+{"time":"2026-01-02T10:04:00Z","type":"oracle_verdict","block":"design","iteration":1,"data":{"accept":true,"score":8.5}}
+{"time":"2026-01-02T10:04:01Z","type":"block_finished","block":"design","data":{"stopReason":"accepted","finalIteration":1,"iterations":2,"usage":{"calls":6,"inputTokens":0,"outputTokens":0,"totalTokens":0,"latencyMs":0}}}
+{"time":"2026-01-02T10:04:02Z","type":"block_started","block":"docs","data":{"inputs":["design"]}}
+{"time":"2026-01-02T10:04:05Z","type":"block_finished","block":"docs","data":{"finalIteration":0,"iterations":0,"usage":{"calls":0,"inputTokens":0,"outputTokens":0,"totalTokens":0,"latencyMs":0},"error":"non-200 status code: 500"}}
+{"time":"2026-01-02T10:04:02Z","type":"block_started","block":"summary","data":{"inputs":[]}}
+{"time":"2026-01-02T10:04:05Z","type":"block_finished","block":"summary","data":{"finalIteration":0,"iterations":0,"usage":{"calls":0,"inputTokens":0,"outputTokens":0,"totalTokens":0,"latencyMs":0},"error":"canceled, another block failed"}}
+{"time":"2026-01-02T10:04:06Z","type":"run_finished","data":{"error":"non-200 status code: 500"}}
+{"time":"2026-01-02T10:05:00Z","type":"run_st`
+	if err := os.WriteFile(filepath.Join(dir, transcript.FileName), []byte(events), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report := usage.NewReport([]usage.Record{
+		{Block: "design", Role: "worker", Model: "gpt-4o", Calls: 2, TotalTokens: 300, CostUSD: 0.5, Priced: true},
+		{Block: "design", Role: "oracle", Model: "local", Calls: 2, TotalTokens: 100},
+	})
+	if err := report.WriteJSON(filepath.Join(dir, usageFileName)); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	s, err := inspectRun(dir)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if s.Resumes != 1 || s.Error != "non-200 status code: 500" {
+		t.Errorf("expected a failed resumed run, got %+v", s)
+	}
+	if !s.Finished.Equal(time.Date(2026, 1, 2, 10, 4, 6, 0, time.UTC)) {
+		t.Errorf("expected the time of run_finished, got %s", s.Finished)
+	}
+	if len(s.Blocks) != 3 {
+		t.Fatalf("expected 3 blocks, got %+v", s.Blocks)
+	}
+
+	design := s.Blocks[0]
+	if design.Status != blockFinished || design.StopReason != "accepted" || design.FinalIteration != 1 ||
+		design.ExpertErrors != 1 || design.Duration != time.Minute {
+		t.Errorf("expected design to be finished after the resume, got %+v", design)
+	}
+	if design.Score == nil || *design.Score != 8.5 {
+		t.Errorf("expected the score of the final iteration, got %v", design.Score)
+	}
+	if design.Usage == nil || design.Usage.TotalTokens != 400 || design.Usage.FormatCost() != ">=0.5000" {
+		t.Errorf("expected the usage of design, got %+v", design.Usage)
+	}
+	if s.Blocks[1].Status != blockFailed || s.Blocks[2].Status != blockCanceled {
+		t.Errorf("expected docs to fail and summary to be canceled, got %+v", s.Blocks[1:])
+	}
+
+	var buf bytes.Buffer
+	if err := writeRunSummary(&buf, s); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	for _, line := range []string{
+		"Status:    failed: non-200 status code: 500",
+		"Cost USD:  >=0.5000",
+		"docs: non-200 status code: 500",
+	} {
+		if !strings.Contains(buf.String(), line) {
+			t.Errorf("expected %q in the summary, got:\n%s", line, buf.String())
+		}
+	}
+}
+
+func TestInspectRun_MissingTranscript(t *testing.T) {
+	if _, err := inspectRun(t.TempDir()); err == nil {
+		t.Fatalf("expected an error")
+	}
+}
@@ -0,0 +1,229 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	defaultAnthropicBaseURL   = "https://api.anthropic.com/v1"
+	anthropicAPIVersion       = "2023-06-01"
+	defaultAnthropicMaxTokens = 4096
+	maxAnthropicToolNameLen   = 64
+)
+
+// AnthropicProvider talks to the Anthropic Messages API. Structured output is obtained by
+// forcing the model to call a single tool whose input schema is the requested JSON schema.
+type AnthropicProvider struct {
+	apiKey  string
+	baseURL string
+	model   string
+	apiClient
+}
+
+func NewAnthropicProvider(apiKey, model string, baseURL string) *AnthropicProvider {
+	if baseURL == "" {
+		baseURL = defaultAnthropicBaseURL
+	}
+
+	return &AnthropicProvider{
+		apiKey:    apiKey,
+		baseURL:   baseURL,
+		model:     model,
+		apiClient: newAPIClient(),
+	}
+}
+
+func (a *AnthropicProvider) GetCompletion(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if err := req.errToolsUnsupported("anthropic"); err != nil {
+		return ChatResponse{}, err
+	}
+
+	anthropicReq := newAnthropicRequest(req.BaseChatRequest, a.model)
+
+	return a.executeRequest(ctx, anthropicReq.Model, anthropicReq, func(body []byte) (ChatResponse, error) {
+		var result anthropicResponse
+		if err := json.Unmarshal(body, &result); err != nil {
+			return ChatResponse{}, fmt.Errorf("error parsing response: %w", err)
+		}
+
+		var text strings.Builder
+		for _, c := range result.Content {
+			if c.Type == "text" {
+				text.WriteString(c.Text)
+			}
+		}
+
+		return ChatResponse{
+			Response:   text.String(),
+			Model:      anthropicReq.Model,
+			TokenUsage: result.Usage.toTokenUsage(),
+			TimeTaken:  0, // Time taken is handled in executeRequest
+		}, nil
+	})
+}
+
+func (a *AnthropicProvider) GetResponse(ctx context.Context, req StructuredChatRequest) (ChatResponse, error) {
+	if err := req.errToolsUnsupported("anthropic"); err != nil {
+		return ChatResponse{}, err
+	}
+
+	anthropicReq := newAnthropicRequest(req.BaseChatRequest, a.model)
+
+	toolName := anthropicToolName(req.Name)
+	anthropicReq.Tools = []anthropicTool{{
+		Name:        toolName,
+		Description: "Respond using this tool. Its input is the final answer.",
+		InputSchema: req.Schema,
+	}}
+	anthropicReq.ToolChoice = &anthropicToolChoice{Type: "tool", Name: toolName}
+
+	return a.executeRequest(ctx, anthropicReq.Model, anthropicReq, func(body []byte) (ChatResponse, error) {
+		var result anthropicResponse
+		if err := json.Unmarshal(body, &result); err != nil {
+			return ChatResponse{}, fmt.Errorf("error parsing response: %w", err)
+		}
+
+		for _, c := range result.Content {
+			if c.Type == "tool_use" && c.Name == toolName {
+				return ChatResponse{
+					Response:   string(c.Input),
+					Model:      anthropicReq.Model,
+					TokenUsage: result.Usage.toTokenUsage(),
+					TimeTaken:  0, // Time taken is handled in executeRequest
+				}, nil
+			}
+		}
+
+		return ChatResponse{}, errors.New("response does not contain structured output")
+	})
+}
+
+func (a *AnthropicProvider) executeRequest(
+	ctx context.Context,
+	model string,
+	requestBodyData any,
+	parseResponse responseParser,
+) (ChatResponse, error) {
+	ctx, span := startRequestSpan(ctx, "AnthropicProvider.executeRequest", "anthropic", model, false)
+	headers := map[string]string{
+		"x-api-key":         a.apiKey,
+		"anthropic-version": anthropicAPIVersion,
+	}
+	resp, err := a.postJSON(ctx, a.baseURL+"/messages", headers, requestBodyData, parseResponse)
+	endRequestSpan(span, resp, err)
+	return resp, err
+}
+
+type anthropicChatRequest struct {
+	Model       string                 `json:"model"`
+	MaxTokens   int                    `json:"max_tokens"`
+	Temperature *float64               `json:"temperature,omitempty"`
+	System      string                 `json:"system,omitempty"`
+	Messages    []anthropicChatMessage `json:"messages"`
+	Tools       []anthropicTool        `json:"tools,omitempty"`
+	ToolChoice  *anthropicToolChoice   `json:"tool_choice,omitempty"`
+}
+
+type anthropicChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type anthropicTool struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	InputSchema any    `json:"input_schema"`
+}
+
+type anthropicToolChoice struct {
+	Type string `json:"type"`
+	Name string `json:"name,omitempty"`
+}
+
+type anthropicTokenUsage struct {
+	InputTokens  int `json:"input_tokens"`
+	OutputTokens int `json:"output_tokens"`
+}
+
+func (u anthropicTokenUsage) toTokenUsage() TokenUsage {
+	return TokenUsage{
+		InputTokens:  u.InputTokens,
+		OutputTokens: u.OutputTokens,
+		TotalTokens:  u.InputTokens + u.OutputTokens,
+	}
+}
+
+type anthropicResponse struct {
+	Content []struct {
+		Type  string          `json:"type"`
+		Text  string          `json:"text"`
+		Name  string          `json:"name"`
+		Input json.RawMessage `json:"input"`
+	} `json:"content"`
+	Usage anthropicTokenUsage `json:"usage"`
+}
+
+// newAnthropicRequest maps our chat messages onto the Messages API. System and developer
+// messages go to the top-level system field, consecutive messages with the same role are
+// merged because the API expects user and assistant turns to alternate.
+func newAnthropicRequest(chat BaseChatRequest, model string) anthropicChatRequest {
+	var system []string
+	var messages []anthropicChatMessage
+
+	for _, msg := range chat.Messages {
+		switch msg.Role {
+		case "system", "developer":
+			if msg.Content != "" {
+				system = append(system, msg.Content)
+			}
+		default:
+			if n := len(messages); n > 0 && messages[n-1].Role == msg.Role {
+				messages[n-1].Content += "\n\n" + msg.Content
+				continue
+			}
+			messages = append(messages, anthropicChatMessage{
+				Role:    msg.Role,
+				Content: msg.Content,
+			})
+		}
+	}
+
+	maxTokens := chat.MaxTokens
+	if maxTokens == 0 {
+		maxTokens = defaultAnthropicMaxTokens
+	}
+
+	return anthropicChatRequest{
+		Model:       chat.modelOrDefault(model),
+		MaxTokens:   maxTokens,
+		Temperature: chat.Temperature,
+		System:      strings.Join(system, "\n\n"),
+		Messages:    messages,
+	}
+}
+
+// anthropicToolName makes the structured output name a valid tool name.
+func anthropicToolName(name string) string {
+	var builder strings.Builder
+	for _, r := range name {
+		switch {
+		case r >= 'a' && r <= 'z', r >= 'A' && r <= 'Z', r >= '0' && r <= '9', r == '_', r == '-':
+			builder.WriteRune(r)
+		default:
+			builder.WriteRune('_')
+		}
+	}
+
+	toolName := builder.String()
+	if toolName == "" {
+		return "structured_output"
+	}
+	if len(toolName) > maxAnthropicToolNameLen {
+		toolName = toolName[:maxAnthropicToolNameLen]
+	}
+	return toolName
+}
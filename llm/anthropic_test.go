@@ -0,0 +1,97 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestAnthropicProviderGetCompletion(t *testing.T) {
+	var got anthropicChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/messages" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if r.Header.Get("x-api-key") != "test-key" {
+			t.Errorf("expected api key header, got '%s'", r.Header.Get("x-api-key"))
+		}
+		if r.Header.Get("anthropic-version") == "" {
+			t.Errorf("expected anthropic-version header")
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("cannot decode request: %v", err)
+		}
+
+		_, _ = w.Write([]byte(`{
+			"content": [{"type": "text", "text": "Hello from Claude"}],
+			"usage": {"input_tokens": 10, "output_tokens": 5}
+		}`))
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider("test-key", "claude-test", server.URL)
+	resp, err := provider.GetCompletion(context.Background(), ChatRequest{BaseChatRequest{
+		Messages: []ChatMessage{
+			{Role: "developer", Content: "You are helpful."},
+			{Role: "user", Content: "Hi"},
+		},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.System != "You are helpful." {
+		t.Errorf("expected developer message in system field, got '%s'", got.System)
+	}
+	if len(got.Messages) != 1 || got.Messages[0].Role != "user" {
+		t.Errorf("expected a single user message, got %+v", got.Messages)
+	}
+	if got.Model != "claude-test" || got.MaxTokens != defaultAnthropicMaxTokens {
+		t.Errorf("unexpected model or max tokens: %s %d", got.Model, got.MaxTokens)
+	}
+
+	if resp.Response != "Hello from Claude" {
+		t.Errorf("expected 'Hello from Claude', got '%s'", resp.Response)
+	}
+	if resp.TokenUsage != (TokenUsage{InputTokens: 10, OutputTokens: 5, TotalTokens: 15}) {
+		t.Errorf("unexpected token usage: %+v", resp.TokenUsage)
+	}
+}
+
+func TestAnthropicProviderGetResponse(t *testing.T) {
+	var got anthropicChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("cannot decode request: %v", err)
+		}
+
+		_, _ = w.Write([]byte(`{
+			"content": [{"type": "tool_use", "name": "files_writer", "input": {"files": []}}],
+			"usage": {"input_tokens": 3, "output_tokens": 4}
+		}`))
+	}))
+	defer server.Close()
+
+	provider := NewAnthropicProvider("test-key", "claude-test", server.URL)
+	resp, err := provider.GetResponse(context.Background(), StructuredChatRequest{
+		BaseChatRequest: BaseChatRequest{Messages: []ChatMessage{{Role: "user", Content: "Hi"}}},
+		Schema:          map[string]any{"type": "object"},
+		Name:            "files writer",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(got.Tools) != 1 || got.ToolChoice == nil || got.ToolChoice.Name != got.Tools[0].Name {
+		t.Errorf("expected tool use to be forced, got tools %+v and choice %+v", got.Tools, got.ToolChoice)
+	}
+
+	if resp.Response != `{"files": []}` {
+		t.Errorf("expected tool input as response, got '%s'", resp.Response)
+	}
+	if resp.TokenUsage.TotalTokens != 7 {
+		t.Errorf("expected 7 total tokens, got %d", resp.TokenUsage.TotalTokens)
+	}
+}
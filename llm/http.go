@@ -0,0 +1,217 @@
+package llm
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
+)
+
+const defaultTimeout = 60 * time.Second
+
+// maxErrorBodyLength limits the response body in the message of an APIError, so full error
+// pages and echoed requests do not end up in logs.
+const maxErrorBodyLength = 512
+
+type HTTPClient interface {
+	Do(req *http.Request) (*http.Response, error)
+}
+
+// APIError is returned when the API responds with a non-200 status code. Body holds the
+// whole response body, its message only the beginning of it.
+type APIError struct {
+	StatusCode int
+	Body       string
+}
+
+func (e *APIError) Error() string {
+	body := strings.TrimSpace(e.Body)
+	if len(body) > maxErrorBodyLength {
+		body = fmt.Sprintf("%s... (%d bytes)", strings.ToValidUTF8(body[:maxErrorBodyLength], ""), len(body))
+	}
+	return fmt.Sprintf("non-200 status code: %d; body: %s", e.StatusCode, body)
+}
+
+var errCreateRequest = errors.New("error creating request")
+
+type responseParser func([]byte) (ChatResponse, error)
+
+// apiClient sends requests to HTTP based LLM APIs. It is embedded by all such providers.
+type apiClient struct {
+	httpClient HTTPClient
+	// streamHTTPClient has no overall timeout, long generations are limited by the idle
+	// timeout between streamed events instead.
+	streamHTTPClient HTTPClient
+	retryPolicy      RetryPolicy
+}
+
+func newAPIClient() apiClient {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.ResponseHeaderTimeout = defaultTimeout
+
+	return apiClient{
+		httpClient:       &http.Client{Timeout: defaultTimeout},
+		streamHTTPClient: &http.Client{Transport: transport},
+		retryPolicy:      DefaultRetryPolicy(),
+	}
+}
+
+// SetRetryPolicy replaces the default retry policy of the provider.
+func (c *apiClient) SetRetryPolicy(policy RetryPolicy) {
+	c.retryPolicy = policy
+}
+
+// postJSON sends requestBodyData as a JSON POST request to url and parses a successful
+// response with parseResponse. Transport errors and retryable status codes are retried
+// according to the retry policy.
+func (c *apiClient) postJSON(
+	ctx context.Context,
+	url string,
+	headers map[string]string,
+	requestBodyData any,
+	parseResponse responseParser,
+) (ChatResponse, error) {
+	startTime := time.Now()
+
+	requestBody, err := json.Marshal(requestBodyData)
+	if err != nil {
+		return ChatResponse{}, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	var body []byte
+	err = c.withRetry(ctx, startTime, func() (time.Duration, error) {
+		resp, retryAfter, err := c.send(ctx, c.httpClient, url, headers, requestBody)
+		if err != nil {
+			return retryAfter, err
+		}
+		defer resp.Body.Close()
+
+		body, err = io.ReadAll(resp.Body)
+		if err != nil {
+			return 0, fmt.Errorf("error reading response body: %w", err)
+		}
+		return 0, nil
+	})
+	if err != nil {
+		return ChatResponse{}, err
+	}
+
+	chatResponse, err := parseResponse(body)
+	if err != nil {
+		return ChatResponse{}, err
+	}
+
+	chatResponse.TimeTaken = time.Since(startTime)
+	return chatResponse, nil
+}
+
+// withRetry calls attempt until it succeeds, returns a non-retryable error or the retry
+// policy gives up. attempt returns the delay requested by the API next to its error.
+func (c *apiClient) withRetry(
+	ctx context.Context,
+	startTime time.Time,
+	attempt func() (retryAfter time.Duration, err error),
+) error {
+	logger := loggerutils.GetLogger(ctx)
+	span := trace.SpanFromContext(ctx)
+
+	for n := 1; ; n++ {
+		span.SetAttributes(attrRetryCount.Int(n - 1))
+		retryAfter, err := attempt()
+		if err == nil {
+			return nil
+		}
+
+		if !c.isRetryable(ctx, err) || n >= c.retryPolicy.MaxAttempts {
+			return err
+		}
+
+		delay := max(c.retryPolicy.backoff(n), retryAfter)
+		maxElapsed := c.retryPolicy.MaxElapsedTime
+		if maxElapsed > 0 && time.Since(startTime)+delay > maxElapsed {
+			return fmt.Errorf("giving up retrying after %s: %w", time.Since(startTime), err)
+		}
+
+		logger.Warn("Request failed, retrying...", "attempt", n, "delay", delay, "error", err)
+		span.AddEvent("retry", trace.WithAttributes(
+			attribute.Int("attempt", n),
+			attribute.String("delay", delay.String()),
+			attribute.String("error", err.Error()),
+		))
+
+		timer := time.NewTimer(delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return fmt.Errorf("error sending request: %w", ctx.Err())
+		case <-timer.C:
+		}
+	}
+}
+
+// send executes a single attempt and returns the response when its status is 200, the
+// caller has to close its body. A new request is built every time because the body
+// reader of a sent request is already consumed.
+func (c *apiClient) send(
+	ctx context.Context,
+	client HTTPClient,
+	url string,
+	headers map[string]string,
+	requestBody []byte,
+) (resp *http.Response, retryAfter time.Duration, err error) {
+	httpReq, err := http.NewRequestWithContext(ctx,
+		"POST",
+		url,
+		bytes.NewReader(requestBody),
+	)
+	if err != nil {
+		return nil, 0, fmt.Errorf("%w: %w", errCreateRequest, err)
+	}
+
+	httpReq.Header.Set("Content-Type", "application/json")
+	for k, v := range headers {
+		httpReq.Header.Set(k, v)
+	}
+
+	resp, err = client.Do(httpReq)
+	if err != nil {
+		return nil, 0, fmt.Errorf("error sending request: %w", err)
+	}
+	trace.SpanFromContext(ctx).SetAttributes(attrStatusCode.Int(resp.StatusCode))
+
+	if resp.StatusCode != http.StatusOK {
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return nil, 0, fmt.Errorf("error reading response body: %w", err)
+		}
+
+		retryAfter = parseRetryAfter(resp.Header.Get("Retry-After"), time.Now())
+		return nil, retryAfter, &APIError{StatusCode: resp.StatusCode, Body: string(body)}
+	}
+
+	return resp, 0, nil
+}
+
+func (c *apiClient) isRetryable(ctx context.Context, err error) bool {
+	if ctx.Err() != nil {
+		return false
+	}
+
+	var apiErr *APIError
+	if errors.As(err, &apiErr) {
+		return c.retryPolicy.isRetryableStatus(apiErr.StatusCode)
+	}
+
+	// request creation errors are not going to disappear
+	return !errors.Is(err, errCreateRequest)
+}
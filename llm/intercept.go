@@ -0,0 +1,60 @@
+package llm
+
+import "context"
+
+// Call is an intercepted call of a provider.
+type Call struct {
+	Request BaseChatRequest
+	// Schema is the name of the structured output schema, empty for completions.
+	Schema string
+}
+
+// Interceptor runs around every call of a provider, send makes the call. It can skip the
+// call by not calling send, e.g. when a limit is reached.
+type Interceptor func(ctx context.Context, call Call, send func() (ChatResponse, error)) (ChatResponse, error)
+
+// Intercept returns a provider running every call through intercept. Structured output
+// support of the wrapped provider is preserved, streaming is always supported, through an
+// adapter when the wrapped provider does not stream, so wrappers can be stacked in any
+// order without losing capabilities.
+func Intercept(provider LLMProvider, intercept Interceptor) LLMProvider {
+	p := interceptedProvider{provider: Streaming(provider), intercept: intercept}
+	if sp, ok := provider.(StructuredLLMProvider); ok {
+		return structuredInterceptedProvider{interceptedProvider: p, structured: StreamingStructured(sp)}
+	}
+	return p
+}
+
+type interceptedProvider struct {
+	provider  StreamingLLMProvider
+	intercept Interceptor
+}
+
+func (p interceptedProvider) GetCompletion(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	return p.intercept(ctx, Call{Request: req.BaseChatRequest}, func() (ChatResponse, error) {
+		return p.provider.GetCompletion(ctx, req)
+	})
+}
+
+func (p interceptedProvider) StreamCompletion(ctx context.Context, req ChatRequest, onDelta StreamHandler) (ChatResponse, error) {
+	return p.intercept(ctx, Call{Request: req.BaseChatRequest}, func() (ChatResponse, error) {
+		return p.provider.StreamCompletion(ctx, req, onDelta)
+	})
+}
+
+type structuredInterceptedProvider struct {
+	interceptedProvider
+	structured StreamingStructuredLLMProvider
+}
+
+func (p structuredInterceptedProvider) GetResponse(ctx context.Context, req StructuredChatRequest) (ChatResponse, error) {
+	return p.intercept(ctx, Call{Request: req.BaseChatRequest, Schema: req.Name}, func() (ChatResponse, error) {
+		return p.structured.GetResponse(ctx, req)
+	})
+}
+
+func (p structuredInterceptedProvider) StreamResponse(ctx context.Context, req StructuredChatRequest, onDelta StreamHandler) (ChatResponse, error) {
+	return p.intercept(ctx, Call{Request: req.BaseChatRequest, Schema: req.Name}, func() (ChatResponse, error) {
+		return p.structured.StreamResponse(ctx, req, onDelta)
+	})
+}
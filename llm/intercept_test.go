@@ -0,0 +1,57 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"testing"
+)
+
+func TestIntercept(t *testing.T) {
+	var calls []Call
+	intercept := func(ctx context.Context, call Call, send func() (ChatResponse, error)) (ChatResponse, error) {
+		calls = append(calls, call)
+		if call.Request.Model == "blocked" {
+			return ChatResponse{}, errors.New("blocked")
+		}
+		return send()
+	}
+
+	plain := Intercept(&MockLLMProvider{}, intercept)
+	if _, ok := plain.(StructuredLLMProvider); ok {
+		t.Errorf("expected no structured output for a plain provider")
+	}
+	if _, ok := plain.(StreamingLLMProvider); !ok {
+		t.Errorf("expected streaming to be supported")
+	}
+
+	sent := 0
+	structured := Intercept(&MockStructuredLLMProvider{
+		GetResponseFunc: func(ctx context.Context, req StructuredChatRequest) (ChatResponse, error) {
+			sent++
+			return ChatResponse{Response: "{}"}, nil
+		},
+	}, intercept)
+	sp, ok := structured.(StreamingStructuredLLMProvider)
+	if !ok {
+		t.Fatalf("expected structured output to be preserved")
+	}
+
+	var deltas []string
+	resp, err := sp.StreamResponse(context.Background(), StructuredChatRequest{Name: "verdict"}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil || resp.Response != "{}" || len(deltas) != 1 {
+		t.Errorf("unexpected response %+v, deltas %v, error %v", resp, deltas, err)
+	}
+
+	blocked := StructuredChatRequest{BaseChatRequest: BaseChatRequest{Model: "blocked"}}
+	if _, err := sp.GetResponse(context.Background(), blocked); err == nil {
+		t.Errorf("expected the interceptor error")
+	}
+	if sent != 1 {
+		t.Errorf("expected a single call to be sent, got %d", sent)
+	}
+	if len(calls) != 2 || calls[0].Schema != "verdict" {
+		t.Errorf("unexpected intercepted calls %+v", calls)
+	}
+}
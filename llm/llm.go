@@ -17,6 +17,19 @@ type StructuredLLMProvider interface {
 type BaseChatRequest struct {
 	Messages  []ChatMessage
 	MaxTokens int
+	// Model overrides the provider's default model when not empty.
+	Model string
+	// Temperature is sent only when set, otherwise the API default is used.
+	Temperature *float64
+	// Tools the model can call instead of answering, see Tool.
+	Tools []Tool
+}
+
+func (r BaseChatRequest) modelOrDefault(defaultModel string) string {
+	if r.Model != "" {
+		return r.Model
+	}
+	return defaultModel
 }
 
 type ChatRequest struct {
@@ -32,12 +45,32 @@ type StructuredChatRequest struct {
 type ChatMessage struct {
 	Role    string
 	Content string
+	// ToolCalls are the calls requested by the model in an assistant message.
+	ToolCalls []ToolCall
+	// ToolCallID links a tool message with the call it is the result of.
+	ToolCallID string
 }
 
 type ChatResponse struct {
-	Response   string
+	Response string
+	// ToolCalls are requested by the model instead of a final answer.
+	ToolCalls  []ToolCall
+	Model      string
 	TokenUsage TokenUsage
 	TimeTaken  time.Duration
+	// Calls is the number of requests behind the response when there was more than
+	// one, e.g. in a tool calling loop.
+	Calls int
+}
+
+// Usage returns the usage of the calls that produced the response.
+func (r ChatResponse) Usage() Usage {
+	return Usage{
+		Model:      r.Model,
+		Calls:      max(r.Calls, 1),
+		TokenUsage: r.TokenUsage,
+		TimeTaken:  r.TimeTaken,
+	}
 }
 
 type TokenUsage struct {
@@ -45,3 +78,37 @@ type TokenUsage struct {
 	OutputTokens int
 	TotalTokens  int
 }
+
+func (tu TokenUsage) Add(other TokenUsage) TokenUsage {
+	return TokenUsage{
+		InputTokens:  tu.InputTokens + other.InputTokens,
+		OutputTokens: tu.OutputTokens + other.OutputTokens,
+		TotalTokens:  tu.TotalTokens + other.TotalTokens,
+	}
+}
+
+// Usage accumulates token usage and latency of LLM calls. Model is empty when the calls
+// were made to different models.
+type Usage struct {
+	Model      string
+	Calls      int
+	TokenUsage TokenUsage
+	TimeTaken  time.Duration
+}
+
+func (u Usage) Add(other Usage) Usage {
+	model := u.Model
+	switch {
+	case u.Calls == 0:
+		model = other.Model
+	case other.Calls > 0 && other.Model != u.Model:
+		model = ""
+	}
+
+	return Usage{
+		Model:      model,
+		Calls:      u.Calls + other.Calls,
+		TokenUsage: u.TokenUsage.Add(other.TokenUsage),
+		TimeTaken:  u.TimeTaken + other.TimeTaken,
+	}
+}
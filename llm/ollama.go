@@ -0,0 +1,126 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+)
+
+const defaultOllamaBaseURL = "http://localhost:11434"
+
+// OllamaProvider talks to the /api/chat endpoint of an Ollama (or compatible llama.cpp)
+// server. Structured output uses the JSON schema `format` field.
+type OllamaProvider struct {
+	baseURL string
+	model   string
+	apiClient
+}
+
+func NewOllamaProvider(model string, baseURL string) *OllamaProvider {
+	if baseURL == "" {
+		baseURL = defaultOllamaBaseURL
+	}
+
+	return &OllamaProvider{
+		baseURL:   baseURL,
+		model:     model,
+		apiClient: newAPIClient(),
+	}
+}
+
+func (o *OllamaProvider) GetCompletion(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+	if err := req.errToolsUnsupported("ollama"); err != nil {
+		return ChatResponse{}, err
+	}
+
+	ollamaReq := newOllamaRequest(req.BaseChatRequest, o.model)
+	return o.executeRequest(ctx, ollamaReq)
+}
+
+func (o *OllamaProvider) GetResponse(ctx context.Context, req StructuredChatRequest) (ChatResponse, error) {
+	if err := req.errToolsUnsupported("ollama"); err != nil {
+		return ChatResponse{}, err
+	}
+
+	ollamaReq := newOllamaRequest(req.BaseChatRequest, o.model)
+	ollamaReq.Format = req.Schema
+	return o.executeRequest(ctx, ollamaReq)
+}
+
+func (o *OllamaProvider) executeRequest(ctx context.Context, requestBodyData ollamaChatRequest) (ChatResponse, error) {
+	ctx, span := startRequestSpan(ctx, "OllamaProvider.executeRequest", "ollama", requestBodyData.Model, false)
+	resp, err := o.postJSON(ctx, o.baseURL+"/api/chat", nil, requestBodyData, func(body []byte) (ChatResponse, error) {
+		var result ollamaResponse
+		if err := json.Unmarshal(body, &result); err != nil {
+			return ChatResponse{}, fmt.Errorf("error parsing response: %w", err)
+		}
+
+		return ChatResponse{
+			Response: result.Message.Content,
+			Model:    requestBodyData.Model,
+			TokenUsage: TokenUsage{
+				InputTokens:  result.PromptEvalCount,
+				OutputTokens: result.EvalCount,
+				TotalTokens:  result.PromptEvalCount + result.EvalCount,
+			},
+			TimeTaken: 0, // Time taken is handled in executeRequest
+		}, nil
+	})
+	endRequestSpan(span, resp, err)
+	return resp, err
+}
+
+type ollamaChatRequest struct {
+	Model    string              `json:"model"`
+	Messages []ollamaChatMessage `json:"messages"`
+	Stream   bool                `json:"stream"`
+	Format   any                 `json:"format,omitempty"`
+	Options  *ollamaOptions      `json:"options,omitempty"`
+}
+
+type ollamaChatMessage struct {
+	Role    string `json:"role"`
+	Content string `json:"content"`
+}
+
+type ollamaOptions struct {
+	NumPredict  int      `json:"num_predict,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+}
+
+type ollamaResponse struct {
+	Message struct {
+		Content string `json:"content"`
+	} `json:"message"`
+	PromptEvalCount int `json:"prompt_eval_count"`
+	EvalCount       int `json:"eval_count"`
+}
+
+func newOllamaRequest(chat BaseChatRequest, model string) ollamaChatRequest {
+	messages := make([]ollamaChatMessage, len(chat.Messages))
+	for i, msg := range chat.Messages {
+		role := msg.Role
+		// Ollama does not know the OpenAI "developer" role
+		if role == "developer" {
+			role = "system"
+		}
+		messages[i] = ollamaChatMessage{
+			Role:    role,
+			Content: msg.Content,
+		}
+	}
+
+	req := ollamaChatRequest{
+		Model:    chat.modelOrDefault(model),
+		Messages: messages,
+		Stream:   false,
+	}
+	if chat.MaxTokens > 0 || chat.Temperature != nil {
+		req.Options = &ollamaOptions{
+			NumPredict:  chat.MaxTokens,
+			Temperature: chat.Temperature,
+		}
+	}
+
+	return req
+}
@@ -0,0 +1,58 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestOllamaProviderGetResponse(t *testing.T) {
+	var got ollamaChatRequest
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/chat" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if err := json.NewDecoder(r.Body).Decode(&got); err != nil {
+			t.Fatalf("cannot decode request: %v", err)
+		}
+
+		_, _ = w.Write([]byte(`{
+			"message": {"role": "assistant", "content": "{\"files\": []}"},
+			"prompt_eval_count": 12,
+			"eval_count": 8
+		}`))
+	}))
+	defer server.Close()
+
+	provider := NewOllamaProvider("llama3.2", server.URL)
+	resp, err := provider.GetResponse(context.Background(), StructuredChatRequest{
+		BaseChatRequest: BaseChatRequest{Messages: []ChatMessage{
+			{Role: "developer", Content: "You are helpful."},
+			{Role: "user", Content: "Hi"},
+		}},
+		Schema: map[string]any{"type": "object"},
+		Name:   "files",
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if got.Stream {
+		t.Errorf("expected streaming to be disabled")
+	}
+	if got.Format == nil {
+		t.Errorf("expected schema to be sent in format field")
+	}
+	if got.Messages[0].Role != "system" {
+		t.Errorf("expected developer role to be mapped to system, got '%s'", got.Messages[0].Role)
+	}
+
+	if resp.Response != `{"files": []}` {
+		t.Errorf("unexpected response '%s'", resp.Response)
+	}
+	if resp.TokenUsage != (TokenUsage{InputTokens: 12, OutputTokens: 8, TotalTokens: 20}) {
+		t.Errorf("unexpected token usage: %+v", resp.TokenUsage)
+	}
+}
@@ -1,45 +1,38 @@
 package llm
 
 import (
-	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
-	"io"
-	"net/http"
+	"strings"
 	"time"
-
-	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
-)
-
-const (
-	defaultBaseURL = "https://api.openai.com/v1"
-	defaultTimeout = 60 * time.Second
-	maxRetries     = 3
-	retryDelay     = 2 * time.Second
 )
 
-type HTTPClient interface {
-	Do(req *http.Request) (*http.Response, error)
-}
+const defaultBaseURL = "https://api.openai.com/v1"
 
 type OpenAIProvider struct {
-	apiKey     string
-	baseURL    string
-	model      string
-	httpClient HTTPClient
+	apiKey  string
+	baseURL string
+	model   string
+	apiClient
 }
 
 func (o *OpenAIProvider) GetCompletion(ctx context.Context, req ChatRequest) (ChatResponse, error) {
 	openAIReq := newOpenAIRequest(req, o.model)
-	return o.executeRequest(ctx, "/chat/completions", openAIReq, func(body []byte) (ChatResponse, error) {
+	return o.executeRequest(ctx, "/chat/completions", openAIReq.Model, openAIReq, func(body []byte) (ChatResponse, error) {
 		var result openAIResponse
 		if err := json.Unmarshal(body, &result); err != nil {
 			return ChatResponse{}, fmt.Errorf("error parsing response: %w", err)
 		}
+		if len(result.Choices) == 0 {
+			return ChatResponse{}, errors.New("response does not contain any choices")
+		}
 
 		response := ChatResponse{
-			Response: result.Choices[0].Message.Content,
+			Response:  result.Choices[0].Message.Content,
+			ToolCalls: fromOpenAIToolCalls(result.Choices[0].Message.ToolCalls),
+			Model:     openAIReq.Model,
 			TokenUsage: TokenUsage{
 				InputTokens:  result.Usage.PromptTokens,
 				OutputTokens: result.Usage.CompletionTokens,
@@ -57,22 +50,52 @@ func NewOpenAIProvider(apiKey, model string, baseURL string) *OpenAIProvider {
 	}
 
 	return &OpenAIProvider{
-		apiKey:     apiKey,
-		baseURL:    baseURL,
-		model:      model,
-		httpClient: &http.Client{Timeout: defaultTimeout},
+		apiKey:    apiKey,
+		baseURL:   baseURL,
+		model:     model,
+		apiClient: newAPIClient(),
 	}
 }
 
 type openAIChatRequest struct {
-	Messages  []openAIChatMessage `json:"messages"`
-	Model     string              `json:"model"`
-	MaxTokens int                 `json:"max_tokens,omitempty"`
+	Messages      []openAIChatMessage  `json:"messages"`
+	Model         string               `json:"model"`
+	MaxTokens     int                  `json:"max_tokens,omitempty"`
+	Temperature   *float64             `json:"temperature,omitempty"`
+	Tools         []openAITool         `json:"tools,omitempty"`
+	Stream        bool                 `json:"stream,omitempty"`
+	StreamOptions *openAIStreamOptions `json:"stream_options,omitempty"`
+}
+
+type openAIStreamOptions struct {
+	IncludeUsage bool `json:"include_usage"`
 }
 
 type openAIChatMessage struct {
-	Role    string `json:"role"`
-	Content string `json:"content"`
+	Role       string           `json:"role"`
+	Content    string           `json:"content"`
+	ToolCalls  []openAIToolCall `json:"tool_calls,omitempty"`
+	ToolCallID string           `json:"tool_call_id,omitempty"`
+}
+
+type openAITool struct {
+	Type     string         `json:"type"`
+	Function openAIFunction `json:"function"`
+}
+
+type openAIFunction struct {
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+}
+
+type openAIToolCall struct {
+	ID       string `json:"id"`
+	Type     string `json:"type"`
+	Function struct {
+		Name      string `json:"name"`
+		Arguments string `json:"arguments"`
+	} `json:"function"`
 }
 
 type openAITokenUsage struct {
@@ -84,7 +107,8 @@ type openAITokenUsage struct {
 type openAIResponse struct {
 	Choices []struct {
 		Message struct {
-			Content string `json:"content"`
+			Content   string           `json:"content"`
+			ToolCalls []openAIToolCall `json:"tool_calls"`
 		} `json:"message"`
 	} `json:"choices"`
 	Usage openAITokenUsage `json:"usage"`
@@ -94,16 +118,47 @@ func newOpenAIRequest(chat ChatRequest, model string) openAIChatRequest {
 	messages := make([]openAIChatMessage, len(chat.Messages))
 	for i, msg := range chat.Messages {
 		messages[i] = openAIChatMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
+			Role:       msg.Role,
+			Content:    msg.Content,
+			ToolCalls:  toOpenAIToolCalls(msg.ToolCalls),
+			ToolCallID: msg.ToolCallID,
 		}
 	}
 
+	var tools []openAITool
+	for _, t := range chat.Tools {
+		tools = append(tools, openAITool{
+			Type:     "function",
+			Function: openAIFunction{Name: t.Name, Description: t.Description, Parameters: t.Parameters},
+		})
+	}
+
 	return openAIChatRequest{
-		Messages:  messages,
-		Model:     model,
-		MaxTokens: chat.MaxTokens,
+		Messages:    messages,
+		Model:       chat.modelOrDefault(model),
+		MaxTokens:   chat.MaxTokens,
+		Temperature: chat.Temperature,
+		Tools:       tools,
+	}
+}
+
+func toOpenAIToolCalls(calls []ToolCall) []openAIToolCall {
+	var result []openAIToolCall
+	for _, c := range calls {
+		call := openAIToolCall{ID: c.ID, Type: "function"}
+		call.Function.Name = c.Name
+		call.Function.Arguments = c.Arguments
+		result = append(result, call)
 	}
+	return result
+}
+
+func fromOpenAIToolCalls(calls []openAIToolCall) []ToolCall {
+	var result []ToolCall
+	for _, c := range calls {
+		result = append(result, ToolCall{ID: c.ID, Name: c.Function.Name, Arguments: c.Function.Arguments})
+	}
+	return result
 }
 
 type OpenAIProviderWithStructuredOutput struct {
@@ -113,14 +168,17 @@ type OpenAIProviderWithStructuredOutput struct {
 func (o *OpenAIProviderWithStructuredOutput) GetResponse(ctx context.Context, req StructuredChatRequest) (ChatResponse, error) {
 	openAIReq := newOpenAIWithStructuredOutputProviderRequest(req, o.model)
 
-	return o.executeRequest(ctx, "/responses", openAIReq, func(body []byte) (ChatResponse, error) {
+	return o.executeRequest(ctx, "/responses", openAIReq.Model, openAIReq, func(body []byte) (ChatResponse, error) {
 		var result structuredOpenAIResponse
 		if err := json.Unmarshal(body, &result); err != nil {
 			return ChatResponse{}, fmt.Errorf("error parsing response: %w", err)
 		}
 
+		text, toolCalls := result.Output.parse()
 		response := ChatResponse{
-			Response: result.Output[0].Content[0].Text,
+			Response:  text,
+			ToolCalls: toolCalls,
+			Model:     openAIReq.Model,
 			TokenUsage: TokenUsage{
 				InputTokens:  result.Usage.PromptTokens,
 				OutputTokens: result.Usage.CompletionTokens,
@@ -139,18 +197,43 @@ func NewOpenAIWithStructuredOutputProvider(apiKey, model string, baseURL string)
 
 	return &OpenAIProviderWithStructuredOutput{
 		&OpenAIProvider{
-			apiKey:     apiKey,
-			baseURL:    baseURL,
-			model:      model,
-			httpClient: &http.Client{Timeout: defaultTimeout},
+			apiKey:    apiKey,
+			baseURL:   baseURL,
+			model:     model,
+			apiClient: newAPIClient(),
 		}}
 }
 
 type openAIWithStructuredOutputProviderChatRequest struct {
-	Model     string                                          `json:"model"`
-	MaxTokens int                                             `json:"max_output_tokens,omitempty"`
-	Input     []openAIWithStructuredOutputProviderChatMessage `json:"input"`
-	Text      TextFormat                                      `json:"text"`
+	Model       string   `json:"model"`
+	MaxTokens   int      `json:"max_output_tokens,omitempty"`
+	Temperature *float64 `json:"temperature,omitempty"`
+	// Input holds messages, function calls and function call outputs.
+	Input  []any                 `json:"input"`
+	Text   TextFormat            `json:"text"`
+	Tools  []openAIResponsesTool `json:"tools,omitempty"`
+	Stream bool                  `json:"stream,omitempty"`
+}
+
+type openAIResponsesTool struct {
+	Type        string `json:"type"`
+	Name        string `json:"name"`
+	Description string `json:"description,omitempty"`
+	Parameters  any    `json:"parameters,omitempty"`
+	Strict      bool   `json:"strict"`
+}
+
+type openAIResponsesFunctionCall struct {
+	Type      string `json:"type"`
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+type openAIResponsesFunctionCallOutput struct {
+	Type   string `json:"type"`
+	CallID string `json:"call_id"`
+	Output string `json:"output"`
 }
 
 type openAIWithStructuredOutputProviderChatMessage struct {
@@ -176,27 +259,81 @@ type openAIWithStructuredOutputProviderTokenUsage struct {
 }
 
 type structuredOpenAIResponse struct {
-	Output []struct {
-		Content []struct {
-			Text string `json:"text"`
-		} `json:"content"`
-	} `json:"output"`
-	Usage openAIWithStructuredOutputProviderTokenUsage `json:"usage"`
+	Output openAIResponsesOutput                        `json:"output"`
+	Usage  openAIWithStructuredOutputProviderTokenUsage `json:"usage"`
+}
+
+// openAIResponsesOutput lists the output items of the responses endpoint: messages and
+// function calls.
+type openAIResponsesOutput []struct {
+	Type    string `json:"type"`
+	Content []struct {
+		Text string `json:"text"`
+	} `json:"content"`
+	CallID    string `json:"call_id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+func (output openAIResponsesOutput) parse() (string, []ToolCall) {
+	var text strings.Builder
+	var toolCalls []ToolCall
+	for _, item := range output {
+		if item.Type == "function_call" {
+			toolCalls = append(toolCalls, ToolCall{ID: item.CallID, Name: item.Name, Arguments: item.Arguments})
+			continue
+		}
+		for _, c := range item.Content {
+			text.WriteString(c.Text)
+		}
+	}
+	return text.String(), toolCalls
 }
 
 func newOpenAIWithStructuredOutputProviderRequest(chat StructuredChatRequest, model string) openAIWithStructuredOutputProviderChatRequest {
-	messages := make([]openAIWithStructuredOutputProviderChatMessage, len(chat.Messages))
-	for i, msg := range chat.Messages {
-		messages[i] = openAIWithStructuredOutputProviderChatMessage{
-			Role:    msg.Role,
-			Content: msg.Content,
+	var input []any
+	for _, msg := range chat.Messages {
+		if msg.Role == RoleTool {
+			input = append(input, openAIResponsesFunctionCallOutput{
+				Type:   "function_call_output",
+				CallID: msg.ToolCallID,
+				Output: msg.Content,
+			})
+			continue
 		}
+
+		if msg.Content != "" || len(msg.ToolCalls) == 0 {
+			input = append(input, openAIWithStructuredOutputProviderChatMessage{
+				Role:    msg.Role,
+				Content: msg.Content,
+			})
+		}
+		for _, c := range msg.ToolCalls {
+			input = append(input, openAIResponsesFunctionCall{
+				Type:      "function_call",
+				CallID:    c.ID,
+				Name:      c.Name,
+				Arguments: c.Arguments,
+			})
+		}
+	}
+
+	var tools []openAIResponsesTool
+	for _, t := range chat.Tools {
+		tools = append(tools, openAIResponsesTool{
+			Type:        "function",
+			Name:        t.Name,
+			Description: t.Description,
+			Parameters:  t.Parameters,
+		})
 	}
 
 	return openAIWithStructuredOutputProviderChatRequest{
-		Model:     model,
-		MaxTokens: chat.MaxTokens,
-		Input:     messages,
+		Model:       chat.modelOrDefault(model),
+		MaxTokens:   chat.MaxTokens,
+		Temperature: chat.Temperature,
+		Input:       input,
+		Tools:       tools,
 		Text: TextFormat{
 			Format: FormatDetail{
 				Type:   "json_schema",
@@ -208,65 +345,32 @@ func newOpenAIWithStructuredOutputProviderRequest(chat StructuredChatRequest, mo
 	}
 }
 
-type responseParser func([]byte) (ChatResponse, error)
-
-func (o *OpenAIProvider) executeRequest(ctx context.Context, endpoint string, requestBodyData any, parseResponse responseParser) (ChatResponse, error) {
-	logger := loggerutils.GetLogger(ctx)
-	startTime := time.Now()
-
-	requestBody, err := json.Marshal(requestBodyData)
-	if err != nil {
-		return ChatResponse{}, fmt.Errorf("error marshaling request: %w", err)
-	}
-
-	httpReq, err := http.NewRequestWithContext(ctx,
-		"POST",
-		o.baseURL+endpoint,
-		bytes.NewReader(requestBody),
-	)
-	if err != nil {
-		return ChatResponse{}, fmt.Errorf("error creating request: %w", err)
-	}
-
-	httpReq.Header.Set("Content-Type", "application/json")
-	httpReq.Header.Set("Authorization", "Bearer "+o.apiKey)
-
-	var resp *http.Response
-	for i := range maxRetries {
-		resp, err = o.httpClient.Do(httpReq)
-		if err == nil {
-			break
-		}
-		logger.Warn("Request failed, retrying...", "attempt", i+1, "error", err)
-		time.Sleep(retryDelay)
-	}
-	if err != nil {
-		return ChatResponse{}, fmt.Errorf("error sending request: %w", err)
-	}
-	defer resp.Body.Close()
-
-	if resp.StatusCode != http.StatusOK {
-		body, err := io.ReadAll(resp.Body)
-		if err != nil {
-			return ChatResponse{}, fmt.Errorf("error reading response body: %w", err)
-		}
-		return ChatResponse{}, fmt.Errorf(
-			"non-200 status code: %d; body: %s",
-			resp.StatusCode,
-			string(body),
-		)
-	}
-
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return ChatResponse{}, fmt.Errorf("error reading response body: %w", err)
-	}
-
-	chatResponse, err := parseResponse(body)
-	if err != nil {
-		return ChatResponse{}, err
-	}
+// executeStream is executeRequest for streamed responses. onEvent fills the response,
+// its model and token usage go to the span of the request.
+func (o *OpenAIProvider) executeStream(
+	ctx context.Context,
+	endpoint string,
+	response *ChatResponse,
+	requestBodyData any,
+	onEvent func(sseEvent) error,
+) (time.Duration, error) {
+	ctx, span := startRequestSpan(ctx, "OpenAIProvider.executeStream", "openai", response.Model, true)
+	headers := map[string]string{"Authorization": "Bearer " + o.apiKey}
+	timeTaken, err := o.postStream(ctx, o.baseURL+endpoint, headers, requestBodyData, onEvent)
+	endRequestSpan(span, *response, err)
+	return timeTaken, err
+}
 
-	chatResponse.TimeTaken = time.Since(startTime)
-	return chatResponse, nil
+func (o *OpenAIProvider) executeRequest(
+	ctx context.Context,
+	endpoint string,
+	model string,
+	requestBodyData any,
+	parseResponse responseParser,
+) (ChatResponse, error) {
+	ctx, span := startRequestSpan(ctx, "OpenAIProvider.executeRequest", "openai", model, false)
+	headers := map[string]string{"Authorization": "Bearer " + o.apiKey}
+	resp, err := o.postJSON(ctx, o.baseURL+endpoint, headers, requestBodyData, parseResponse)
+	endRequestSpan(span, resp, err)
+	return resp, err
 }
@@ -0,0 +1,153 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// StreamCompletion streams a chat completion. Usage is requested with stream_options, it
+// comes in the last chunk.
+func (o *OpenAIProvider) StreamCompletion(ctx context.Context, req ChatRequest, onDelta StreamHandler) (ChatResponse, error) {
+	openAIReq := newOpenAIRequest(req, o.model)
+	openAIReq.Stream = true
+	openAIReq.StreamOptions = &openAIStreamOptions{IncludeUsage: true}
+
+	response := ChatResponse{Model: openAIReq.Model}
+	var text strings.Builder
+	// tool calls come in fragments identified by their index
+	var toolCalls []ToolCall
+
+	timeTaken, err := o.executeStream(ctx, "/chat/completions", &response, openAIReq, func(ev sseEvent) error {
+		if ev.Data == "[DONE]" {
+			return errStreamDone
+		}
+
+		var chunk openAIStreamChunk
+		if err := json.Unmarshal([]byte(ev.Data), &chunk); err != nil {
+			return fmt.Errorf("error parsing stream chunk: %w", err)
+		}
+		if chunk.Error != nil {
+			return fmt.Errorf("stream error: %s", chunk.Error.Message)
+		}
+
+		for _, c := range chunk.Choices {
+			if c.Delta.Content != "" {
+				text.WriteString(c.Delta.Content)
+				onDelta(c.Delta.Content)
+			}
+			for _, tc := range c.Delta.ToolCalls {
+				for len(toolCalls) <= tc.Index {
+					toolCalls = append(toolCalls, ToolCall{})
+				}
+				call := &toolCalls[tc.Index]
+				call.ID += tc.ID
+				call.Name += tc.Function.Name
+				call.Arguments += tc.Function.Arguments
+			}
+		}
+		if chunk.Usage != nil {
+			response.TokenUsage = TokenUsage{
+				InputTokens:  chunk.Usage.PromptTokens,
+				OutputTokens: chunk.Usage.CompletionTokens,
+				TotalTokens:  chunk.Usage.TotalTokens,
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return ChatResponse{}, err
+	}
+
+	response.Response = text.String()
+	response.ToolCalls = toolCalls
+	response.TimeTaken = timeTaken
+	return response, nil
+}
+
+type openAIStreamChunk struct {
+	Choices []struct {
+		Delta struct {
+			Content   string `json:"content"`
+			ToolCalls []struct {
+				Index    int    `json:"index"`
+				ID       string `json:"id"`
+				Function struct {
+					Name      string `json:"name"`
+					Arguments string `json:"arguments"`
+				} `json:"function"`
+			} `json:"tool_calls"`
+		} `json:"delta"`
+	} `json:"choices"`
+	Usage *openAITokenUsage  `json:"usage"`
+	Error *openAIStreamError `json:"error"`
+}
+
+type openAIStreamError struct {
+	Message string `json:"message"`
+}
+
+// StreamResponse streams a structured response of the responses endpoint. Text deltas
+// come in response.output_text.delta events and usage in the final response.completed.
+func (o *OpenAIProviderWithStructuredOutput) StreamResponse(ctx context.Context, req StructuredChatRequest, onDelta StreamHandler) (ChatResponse, error) {
+	openAIReq := newOpenAIWithStructuredOutputProviderRequest(req, o.model)
+	openAIReq.Stream = true
+
+	response := ChatResponse{Model: openAIReq.Model}
+	var text strings.Builder
+	completed := false
+
+	timeTaken, err := o.executeStream(ctx, "/responses", &response, openAIReq, func(ev sseEvent) error {
+		var event openAIResponsesStreamEvent
+		if err := json.Unmarshal([]byte(ev.Data), &event); err != nil {
+			return fmt.Errorf("error parsing stream event: %w", err)
+		}
+
+		switch event.Type {
+		case "response.output_text.delta":
+			text.WriteString(event.Delta)
+			onDelta(event.Delta)
+		case "response.completed":
+			completed = true
+			_, response.ToolCalls = event.Response.Output.parse()
+			response.TokenUsage = TokenUsage{
+				InputTokens:  event.Response.Usage.PromptTokens,
+				OutputTokens: event.Response.Usage.CompletionTokens,
+				TotalTokens:  event.Response.Usage.TotalTokens,
+			}
+			return errStreamDone
+		case "response.failed", "response.incomplete":
+			message := event.Type
+			if event.Response.Error != nil {
+				message = event.Response.Error.Message
+			}
+			return fmt.Errorf("stream error: %s", message)
+		case "error":
+			return fmt.Errorf("stream error: %s", event.Message)
+		}
+		return nil
+	})
+	if err != nil {
+		return ChatResponse{}, err
+	}
+	if !completed {
+		return ChatResponse{}, errors.New("stream ended before the response was completed")
+	}
+
+	response.Response = text.String()
+	response.TimeTaken = timeTaken
+	return response, nil
+}
+
+type openAIResponsesStreamEvent struct {
+	Type     string `json:"type"`
+	Delta    string `json:"delta"`
+	Message  string `json:"message"`
+	Response struct {
+		Output openAIResponsesOutput                        `json:"output"`
+		Usage  openAIWithStructuredOutputProviderTokenUsage `json:"usage"`
+		Error  *openAIStreamError                           `json:"error"`
+	} `json:"response"`
+}
@@ -0,0 +1,232 @@
+package llm
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeHTTPClient returns the prepared responses in order and records request bodies.
+type fakeHTTPClient struct {
+	responses []fakeResponse
+	bodies    []string
+}
+
+type fakeResponse struct {
+	statusCode int
+	header     http.Header
+	body       string
+	err        error
+}
+
+func (f *fakeHTTPClient) Do(req *http.Request) (*http.Response, error) {
+	body, err := io.ReadAll(req.Body)
+	if err != nil {
+		return nil, err
+	}
+	f.bodies = append(f.bodies, string(body))
+
+	r := f.responses[0]
+	if len(f.responses) > 1 {
+		f.responses = f.responses[1:]
+	}
+	if r.err != nil {
+		return nil, r.err
+	}
+
+	header := r.header
+	if header == nil {
+		header = http.Header{}
+	}
+	return &http.Response{
+		StatusCode: r.statusCode,
+		Header:     header,
+		Body:       io.NopCloser(strings.NewReader(r.body)),
+	}, nil
+}
+
+func testRetryPolicy() RetryPolicy {
+	policy := DefaultRetryPolicy()
+	policy.InitialBackoff = time.Millisecond
+	policy.MaxBackoff = 5 * time.Millisecond
+	policy.Jitter = 0
+	return policy
+}
+
+const okCompletion = `{"choices": [{"message": {"content": "done"}}], "usage": {"total_tokens": 3}}`
+
+func newTestOpenAIProvider(client HTTPClient, policy RetryPolicy) *OpenAIProvider {
+	provider := NewOpenAIProvider("key", "model", "http://example.com")
+	provider.httpClient = client
+	provider.streamHTTPClient = client
+	provider.SetRetryPolicy(policy)
+	return provider
+}
+
+func TestRetryOnRetryableStatus(t *testing.T) {
+	client := &fakeHTTPClient{responses: []fakeResponse{
+		{statusCode: http.StatusTooManyRequests, body: "slow down"},
+		{statusCode: http.StatusServiceUnavailable, body: "unavailable"},
+		{err: errors.New("connection reset")},
+		{statusCode: http.StatusOK, body: okCompletion},
+	}}
+	provider := newTestOpenAIProvider(client, testRetryPolicy())
+
+	resp, err := provider.GetCompletion(context.Background(), ChatRequest{BaseChatRequest{
+		Messages: []ChatMessage{{Role: "user", Content: "Hi"}},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Response != "done" {
+		t.Errorf("expected 'done', got '%s'", resp.Response)
+	}
+
+	if len(client.bodies) != 4 {
+		t.Fatalf("expected 4 attempts, got %d", len(client.bodies))
+	}
+	for i, body := range client.bodies {
+		if body == "" || body != client.bodies[0] {
+			t.Errorf("attempt %d sent a different body: '%s'", i+1, body)
+		}
+	}
+}
+
+func TestNoRetryOnClientError(t *testing.T) {
+	client := &fakeHTTPClient{responses: []fakeResponse{
+		{statusCode: http.StatusBadRequest, body: "bad request"},
+	}}
+	provider := newTestOpenAIProvider(client, testRetryPolicy())
+
+	_, err := provider.GetCompletion(context.Background(), ChatRequest{})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusBadRequest {
+		t.Fatalf("expected APIError with status 400, got %v", err)
+	}
+	if len(client.bodies) != 1 {
+		t.Errorf("expected a single attempt, got %d", len(client.bodies))
+	}
+}
+
+func TestAPIErrorTruncatesBody(t *testing.T) {
+	body := strings.Repeat("x", maxErrorBodyLength+100)
+	err := &APIError{StatusCode: http.StatusBadGateway, Body: body}
+
+	want := fmt.Sprintf("non-200 status code: 502; body: %s... (%d bytes)",
+		body[:maxErrorBodyLength], len(body))
+	if err.Error() != want {
+		t.Errorf("expected %q, got %q", want, err.Error())
+	}
+	if err.Body != body {
+		t.Errorf("expected the whole body to be kept")
+	}
+}
+
+func TestRetryGivesUpAfterMaxAttempts(t *testing.T) {
+	client := &fakeHTTPClient{responses: []fakeResponse{
+		{statusCode: http.StatusInternalServerError, body: "oops"},
+	}}
+	policy := testRetryPolicy()
+	policy.MaxAttempts = 3
+	provider := newTestOpenAIProvider(client, policy)
+
+	_, err := provider.GetCompletion(context.Background(), ChatRequest{})
+
+	var apiErr *APIError
+	if !errors.As(err, &apiErr) || apiErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("expected APIError with status 500, got %v", err)
+	}
+	if len(client.bodies) != 3 {
+		t.Errorf("expected 3 attempts, got %d", len(client.bodies))
+	}
+}
+
+func TestRetryHonorsMaxElapsedTimeAndRetryAfter(t *testing.T) {
+	client := &fakeHTTPClient{responses: []fakeResponse{
+		{statusCode: http.StatusTooManyRequests, header: http.Header{"Retry-After": []string{"120"}}},
+	}}
+	policy := testRetryPolicy()
+	policy.MaxElapsedTime = time.Second
+	provider := newTestOpenAIProvider(client, policy)
+
+	start := time.Now()
+	_, err := provider.GetCompletion(context.Background(), ChatRequest{})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if time.Since(start) > 500*time.Millisecond {
+		t.Errorf("expected to give up without waiting for Retry-After, took %s", time.Since(start))
+	}
+	if len(client.bodies) != 1 {
+		t.Errorf("expected a single attempt, got %d", len(client.bodies))
+	}
+}
+
+func TestRetryStopsWhenContextIsCanceled(t *testing.T) {
+	client := &fakeHTTPClient{responses: []fakeResponse{
+		{statusCode: http.StatusServiceUnavailable},
+	}}
+	policy := testRetryPolicy()
+	policy.InitialBackoff = time.Minute
+	policy.MaxBackoff = time.Minute
+	provider := newTestOpenAIProvider(client, policy)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := provider.GetCompletion(ctx, ChatRequest{})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Fatalf("expected context deadline error, got %v", err)
+	}
+	if time.Since(start) > time.Second {
+		t.Errorf("expected backoff to be interrupted, took %s", time.Since(start))
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)
+
+	tests := map[string]time.Duration{
+		"":                              0,
+		"7":                             7 * time.Second,
+		"-1":                            0,
+		"Wed, 01 Jan 2025 12:00:30 GMT": 30 * time.Second,
+		"Wed, 01 Jan 2025 11:00:00 GMT": 0,
+		"soon":                          0,
+	}
+
+	for header, expected := range tests {
+		if got := parseRetryAfter(header, now); got != expected {
+			t.Errorf("header '%s': expected %s, got %s", header, expected, got)
+		}
+	}
+}
+
+func TestRetryPolicyBackoff(t *testing.T) {
+	policy := RetryPolicy{
+		InitialBackoff: time.Second,
+		MaxBackoff:     5 * time.Second,
+		Multiplier:     2,
+	}
+
+	expected := []time.Duration{time.Second, 2 * time.Second, 4 * time.Second, 5 * time.Second}
+	for i, e := range expected {
+		if got := policy.backoff(i + 1); got != e {
+			t.Errorf("attempt %d: expected %s, got %s", i+1, e, got)
+		}
+	}
+
+	policy.Jitter = 0.5
+	for range 100 {
+		if got := policy.backoff(1); got < 500*time.Millisecond || got > 1500*time.Millisecond {
+			t.Fatalf("jittered backoff out of range: %s", got)
+		}
+	}
+}
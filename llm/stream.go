@@ -0,0 +1,202 @@
+package llm
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"maps"
+	"strings"
+	"time"
+)
+
+// streamIdleTimeout cancels a stream that has not sent anything for this long.
+const streamIdleTimeout = defaultTimeout
+
+// maxSSELineSize limits a single line of a server-sent events stream.
+const maxSSELineSize = 1024 * 1024
+
+// StreamHandler receives the text deltas of a response as they are generated.
+type StreamHandler func(delta string)
+
+// StreamingLLMProvider is implemented by providers able to stream completions. The
+// returned response is the same as the one of GetCompletion.
+type StreamingLLMProvider interface {
+	LLMProvider
+	StreamCompletion(ctx context.Context, req ChatRequest, onDelta StreamHandler) (ChatResponse, error)
+}
+
+// StreamingStructuredLLMProvider is implemented by providers able to stream structured
+// responses.
+type StreamingStructuredLLMProvider interface {
+	StructuredLLMProvider
+	StreamResponse(ctx context.Context, req StructuredChatRequest, onDelta StreamHandler) (ChatResponse, error)
+}
+
+// Streaming returns the provider as a StreamingLLMProvider. Providers without streaming
+// support are adapted: the whole response is delivered as a single delta once it is
+// complete.
+func Streaming(provider LLMProvider) StreamingLLMProvider {
+	if sp, ok := provider.(StreamingLLMProvider); ok {
+		return sp
+	}
+	return streamingAdapter{LLMProvider: provider}
+}
+
+// StreamingStructured is Streaming for structured responses.
+func StreamingStructured(provider StructuredLLMProvider) StreamingStructuredLLMProvider {
+	if sp, ok := provider.(StreamingStructuredLLMProvider); ok {
+		return sp
+	}
+	return structuredStreamingAdapter{StructuredLLMProvider: provider}
+}
+
+type streamingAdapter struct {
+	LLMProvider
+}
+
+func (a streamingAdapter) StreamCompletion(ctx context.Context, req ChatRequest, onDelta StreamHandler) (ChatResponse, error) {
+	resp, err := a.GetCompletion(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Response != "" {
+		onDelta(resp.Response)
+	}
+	return resp, nil
+}
+
+type structuredStreamingAdapter struct {
+	StructuredLLMProvider
+}
+
+func (a structuredStreamingAdapter) StreamResponse(ctx context.Context, req StructuredChatRequest, onDelta StreamHandler) (ChatResponse, error) {
+	resp, err := a.GetResponse(ctx, req)
+	if err != nil {
+		return resp, err
+	}
+	if resp.Response != "" {
+		onDelta(resp.Response)
+	}
+	return resp, nil
+}
+
+// sseEvent is a single server-sent event.
+type sseEvent struct {
+	Event string
+	Data  string
+}
+
+// errStreamDone is returned by an event handler to stop reading a stream early.
+var errStreamDone = errors.New("stream done")
+
+// postStream sends requestBodyData as a JSON POST request to url and passes the events
+// of the server-sent events response to onEvent. Only failures before the stream starts
+// are retried, so no delta is delivered twice. It returns the time taken by the request.
+func (c *apiClient) postStream(
+	ctx context.Context,
+	url string,
+	headers map[string]string,
+	requestBodyData any,
+	onEvent func(sseEvent) error,
+) (time.Duration, error) {
+	startTime := time.Now()
+
+	requestBody, err := json.Marshal(requestBodyData)
+	if err != nil {
+		return 0, fmt.Errorf("error marshaling request: %w", err)
+	}
+
+	// the stream context is canceled also when the stream stays idle for too long
+	streamCtx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	streamHeaders := map[string]string{"Accept": "text/event-stream"}
+	maps.Copy(streamHeaders, headers)
+
+	var body io.ReadCloser
+	err = c.withRetry(streamCtx, startTime, func() (time.Duration, error) {
+		resp, retryAfter, err := c.send(streamCtx, c.streamHTTPClient, url, streamHeaders, requestBody)
+		if err != nil {
+			return retryAfter, err
+		}
+		body = resp.Body
+		return 0, nil
+	})
+	if err != nil {
+		return 0, err
+	}
+	defer body.Close()
+
+	idle := time.AfterFunc(streamIdleTimeout, cancel)
+	defer idle.Stop()
+
+	err = readSSE(body, func(ev sseEvent) error {
+		idle.Reset(streamIdleTimeout)
+		return onEvent(ev)
+	})
+	if err != nil && streamCtx.Err() != nil && ctx.Err() == nil {
+		return 0, fmt.Errorf("error reading stream, no event for %s: %w", streamIdleTimeout, err)
+	}
+	if err != nil {
+		return 0, err
+	}
+
+	return time.Since(startTime), nil
+}
+
+// readSSE parses a server-sent events stream and calls onEvent for every event with data.
+func readSSE(r io.Reader, onEvent func(sseEvent) error) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxSSELineSize)
+
+	var event string
+	var data []string
+	dispatch := func() error {
+		defer func() {
+			event = ""
+			data = nil
+		}()
+		if len(data) == 0 {
+			return nil
+		}
+		return onEvent(sseEvent{Event: event, Data: strings.Join(data, "\n")})
+	}
+
+	for scanner.Scan() {
+		line := scanner.Text()
+		if line == "" {
+			if err := dispatch(); err != nil {
+				return ignoreStreamDone(err)
+			}
+			continue
+		}
+		// lines starting with a colon are comments, e.g. keep-alives
+		if strings.HasPrefix(line, ":") {
+			continue
+		}
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+		switch field {
+		case "event":
+			event = value
+		case "data":
+			data = append(data, value)
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("error reading stream: %w", err)
+	}
+
+	return ignoreStreamDone(dispatch())
+}
+
+func ignoreStreamDone(err error) error {
+	if errors.Is(err, errStreamDone) {
+		return nil
+	}
+	return err
+}
@@ -0,0 +1,177 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestReadSSE(t *testing.T) {
+	stream := ": keep-alive\n" +
+		"event: first\n" +
+		"data: line 1\n" +
+		"data: line 2\n" +
+		"\n" +
+		"data: {\"a\": 1}\n" +
+		"\n" +
+		"data: no trailing blank line"
+
+	var events []sseEvent
+	err := readSSE(strings.NewReader(stream), func(ev sseEvent) error {
+		events = append(events, ev)
+		return nil
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := []sseEvent{
+		{Event: "first", Data: "line 1\nline 2"},
+		{Data: `{"a": 1}`},
+		{Data: "no trailing blank line"},
+	}
+	if len(events) != len(expected) {
+		t.Fatalf("expected %d events, got %d: %v", len(expected), len(events), events)
+	}
+	for i := range expected {
+		if events[i] != expected[i] {
+			t.Errorf("event %d: expected %+v, got %+v", i, expected[i], events[i])
+		}
+	}
+}
+
+func TestOpenAIStreamCompletion(t *testing.T) {
+	stream := `data: {"choices": [{"delta": {"role": "assistant", "content": ""}}], "usage": null}
+
+data: {"choices": [{"delta": {"content": "Hel"}}], "usage": null}
+
+data: {"choices": [{"delta": {"content": "lo"}}], "usage": null}
+
+data: {"choices": [], "usage": {"prompt_tokens": 5, "completion_tokens": 2, "total_tokens": 7}}
+
+data: [DONE]
+
+`
+	client := &fakeHTTPClient{responses: []fakeResponse{
+		{statusCode: http.StatusTooManyRequests, body: "slow down"},
+		{statusCode: http.StatusOK, body: stream},
+	}}
+	provider := newTestOpenAIProvider(client, testRetryPolicy())
+
+	var deltas []string
+	resp, err := provider.StreamCompletion(context.Background(), ChatRequest{BaseChatRequest{
+		Messages: []ChatMessage{{Role: "user", Content: "Hi"}},
+	}}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if resp.Response != "Hello" {
+		t.Errorf("expected response 'Hello', got %q", resp.Response)
+	}
+	if strings.Join(deltas, "|") != "Hel|lo" {
+		t.Errorf("unexpected deltas %v", deltas)
+	}
+	if resp.TokenUsage.TotalTokens != 7 || resp.TokenUsage.InputTokens != 5 {
+		t.Errorf("unexpected token usage %+v", resp.TokenUsage)
+	}
+	if !strings.Contains(client.bodies[1], `"stream":true`) || !strings.Contains(client.bodies[1], `"include_usage":true`) {
+		t.Errorf("streaming not requested: %s", client.bodies[1])
+	}
+}
+
+func TestOpenAIStreamResponse(t *testing.T) {
+	tests := []struct {
+		name      string
+		stream    string
+		expected  string
+		expectErr bool
+	}{
+		{
+			name: "completed",
+			stream: `event: response.created
+data: {"type": "response.created", "response": {}}
+
+event: response.output_text.delta
+data: {"type": "response.output_text.delta", "delta": "{\"a\":"}
+
+event: response.output_text.delta
+data: {"type": "response.output_text.delta", "delta": " 1}"}
+
+event: response.completed
+data: {"type": "response.completed", "response": {"usage": {"input_tokens": 3, "output_tokens": 4, "total_tokens": 7}}}
+
+`,
+			expected: `{"a": 1}`,
+		},
+		{
+			name: "failed",
+			stream: `event: response.failed
+data: {"type": "response.failed", "response": {"error": {"message": "server error"}}}
+
+`,
+			expectErr: true,
+		},
+		{
+			name: "interrupted",
+			stream: `event: response.output_text.delta
+data: {"type": "response.output_text.delta", "delta": "{"}
+
+`,
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			client := &fakeHTTPClient{responses: []fakeResponse{{statusCode: http.StatusOK, body: tt.stream}}}
+			provider := &OpenAIProviderWithStructuredOutput{newTestOpenAIProvider(client, testRetryPolicy())}
+
+			var streamed strings.Builder
+			resp, err := provider.StreamResponse(context.Background(), StructuredChatRequest{
+				BaseChatRequest: BaseChatRequest{Messages: []ChatMessage{{Role: "user", Content: "Hi"}}},
+				Name:            "answer",
+			}, func(delta string) {
+				streamed.WriteString(delta)
+			})
+			if tt.expectErr {
+				if err == nil {
+					t.Fatalf("expected error, got response %+v", resp)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if resp.Response != tt.expected || streamed.String() != tt.expected {
+				t.Errorf("expected %q, got response %q and deltas %q", tt.expected, resp.Response, streamed.String())
+			}
+			if resp.TokenUsage.TotalTokens != 7 {
+				t.Errorf("expected 7 tokens, got %d", resp.TokenUsage.TotalTokens)
+			}
+		})
+	}
+}
+
+func TestStreamingAdapter(t *testing.T) {
+	provider := Streaming(&MockLLMProvider{
+		GetCompletionFunc: func(ctx context.Context, req ChatRequest) (ChatResponse, error) {
+			return ChatResponse{Response: "whole answer"}, nil
+		},
+	})
+
+	var deltas []string
+	resp, err := provider.StreamCompletion(context.Background(), ChatRequest{}, func(delta string) {
+		deltas = append(deltas, delta)
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if resp.Response != "whole answer" || len(deltas) != 1 || deltas[0] != "whole answer" {
+		t.Errorf("unexpected response %q and deltas %v", resp.Response, deltas)
+	}
+}
@@ -0,0 +1,34 @@
+package llm
+
+import "fmt"
+
+// RoleTool is the role of messages carrying tool results.
+const RoleTool = "tool"
+
+// Tool is a function the model can call. Parameters is the JSON schema of its arguments.
+type Tool struct {
+	Name        string
+	Description string
+	Parameters  any
+}
+
+// ToolCall is a call of a tool requested by the model, Arguments are JSON encoded.
+type ToolCall struct {
+	ID        string
+	Name      string
+	Arguments string
+}
+
+// ToolResultMessage returns the message passing the result of a tool call to the model.
+func ToolResultMessage(callID string, result string) ChatMessage {
+	return ChatMessage{Role: RoleTool, Content: result, ToolCallID: callID}
+}
+
+// errToolsUnsupported is returned by providers that cannot call tools, so a misconfigured
+// assistant fails instead of silently answering without them.
+func (r BaseChatRequest) errToolsUnsupported(provider string) error {
+	if len(r.Tools) == 0 {
+		return nil
+	}
+	return fmt.Errorf("tool calling is not supported by the %s provider", provider)
+}
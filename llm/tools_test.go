@@ -0,0 +1,144 @@
+package llm
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+)
+
+var testToolRequest = BaseChatRequest{
+	Messages: []ChatMessage{
+		{Role: "user", Content: "What is in main.py?"},
+		{Role: "assistant", ToolCalls: []ToolCall{{ID: "call_1", Name: "read_file", Arguments: `{"path":"main.py"}`}}},
+		ToolResultMessage("call_1", "print('hi')"),
+	},
+	Tools: []Tool{{
+		Name:        "read_file",
+		Description: "Reads a file",
+		Parameters:  map[string]any{"type": "object"},
+	}},
+}
+
+func TestOpenAIToolCalls(t *testing.T) {
+	client := &fakeHTTPClient{responses: []fakeResponse{{statusCode: http.StatusOK, body: `{
+		"choices": [{"message": {"content": "", "tool_calls": [
+			{"id": "call_2", "type": "function", "function": {"name": "list_files", "arguments": "{}"}}
+		]}}],
+		"usage": {"total_tokens": 3}
+	}`}}}
+	provider := newTestOpenAIProvider(client, testRetryPolicy())
+
+	resp, err := provider.GetCompletion(context.Background(), ChatRequest{testToolRequest})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := ToolCall{ID: "call_2", Name: "list_files", Arguments: "{}"}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0] != expected {
+		t.Errorf("expected tool call %+v, got %+v", expected, resp.ToolCalls)
+	}
+
+	var sent struct {
+		Messages []map[string]any `json:"messages"`
+		Tools    []map[string]any `json:"tools"`
+	}
+	if err := json.Unmarshal([]byte(client.bodies[0]), &sent); err != nil {
+		t.Fatalf("cannot parse request: %v", err)
+	}
+	if len(sent.Tools) != 1 || sent.Tools[0]["type"] != "function" {
+		t.Errorf("unexpected tools %v", sent.Tools)
+	}
+	if sent.Messages[1]["tool_calls"] == nil {
+		t.Errorf("assistant message without tool calls: %v", sent.Messages[1])
+	}
+	if sent.Messages[2]["role"] != "tool" || sent.Messages[2]["tool_call_id"] != "call_1" {
+		t.Errorf("unexpected tool result message: %v", sent.Messages[2])
+	}
+}
+
+func TestOpenAIEmptyChoices(t *testing.T) {
+	client := &fakeHTTPClient{responses: []fakeResponse{{statusCode: http.StatusOK, body: `{"choices": []}`}}}
+	provider := newTestOpenAIProvider(client, testRetryPolicy())
+
+	if _, err := provider.GetCompletion(context.Background(), ChatRequest{testToolRequest}); err == nil {
+		t.Fatal("expected an error for a response without choices")
+	}
+}
+
+func TestOpenAIResponsesToolCalls(t *testing.T) {
+	client := &fakeHTTPClient{responses: []fakeResponse{{statusCode: http.StatusOK, body: `{
+		"output": [
+			{"type": "function_call", "call_id": "call_2", "name": "list_files", "arguments": "{}"}
+		],
+		"usage": {"total_tokens": 3}
+	}`}}}
+	provider := &OpenAIProviderWithStructuredOutput{newTestOpenAIProvider(client, testRetryPolicy())}
+
+	resp, err := provider.GetResponse(context.Background(), StructuredChatRequest{BaseChatRequest: testToolRequest})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := ToolCall{ID: "call_2", Name: "list_files", Arguments: "{}"}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0] != expected {
+		t.Errorf("expected tool call %+v, got %+v", expected, resp.ToolCalls)
+	}
+
+	var sent struct {
+		Input []map[string]any `json:"input"`
+		Tools []map[string]any `json:"tools"`
+	}
+	if err := json.Unmarshal([]byte(client.bodies[0]), &sent); err != nil {
+		t.Fatalf("cannot parse request: %v", err)
+	}
+	if len(sent.Tools) != 1 || sent.Tools[0]["name"] != "read_file" {
+		t.Errorf("unexpected tools %v", sent.Tools)
+	}
+	expectedTypes := []any{nil, "function_call", "function_call_output"}
+	if len(sent.Input) != len(expectedTypes) {
+		t.Fatalf("expected %d input items, got %v", len(expectedTypes), sent.Input)
+	}
+	for i, typ := range expectedTypes {
+		if sent.Input[i]["type"] != typ {
+			t.Errorf("input %d: expected type %v, got %v", i, typ, sent.Input[i])
+		}
+	}
+}
+
+func TestOpenAIStreamToolCalls(t *testing.T) {
+	stream := `data: {"choices": [{"delta": {"tool_calls": [{"index": 0, "id": "call_1", "function": {"name": "read_file", "arguments": ""}}]}}]}
+
+data: {"choices": [{"delta": {"tool_calls": [{"index": 0, "function": {"arguments": "{\"path\":"}}]}}]}
+
+data: {"choices": [{"delta": {"tool_calls": [{"index": 0, "function": {"arguments": "\"main.py\"}"}}]}}]}
+
+data: [DONE]
+
+`
+	client := &fakeHTTPClient{responses: []fakeResponse{{statusCode: http.StatusOK, body: stream}}}
+	provider := newTestOpenAIProvider(client, testRetryPolicy())
+
+	resp, err := provider.StreamCompletion(context.Background(), ChatRequest{testToolRequest}, func(string) {})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	expected := ToolCall{ID: "call_1", Name: "read_file", Arguments: `{"path":"main.py"}`}
+	if len(resp.ToolCalls) != 1 || resp.ToolCalls[0] != expected {
+		t.Errorf("expected tool call %+v, got %+v", expected, resp.ToolCalls)
+	}
+}
+
+func TestToolsUnsupported(t *testing.T) {
+	providers := map[string]LLMProvider{
+		"anthropic": NewAnthropicProvider("key", "model", "http://example.com"),
+		"ollama":    NewOllamaProvider("model", "http://example.com"),
+	}
+	for name, provider := range providers {
+		_, err := provider.GetCompletion(context.Background(), ChatRequest{testToolRequest})
+		if err == nil {
+			t.Errorf("%s: expected error for tools", name)
+		}
+	}
+}
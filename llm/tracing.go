@@ -0,0 +1,46 @@
+package llm
+
+import (
+	"context"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
+)
+
+var tracer = otel.Tracer("github.com/aszmajdzinski/llm-feedback-loop-executor/llm")
+
+// Span attributes of API requests, named after the OpenTelemetry semantic conventions
+// where there is one.
+const (
+	attrSystem       = attribute.Key("gen_ai.system")
+	attrModel        = attribute.Key("gen_ai.request.model")
+	attrInputTokens  = attribute.Key("gen_ai.usage.input_tokens")
+	attrOutputTokens = attribute.Key("gen_ai.usage.output_tokens")
+	attrStatusCode   = attribute.Key("http.response.status_code")
+	attrRetryCount   = attribute.Key("llm.retry_count")
+	attrStream       = attribute.Key("llm.stream")
+)
+
+// startRequestSpan starts the span of an API request. The status code and the retries
+// are added to it by the apiClient, the tokens by endRequestSpan.
+func startRequestSpan(ctx context.Context, name string, system string, model string, stream bool) (context.Context, trace.Span) {
+	return tracer.Start(ctx, name,
+		trace.WithSpanKind(trace.SpanKindClient),
+		trace.WithAttributes(attrSystem.String(system), attrModel.String(model), attrStream.Bool(stream)),
+	)
+}
+
+func endRequestSpan(span trace.Span, resp ChatResponse, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(
+			attrInputTokens.Int(resp.TokenUsage.InputTokens),
+			attrOutputTokens.Int(resp.TokenUsage.OutputTokens),
+		)
+	}
+	span.End()
+}
@@ -0,0 +1,88 @@
+package llm
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/tracing/tracingtest"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+func spanAttributes(t *testing.T, spans tracetest.SpanStubs, name string) map[attribute.Key]attribute.Value {
+	t.Helper()
+	for _, s := range spans {
+		if s.Name == name {
+			attrs := map[attribute.Key]attribute.Value{}
+			for _, a := range s.Attributes {
+				attrs[a.Key] = a.Value
+			}
+			return attrs
+		}
+	}
+	t.Fatalf("no span %s in %d spans", name, len(spans))
+	return nil
+}
+
+func TestExecuteRequestSpan(t *testing.T) {
+	exporter := tracingtest.InMemory()
+	exporter.Reset()
+
+	client := &fakeHTTPClient{responses: []fakeResponse{
+		{statusCode: http.StatusTooManyRequests, body: "slow down"},
+		{statusCode: http.StatusOK, body: `{"choices": [{"message": {"content": "done"}}], "usage": {"prompt_tokens": 2, "completion_tokens": 1, "total_tokens": 3}}`},
+	}}
+	provider := newTestOpenAIProvider(client, testRetryPolicy())
+
+	_, err := provider.GetCompletion(context.Background(), ChatRequest{BaseChatRequest{
+		Model:    "gpt-4o",
+		Messages: []ChatMessage{{Role: "user", Content: "Hi"}},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	attrs := spanAttributes(t, spans, "OpenAIProvider.executeRequest")
+	if attrs[attrModel].AsString() != "gpt-4o" || attrs[attrSystem].AsString() != "openai" {
+		t.Errorf("unexpected model attributes %v", attrs)
+	}
+	if attrs[attrStatusCode].AsInt64() != http.StatusOK || attrs[attrRetryCount].AsInt64() != 1 {
+		t.Errorf("unexpected request attributes %v", attrs)
+	}
+	if attrs[attrInputTokens].AsInt64() != 2 || attrs[attrOutputTokens].AsInt64() != 1 {
+		t.Errorf("unexpected token attributes %v", attrs)
+	}
+	if len(spans[0].Events) != 1 || spans[0].Events[0].Name != "retry" {
+		t.Errorf("expected a retry event, got %v", spans[0].Events)
+	}
+}
+
+func TestExecuteStreamSpanFails(t *testing.T) {
+	exporter := tracingtest.InMemory()
+	exporter.Reset()
+
+	client := &fakeHTTPClient{responses: []fakeResponse{{statusCode: http.StatusBadRequest, body: "bad request"}}}
+	provider := newTestOpenAIProvider(client, testRetryPolicy())
+
+	_, err := provider.StreamCompletion(context.Background(), ChatRequest{BaseChatRequest{
+		Messages: []ChatMessage{{Role: "user", Content: "Hi"}},
+	}}, func(string) {})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	spans := exporter.GetSpans()
+	attrs := spanAttributes(t, spans, "OpenAIProvider.executeStream")
+	if attrs[attrModel].AsString() != "model" || !attrs[attrStream].AsBool() {
+		t.Errorf("unexpected attributes %v", attrs)
+	}
+	if attrs[attrStatusCode].AsInt64() != http.StatusBadRequest || attrs[attrRetryCount].AsInt64() != 0 {
+		t.Errorf("unexpected request attributes %v", attrs)
+	}
+	if spans[0].Status.Code != codes.Error {
+		t.Errorf("expected the span to fail, got %v", spans[0].Status)
+	}
+}
@@ -2,14 +2,69 @@ package loggerutils
 
 import (
 	"context"
+	"fmt"
+	"io"
 	"log/slog"
 	"os"
 )
 
-func SetupLogger() *slog.Logger {
-	logger := slog.New(slog.NewTextHandler(os.Stdout, &slog.HandlerOptions{Level: slog.LevelDebug}))
+// Setup configures the logs of a run.
+type Setup struct {
+	// Level is debug (default), info, warn or error.
+	Level string `yaml:"level"`
+	// Format is text (default) or json.
+	Format string `yaml:"format"`
+	// File appends the logs to a file instead of writing them to stdout.
+	File string `yaml:"file"`
+	// Redact lists regular expressions of values masked in the logs on top of the API keys
+	// and authorization headers, e.g. emails or internal tokens.
+	Redact []string `yaml:"redact"`
+}
+
+// SetupLogger creates the logger of the setup and makes it the default one, so the log
+// package writes through it as well. The secrets are masked in every record. closeLog
+// closes the log file.
+func SetupLogger(setup Setup, secrets []string) (logger *slog.Logger, closeLog func() error, err error) {
+	level := slog.LevelDebug
+	if setup.Level != "" {
+		if err := level.UnmarshalText([]byte(setup.Level)); err != nil {
+			return nil, nil, fmt.Errorf("invalid log level %q: %w", setup.Level, err)
+		}
+	}
+
+	jsonFormat := false
+	switch setup.Format {
+	case "", "text":
+	case "json":
+		jsonFormat = true
+	default:
+		return nil, nil, fmt.Errorf("unknown log format %q, expected text or json", setup.Format)
+	}
+
+	redactor, err := NewRedactor(secrets, setup.Redact)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var w io.Writer = os.Stdout
+	closeLog = func() error { return nil }
+	if setup.File != "" {
+		f, err := os.OpenFile(setup.File, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return nil, nil, fmt.Errorf("error opening log file: %w", err)
+		}
+		w = f
+		closeLog = f.Close
+	}
+
+	opts := &slog.HandlerOptions{Level: level}
+	var handler slog.Handler = slog.NewTextHandler(w, opts)
+	if jsonFormat {
+		handler = slog.NewJSONHandler(w, opts)
+	}
+	logger = slog.New(NewRedactingHandler(handler, redactor))
 	slog.SetDefault(logger)
-	return logger
+	return logger, closeLog, nil
 }
 
 type loggerKey struct{}
@@ -0,0 +1,11 @@
+package loggerutils
+
+import "testing"
+
+func TestSetupLogger_InvalidSetup(t *testing.T) {
+	for _, setup := range []Setup{{Level: "verbose"}, {Format: "xml"}, {Redact: []string{"["}}} {
+		if _, _, err := SetupLogger(setup, nil); err == nil {
+			t.Errorf("expected an error for %+v", setup)
+		}
+	}
+}
@@ -0,0 +1,137 @@
+package loggerutils
+
+import (
+	"context"
+	"fmt"
+	"log/slog"
+	"regexp"
+	"strings"
+)
+
+const redacted = "[REDACTED]"
+
+type redactRule struct {
+	re *regexp.Regexp
+	// replacement keeps the parts of the match around the secret, e.g. a header name
+	replacement string
+}
+
+// defaultRules match secrets that end up in logs through errors and dumped requests:
+// authorization and API key headers, and API keys in the format of OpenAI and Anthropic.
+var defaultRules = []redactRule{
+	{
+		re:          regexp.MustCompile(`(?i)((?:authorization|x-api-key|api[_-]?key)["']?\s*[:=]\s*["']?)(?:(?:bearer|basic)\s+)?[^\s"',;}]+`),
+		replacement: "${1}" + redacted,
+	},
+	{re: regexp.MustCompile(`\bsk-[A-Za-z0-9_-]{16,}`), replacement: redacted},
+}
+
+// sensitiveKeys are attribute keys whose values are always masked, compared in lower case
+// without dashes and underscores.
+var sensitiveKeys = map[string]bool{
+	"authorization": true,
+	"apikey":        true,
+	"xapikey":       true,
+	"token":         true,
+	"accesstoken":   true,
+	"password":      true,
+	"secret":        true,
+}
+
+// Redactor masks secrets in log messages and attributes.
+type Redactor struct {
+	secrets []string
+	rules   []redactRule
+}
+
+// NewRedactor masks the given secret values, the default rules and the matches of the
+// patterns.
+func NewRedactor(secrets []string, patterns []string) (*Redactor, error) {
+	r := &Redactor{rules: defaultRules}
+	for _, s := range secrets {
+		if s != "" {
+			r.secrets = append(r.secrets, s)
+		}
+	}
+	for _, p := range patterns {
+		re, err := regexp.Compile(p)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redact pattern %q: %w", p, err)
+		}
+		r.rules = append(r.rules, redactRule{re: re, replacement: redacted})
+	}
+	return r, nil
+}
+
+// String returns s with all secrets masked.
+func (r *Redactor) String(s string) string {
+	for _, secret := range r.secrets {
+		s = strings.ReplaceAll(s, secret, redacted)
+	}
+	for _, rule := range r.rules {
+		s = rule.re.ReplaceAllString(s, rule.replacement)
+	}
+	return s
+}
+
+func (r *Redactor) attr(a slog.Attr) slog.Attr {
+	if sensitiveKeys[strings.NewReplacer("-", "", "_", "").Replace(strings.ToLower(a.Key))] {
+		return slog.String(a.Key, redacted)
+	}
+
+	v := a.Value.Resolve()
+	switch v.Kind() {
+	case slog.KindString:
+		return slog.String(a.Key, r.String(v.String()))
+	case slog.KindGroup:
+		return slog.Attr{Key: a.Key, Value: slog.GroupValue(r.attrs(v.Group())...)}
+	case slog.KindAny:
+		// errors and other values are replaced by their text only when it holds a secret,
+		// so the JSON handler keeps marshaling them otherwise
+		s := fmt.Sprint(v.Any())
+		if masked := r.String(s); masked != s {
+			return slog.String(a.Key, masked)
+		}
+	}
+	return slog.Attr{Key: a.Key, Value: v}
+}
+
+func (r *Redactor) attrs(attrs []slog.Attr) []slog.Attr {
+	masked := make([]slog.Attr, len(attrs))
+	for i, a := range attrs {
+		masked[i] = r.attr(a)
+	}
+	return masked
+}
+
+type redactingHandler struct {
+	next     slog.Handler
+	redactor *Redactor
+}
+
+// NewRedactingHandler masks the secrets of the redactor in records before they are passed
+// to next.
+func NewRedactingHandler(next slog.Handler, redactor *Redactor) slog.Handler {
+	return &redactingHandler{next: next, redactor: redactor}
+}
+
+func (h *redactingHandler) Enabled(ctx context.Context, level slog.Level) bool {
+	return h.next.Enabled(ctx, level)
+}
+
+func (h *redactingHandler) Handle(ctx context.Context, record slog.Record) error {
+	masked := slog.NewRecord(record.Time, record.Level, h.redactor.String(record.Message), record.PC)
+	record.Attrs(func(a slog.Attr) bool {
+		masked.AddAttrs(h.redactor.attr(a))
+		return true
+	})
+	return h.next.Handle(ctx, masked)
+}
+
+func (h *redactingHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	return &redactingHandler{next: h.next.WithAttrs(h.redactor.attrs(attrs)), redactor: h.redactor}
+}
+
+func (h *redactingHandler) WithGroup(name string) slog.Handler {
+	return &redactingHandler{next: h.next.WithGroup(name), redactor: h.redactor}
+}
@@ -0,0 +1,83 @@
+package loggerutils
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+)
+
+func newTestLogger(t *testing.T, buf *bytes.Buffer, patterns ...string) *slog.Logger {
+	t.Helper()
+	redactor, err := NewRedactor([]string{"my-secret-key-123"}, patterns)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	handler := slog.NewJSONHandler(buf, &slog.HandlerOptions{
+		ReplaceAttr: func(groups []string, a slog.Attr) slog.Attr {
+			if a.Key == slog.TimeKey && len(groups) == 0 {
+				return slog.Attr{}
+			}
+			return a
+		},
+	})
+	return slog.New(NewRedactingHandler(handler, redactor))
+}
+
+func TestRedactingHandler(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTestLogger(t, &buf, `[\w.+-]+@[\w-]+\.[\w.]+`)
+
+	logger.With("request", "Authorization: Bearer abc.def").WithGroup("call").Info(
+		"calling with my-secret-key-123",
+		"error", errors.New(`non-200 status code: 401; body: {"error": "invalid key sk-proj-abcdefghijklmnopqrstuvwxyz"}`),
+		"user", "jane.doe@example.com",
+		"api_key", "short",
+		slog.Group("headers", "X-Api-Key", "anything"),
+		"attempt", 2,
+	)
+
+	var got map[string]any
+	if err := json.Unmarshal(buf.Bytes(), &got); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	want := map[string]any{
+		"level":   "INFO",
+		"msg":     "calling with [REDACTED]",
+		"request": "Authorization: [REDACTED]",
+		"call": map[string]any{
+			"error":   `non-200 status code: 401; body: {"error": "invalid key [REDACTED]"}`,
+			"user":    "[REDACTED]",
+			"api_key": "[REDACTED]",
+			"headers": map[string]any{"X-Api-Key": "[REDACTED]"},
+			"attempt": float64(2),
+		},
+	}
+	gotJSON, _ := json.Marshal(got)
+	wantJSON, _ := json.Marshal(want)
+	if !bytes.Equal(gotJSON, wantJSON) {
+		t.Errorf("expected %s, got %s", wantJSON, gotJSON)
+	}
+}
+
+func TestRedactingHandler_KeepsValuesWithoutSecrets(t *testing.T) {
+	var buf bytes.Buffer
+	logger := newTestLogger(t, &buf)
+
+	logger.Info("done", "usage", struct{ Tokens int }{Tokens: 10}, "error", errors.New("timeout"))
+
+	if !strings.Contains(buf.String(), `"usage":{"Tokens":10}`) {
+		t.Errorf("expected values without secrets to be kept, got %s", buf.String())
+	}
+	if !strings.Contains(buf.String(), `"error":"timeout"`) {
+		t.Errorf("expected the error, got %s", buf.String())
+	}
+}
+
+func TestNewRedactor_InvalidPattern(t *testing.T) {
+	if _, err := NewRedactor(nil, []string{"("}); err == nil {
+		t.Fatalf("expected an error for an invalid pattern")
+	}
+}
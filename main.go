@@ -2,132 +2,535 @@ package main
 
 import (
 	"context"
-	"flag"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
 
 	"github.com/aszmajdzinski/llm-feedback-loop-executor/assistants"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/budget"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/executor"
 	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
-	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	gitutils "github.com/aszmajdzinski/llm-feedback-loop-executor/git_utils"
 	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/metrics"
 	thinkingblock "github.com/aszmajdzinski/llm-feedback-loop-executor/thinking_block"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/transcript"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/verifier"
 	_ "github.com/joho/godotenv/autoload"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/trace"
 	"gopkg.in/yaml.v3"
 )
 
 type AppSetup struct {
-	Blocks []Block `yaml:"blocks"`
+	Providers map[string]ProviderSetup `yaml:"providers"`
+	// Templates overrides the built-in prompts for all blocks.
+	Templates thinkingblock.Templates `yaml:"templates"`
+	// Pricing overrides the built-in model prices (USD per 1M tokens).
+	Pricing usage.Pricing `yaml:"pricing"`
+	// Budget limits the whole run, blocks still running when it is reached keep their
+	// best answer so far.
+	Budget budget.Limits `yaml:"budget"`
+	// Executor sets up the isolation of commands run over generated files.
+	Executor executor.Setup `yaml:"executor"`
+	// OutputFiles limits the files a block with filesOutput writes.
+	OutputFiles fileutils.SaveLimits `yaml:"outputFiles"`
+	// Logging sets the level, format and destination of the logs, flags override it.
+	Logging loggerutils.Setup `yaml:"logging"`
+	Blocks  []Block           `yaml:"blocks"`
 }
 
 type Block struct {
 	Name        string `yaml:"name"`
 	Iterations  int    `yaml:"iterations"`
 	FilesOutput bool   `yaml:"filesOutput"`
-	Worker      struct {
-		Name   string `yaml:"name"`
-		System string `yaml:"system"`
+	// Refinement is `full` (default) or `edits`: after the first iteration the worker
+	// returns edits of the previous files instead of all files. Requires filesOutput.
+	Refinement thinkingblock.Refinement `yaml:"refinement"`
+	// AcceptScore stops the loop once the oracle scores a solution at least this high (0-10).
+	AcceptScore float64 `yaml:"acceptScore"`
+	// Provider is the default provider for all roles of the block.
+	Provider string `yaml:"provider"`
+	// Templates overrides the top-level and built-in prompts for this block.
+	Templates thinkingblock.Templates `yaml:"templates"`
+	// Inputs lists the blocks whose final answers are passed as DATA and the existing
+	// files read into it. Without blocks, the previous block is used.
+	Inputs BlockInputs `yaml:"inputs"`
+	// DataTemplate combines the inputs into DATA, it gets `.Inputs` (block name -> answer,
+	// `files` -> the files JSON).
+	DataTemplate string `yaml:"dataTemplate"`
+	// Budget limits the block, the limits of the whole run apply as well.
+	Budget budget.Limits `yaml:"budget"`
+	// Target writes the output files also to an existing project directory. Requires
+	// filesOutput.
+	Target BlockTarget `yaml:"target"`
+	// Git commits the output files to a branch of a local repository.
+	Git *gitutils.Setup `yaml:"git"`
+	// Verify runs commands against the output files of every iteration, the solution is
+	// not accepted until they pass. Requires filesOutput.
+	Verify verifier.Setup `yaml:"verify"`
+	// InputDirectory is read by the file tools. When not set, the output files of the
+	// only input block with filesOutput are used.
+	InputDirectory string `yaml:"inputDirectory"`
+	Worker         struct {
+		Role   `yaml:",inline"`
 		Prompt string `yaml:"prompt"`
 	} `yaml:"worker"`
-	Experts []struct {
-		Name   string `yaml:"name"`
-		System string `yaml:"system"`
-	} `yaml:"experts"`
-	Oracle struct {
-		Name   string `yaml:"name"`
-		System string `yaml:"system"`
-	} `yaml:"oracle"`
+	Experts []Role `yaml:"experts"`
+	Oracle  Role   `yaml:"oracle"`
+}
+
+// Role describes a single assistant of a block. Provider, Model and BaseURL override
+// the provider defaults, so every role can use a different model.
+type Role struct {
+	Name        string   `yaml:"name"`
+	System      string   `yaml:"system"`
+	Provider    string   `yaml:"provider"`
+	Model       string   `yaml:"model"`
+	BaseURL     string   `yaml:"baseURL"`
+	Temperature *float64 `yaml:"temperature"`
+	MaxTokens   int      `yaml:"maxTokens"`
+	// Tools lists the built-in tools the role can call: read_file and list_files.
+	Tools        []string `yaml:"tools"`
+	MaxToolSteps int      `yaml:"maxToolSteps"`
+}
+
+// blockRole is a role of a block with its kind: worker, expert or oracle.
+type blockRole struct {
+	kind string
+	Role
+}
+
+// roles returns the roles of the block in the order they answer in an iteration.
+func (b Block) roles() []blockRole {
+	roles := []blockRole{{kind: "worker", Role: b.Worker.Role}}
+	for _, e := range b.Experts {
+		roles = append(roles, blockRole{kind: "expert", Role: e})
+	}
+	return append(roles, blockRole{kind: "oracle", Role: b.Oracle})
+}
+
+// roleProvider returns the name of the provider of the role, the block and the built-in
+// default apply to roles without a provider.
+func (b Block) roleProvider(role Role) string {
+	return valueOrDefault(role.Provider, valueOrDefault(b.Provider, defaultProviderName))
 }
 
 func main() {
-	logger := loggerutils.SetupLogger()
-	ctx := context.TODO()
-	ctx = loggerutils.WithLogger(ctx, logger)
+	if err := runCLI(context.Background(), os.Args[1:]); err != nil {
+		log.Fatal(err.Error())
+	}
+}
+
+// runOptions control where a run writes its output.
+type runOptions struct {
+	OutputDir string
+	// Resume reuses the checkpoints found in OutputDir: completed blocks are skipped and
+	// unfinished ones continue after their last finished iteration.
+	Resume bool
+	// Progress shows streamed responses of workers and experts, nil disables streaming.
+	Progress *progressPrinter
+	// Metrics counts LLM calls, verdicts and iterations, nil disables them.
+	Metrics *metrics.Metrics
+	// Only and From select the blocks to run, see selectBlocks. The other blocks pass the
+	// outputs of a previous run in OutputDir to the selected ones.
+	Only []string
+	From string
+}
 
-	appSetupFile := flag.String("config", "", "Path to the app setup file")
-	flag.Parse()
+// filtered tells whether only some of the blocks run.
+func (opts runOptions) filtered() bool {
+	return len(opts.Only) > 0 || opts.From != ""
+}
+
+// RunApp runs the blocks as a dependency graph. A block starts as soon as all its input
+// blocks are finished, so independent blocks run concurrently.
+func RunApp(ctx context.Context, appSetup AppSetup, opts runOptions, providers *providerRegistry) (err error) {
+	ctx, span := tracer.Start(ctx, "RunApp", trace.WithAttributes(
+		attribute.Int("blocks", len(appSetup.Blocks)),
+		attribute.Bool("resume", opts.Resume),
+	))
+	defer func() { endSpan(span, err) }()
 
-	if *appSetupFile == "" {
-		log.Fatalf("usage: %s -config <app setup file>", os.Args[0])
+	deps, err := blockDependencies(appSetup.Blocks)
+	if err != nil {
+		return err
+	}
+	selected, err := selectBlocks(appSetup.Blocks, deps, opts.Only, opts.From)
+	if err != nil {
+		return err
+	}
+	needed := make([]bool, len(appSetup.Blocks))
+	for bn := range appSetup.Blocks {
+		for _, d := range deps[bn] {
+			needed[d] = needed[d] || selected[bn]
+		}
 	}
 
-	appSetup, err := getAppData(*appSetupFile)
+	ctx, cancel := context.WithCancelCause(ctx)
+	defer cancel(nil)
+
+	pricing := usage.DefaultPricing().Override(appSetup.Pricing)
+	runBudget := budget.NewTracker("run", appSetup.Budget, pricing, nil)
+	// verification commands run over the same files the blocks write
+	executorSetup := appSetup.Executor
+	executorSetup.Files = appSetup.OutputFiles
+
+	recorder, err := createTranscript(opts, pricing)
 	if err != nil {
-		log.Fatalf("failed loading app setup file: %v", err)
+		return fmt.Errorf("error creating run transcript: %w", err)
+	}
+	defer recorder.Close()
+	ctx = transcript.WithRecorder(ctx, recorder)
+
+	var blockNames []string
+	for _, b := range appSetup.Blocks {
+		blockNames = append(blockNames, b.Name)
+	}
+	transcript.Record(ctx, transcript.Event{
+		Type: transcript.EventRunStarted,
+		Data: transcript.RunStarted{Blocks: blockNames, Resume: opts.Resume},
+	})
+
+	blocksCount := len(appSetup.Blocks)
+	answers := make([]thinkingblock.ThinkingBlockOutput, blocksCount)
+	errs := make([]error, blocksCount)
+	done := make([]chan struct{}, blocksCount)
+	for bn := range done {
+		done[bn] = make(chan struct{})
+	}
+
+	var wg sync.WaitGroup
+	for bn := range appSetup.Blocks {
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+			defer close(done[bn])
+
+			if !selected[bn] {
+				answers[bn], errs[bn] = reuseBlock(ctx, appSetup, opts, bn, needed[bn])
+				return
+			}
+
+			inputNames := make([]string, 0, len(deps[bn]))
+			inputs := make(map[string]string, len(deps[bn]))
+			for _, d := range deps[bn] {
+				<-done[d]
+				if errs[d] != nil {
+					errs[bn] = errDependencyFailed
+					return
+				}
+				inputNames = append(inputNames, appSetup.Blocks[d].Name)
+				inputs[appSetup.Blocks[d].Name] = answers[d].FinalAnswer
+			}
+
+			env := blockEnv{
+				providers: providers,
+				budget:    budget.NewTracker("block "+appSetup.Blocks[bn].Name, appSetup.Blocks[bn].Budget, pricing, runBudget),
+				progress:  opts.Progress,
+				executor:  executorSetup,
+				metrics:   opts.Metrics,
+			}
+			answers[bn], errs[bn] = runAppBlock(ctx, appSetup, opts, bn, inputNames, inputs, env)
+			if errs[bn] != nil && !errors.Is(errs[bn], errRunCanceled) {
+				cancel(errRunCanceled)
+			}
+		}()
+	}
+	wg.Wait()
+
+	reportUsage(ctx, appSetup, opts, answers)
+	writeReport(ctx, appSetup, opts, answers, errs)
+
+	var runErrs []error
+	for _, err := range errs {
+		if err != nil && !errors.Is(err, errDependencyFailed) && !errors.Is(err, errNotSelected) && !errors.Is(err, errRunCanceled) {
+			runErrs = append(runErrs, err)
+		}
+	}
+	runErr := errors.Join(runErrs...)
+
+	finished := transcript.RunFinished{}
+	if runErr != nil {
+		finished.Error = runErr.Error()
+	}
+	transcript.Record(ctx, transcript.Event{Type: transcript.EventRunFinished, Data: finished})
+
+	return runErr
+}
+
+// createTranscript creates run.jsonl in the output directory. A resumed run and a run of
+// selected blocks append to it, the events of the other blocks are kept then.
+func createTranscript(opts runOptions, pricing usage.Pricing) (*transcript.Recorder, error) {
+	if err := os.MkdirAll(opts.OutputDir, 0o755); err != nil {
+		return nil, err
 	}
+	fileName := filepath.Join(opts.OutputDir, transcript.FileName)
+	return transcript.Create(fileName, opts.Resume || opts.filtered(), pricing)
+}
 
-	providers := createProviders()
+// canceledBlockError returns errRunCanceled for a block stopped because the run was
+// canceled after another block failed, it has not failed itself.
+func canceledBlockError(ctx context.Context, err error) error {
+	if errors.Is(err, context.Canceled) && errors.Is(context.Cause(ctx), errRunCanceled) {
+		return errRunCanceled
+	}
+	return err
+}
 
-	err = RunApp(ctx, appSetup, providers)
+// recordBlockFinished records the end of a block, err is set when it failed.
+func recordBlockFinished(ctx context.Context, out thinkingblock.ThinkingBlockOutput, err error) {
+	finished := transcript.BlockFinished{
+		StopReason:     string(out.StopReason),
+		FinalIteration: out.FinalIteration,
+		Iterations:     len(out.PartAnswers),
+		Usage:          transcript.FromContext(ctx).Usage(out.Usage),
+	}
 	if err != nil {
-		log.Fatal(err.Error())
+		finished.Error = err.Error()
 	}
+	transcript.Record(ctx, transcript.Event{Type: transcript.EventBlockFinished, Data: finished})
 }
 
-func RunApp(ctx context.Context, appSetup AppSetup, providers map[string]llm.LLMProvider) error {
-	previousBlockOutput := ""
-	for bn, b := range appSetup.Blocks {
-		logger := loggerutils.GetLogger(ctx)
+// blockEnv holds what a block gets from the run besides its configuration.
+type blockEnv struct {
+	providers *providerRegistry
+	budget    *budget.Tracker
+	// progress shows streamed responses, nil disables streaming.
+	progress *progressPrinter
+	executor executor.Setup
+	// metrics is nil when they are disabled.
+	metrics *metrics.Metrics
+}
+
+func runAppBlock(
+	ctx context.Context,
+	appSetup AppSetup,
+	opts runOptions,
+	bn int,
+	inputNames []string,
+	inputs map[string]string,
+	env blockEnv,
+) (out thinkingblock.ThinkingBlockOutput, err error) {
+	b := appSetup.Blocks[bn]
+	logger := loggerutils.GetLogger(ctx).With("block", b.Name)
+	ctx = loggerutils.WithLogger(ctx, logger)
+	ctx = transcript.WithBlock(ctx, b.Name)
 
-		logger.Info("Running block", "name", b.Name)
+	blockDirName := blockDirName(bn, b.Name)
+	checkpoints := newCheckpointStore(opts.OutputDir, blockDirName)
 
-		ans, err := RunBlock(ctx, b, previousBlockOutput, providers)
+	var previous thinkingblock.ThinkingBlockOutput
+	if opts.Resume {
+		ans, completed, err := checkpoints.loadBlock()
 		if err != nil {
-			return fmt.Errorf("error running block %s: %s", b.Name, err.Error())
+			return ans, fmt.Errorf("error loading block %s checkpoint: %w", b.Name, err)
+		}
+		if completed {
+			logger.Info("Skipping completed block", "name", b.Name)
+			return ans, nil
 		}
 
-		previousBlockOutput = ans.FinalAnswer
+		previous, err = checkpoints.loadIterations()
+		if err != nil {
+			return previous, fmt.Errorf("error loading block %s iterations: %w", b.Name, err)
+		}
+	} else if err := checkpoints.reset(); err != nil {
+		return previous, fmt.Errorf("error removing block %s checkpoints: %w", b.Name, err)
+	}
 
-		partialOutputsDir := filepath.Join(
-			os.Getenv("OUTPUT_DIRECTORY"),
-			"conversations",
-			fileutils.ToKebabCase(fmt.Sprintf("%03d %s", bn, b.Name)),
-		)
+	logger.Info("Running block", "name", b.Name, "inputs", inputNames)
+	transcript.Record(ctx, transcript.Event{
+		Type: transcript.EventBlockStarted,
+		Data: transcript.BlockStarted{Inputs: inputNames},
+	})
+	defer func() {
+		err = canceledBlockError(ctx, err)
+		recordBlockFinished(ctx, out, err)
+	}()
+
+	if b.Target.Dir != "" && !b.FilesOutput {
+		return previous, fmt.Errorf("block %s: target needs filesOutput", b.Name)
+	}
 
-		err = SaveBlockAnswer(ctx, partialOutputsDir, b, ans)
+	committer, err := openBlockGit(ctx, b, appSetup.OutputFiles, opts.Resume)
+	if err != nil {
+		return previous, fmt.Errorf("block %s: %w", b.Name, err)
+	}
+	onIteration := checkpoints.saveIteration
+	if committer != nil && b.Git.Iterations {
+		onIteration = commitIterations(committer, b.Name, onIteration)
+	}
+	if env.metrics != nil {
+		onIteration = observeIterations(env.metrics, b.Name, b.AcceptScore, onIteration)
+	}
+
+	b.Templates = appSetup.Templates.Override(b.Templates)
+	if b.InputDirectory == "" {
+		b.InputDirectory = inputFilesDirectory(appSetup, opts, inputNames)
+	}
+
+	if b.Inputs.Files.Root != "" {
+		files, err := collectInputFiles(ctx, b.Inputs.Files)
 		if err != nil {
-			return fmt.Errorf("error saving block %s: %s", b.Name, err.Error())
+			return thinkingblock.ThinkingBlockOutput{}, fmt.Errorf("error reading block %s input files: %w", b.Name, err)
+		}
+		if _, ok := inputs[filesInputName]; ok {
+			return thinkingblock.ThinkingBlockOutput{}, fmt.Errorf("block %s: input block %s conflicts with input files", b.Name, filesInputName)
+		}
+		inputNames = append(inputNames, filesInputName)
+		inputs[filesInputName] = files
+		if b.InputDirectory == "" {
+			b.InputDirectory = b.Inputs.Files.Root
 		}
+	}
 
-		blockFinalAnswerDir := filepath.Join(
-			os.Getenv("OUTPUT_DIRECTORY"),
-			"answers",
-			fileutils.ToKebabCase(fmt.Sprintf("%03d %s", bn, b.Name)),
-		)
+	data, err := blockInputData(b, inputNames, inputs)
+	if err != nil {
+		return thinkingblock.ThinkingBlockOutput{}, fmt.Errorf("error preparing block %s data: %w", b.Name, err)
+	}
 
-		if b.FilesOutput {
-			err = os.MkdirAll(blockFinalAnswerDir, 0o755)
-			if err != nil {
-				return fmt.Errorf("error creating block answer directory %s: %s", b.Name, err.Error())
+	ans, err := RunBlock(ctx, b, data, previous, onIteration, env)
+	if err != nil {
+		return thinkingblock.ThinkingBlockOutput{}, fmt.Errorf("error running block %s: %w", b.Name, err)
+	}
+	if ans.StopReason == thinkingblock.StopBudgetExceeded {
+		logger.Warn("Block stopped by budget", "name", b.Name, "finalIteration", ans.FinalIteration)
+	}
+	if env.metrics != nil {
+		env.metrics.ObserveBlock(b.Name, string(ans.StopReason), len(ans.PartAnswers))
+	}
 
-			}
-			err = fileutils.SaveFilesFromJson(blockFinalAnswerDir, []byte(ans.FinalAnswer))
-			if err != nil {
-				return fmt.Errorf("error saving output files: %s", err.Error())
-			}
+	partialOutputsDir := filepath.Join(opts.OutputDir, "conversations", blockDirName)
+
+	err = SaveBlockAnswer(ctx, partialOutputsDir, b, ans)
+	if err != nil {
+		return ans, fmt.Errorf("error saving block %s: %s", b.Name, err.Error())
+	}
+
+	blockFinalAnswerDir := filepath.Join(opts.OutputDir, "answers", blockDirName)
+
+	if b.FilesOutput {
+		err = os.MkdirAll(blockFinalAnswerDir, 0o755)
+		if err != nil {
+			return ans, fmt.Errorf("error creating block answer directory %s: %s", b.Name, err.Error())
+		}
+		skipped, err := fileutils.SaveFilesFromJson(blockFinalAnswerDir, []byte(ans.FinalAnswer), appSetup.OutputFiles)
+		if err != nil {
+			return ans, fmt.Errorf("error saving output files: %s", err.Error())
+		}
+		for _, s := range skipped {
+			logger.Warn("Rejected output file", "file", s.FileName, "reason", s.Reason)
 		}
 	}
-	return nil
+
+	if b.Target.Dir != "" {
+		backupDir := filepath.Join(opts.OutputDir, "backups", blockDirName)
+		err = writeBlockTarget(ctx, b.Target, ans.FinalAnswer, appSetup.OutputFiles, partialOutputsDir, backupDir)
+		if err != nil {
+			return ans, fmt.Errorf("error writing block %s output files to %s: %w", b.Name, b.Target.Dir, err)
+		}
+	}
+
+	if committer != nil {
+		err = commitFiles(ctx, committer, ans.FinalAnswer, finalCommitMessage(b.Name, ans))
+		if err != nil {
+			return ans, fmt.Errorf("error committing block %s output files: %w", b.Name, err)
+		}
+	}
+
+	// the block checkpoint is written last, so a resumed run repeats saving the outputs
+	// when it was interrupted in the middle of it
+	err = checkpoints.saveBlock(ans)
+	if err != nil {
+		return ans, fmt.Errorf("error saving block %s checkpoint: %w", b.Name, err)
+	}
+
+	return ans, nil
+}
+
+// reuseBlock returns the output of a block that is not selected to run from its checkpoint
+// of a previous run. A missing checkpoint is an error only when the output is needed by
+// a selected block.
+func reuseBlock(
+	ctx context.Context,
+	appSetup AppSetup,
+	opts runOptions,
+	bn int,
+	needed bool,
+) (thinkingblock.ThinkingBlockOutput, error) {
+	b := appSetup.Blocks[bn]
+	logger := loggerutils.GetLogger(ctx)
+
+	ans, completed, err := newCheckpointStore(opts.OutputDir, blockDirName(bn, b.Name)).loadBlock()
+	if err != nil {
+		return ans, fmt.Errorf("error loading block %s checkpoint: %w", b.Name, err)
+	}
+	if !completed {
+		if needed {
+			return ans, fmt.Errorf("block %s is not selected and has no finished output in %s", b.Name, opts.OutputDir)
+		}
+		return ans, errNotSelected
+	}
+
+	logger.Info("Reusing block output", "name", b.Name)
+	return ans, nil
 }
 
 func RunBlock(
 	ctx context.Context,
 	blockData Block,
 	additionalData string,
-	providers map[string]llm.LLMProvider,
-) (thinkingblock.ThinkingBlockOutput, error) {
-	provider := providers["openai"]
+	previous thinkingblock.ThinkingBlockOutput,
+	onIteration func(context.Context, int, thinkingblock.Prompts, thinkingblock.PartialAnswer) error,
+	env blockEnv,
+) (out thinkingblock.ThinkingBlockOutput, err error) {
+	ctx, span := tracer.Start(ctx, "RunBlock", trace.WithAttributes(
+		attribute.String("block", blockData.Name),
+		attribute.Int("previousIterations", len(previous.PartAnswers)),
+	))
+	defer func() { endSpan(span, err) }()
+
+	worker, experts, oracle, err := createAssistants(blockData, env)
+	if err != nil {
+		return thinkingblock.ThinkingBlockOutput{}, err
+	}
 
-	worker, experts, oracle := createAssistants(blockData, provider)
+	var expertsTeam assistants.ExpertsTeamInterface = &assistants.ExpertsTeam{Experts: experts}
+	if env.metrics != nil {
+		expertsTeam = env.metrics.WrapExperts(expertsTeam, blockData.Name)
+	}
 
 	thinkingBlock := thinkingblock.ThinkingBlock{
 		Worker:      worker,
-		ExpertsTeam: &assistants.ExpertsTeam{Experts: experts},
+		ExpertsTeam: expertsTeam,
 		Oracle:      oracle,
+		AcceptScore: blockData.AcceptScore,
+		Refinement:  blockData.Refinement,
+		Templates:   blockData.Templates,
+		Previous:    previous,
+		OnIteration: onIteration,
+	}
+	if len(blockData.Verify.Commands) > 0 {
+		if !blockData.FilesOutput {
+			return thinkingblock.ThinkingBlockOutput{}, fmt.Errorf("verify commands need filesOutput")
+		}
+		v, err := verifier.New(blockData.Verify, env.executor)
+		if err != nil {
+			return thinkingblock.ThinkingBlockOutput{}, fmt.Errorf("cannot create verifier: %w", err)
+		}
+		thinkingBlock.Verifier = v
 	}
 
-	out, err := thinkingBlock.Run(
+	out, err = thinkingBlock.Run(
 		ctx,
 		string(blockData.Worker.Prompt),
 		additionalData,
@@ -144,35 +547,131 @@ func RunBlock(
 	return out, nil
 }
 
-func createProviders() map[string]llm.LLMProvider {
-	openAIAPIKey := os.Getenv("OPENAI_API_KEY")
-	return map[string]llm.LLMProvider{
-		"openai": llm.NewOpenAIWithStructuredOutputProvider(openAIAPIKey, "gpt-4o-mini", ""),
+func createAssistants(
+	blockData Block,
+	env blockEnv,
+) (worker assistants.Assistant, experts []assistants.Assistant, oracle assistants.Assistant, err error) {
+	worker, err = createAssistant(blockData, "worker", blockData.Worker.Role, env)
+	if err != nil {
+		return
 	}
+	worker.OnStream = env.progress.stream(fmt.Sprintf("[%s] worker %s", blockData.Name, worker.Name))
+
+	for _, a := range blockData.Experts {
+		expert, expertErr := createAssistant(blockData, "expert", a, env)
+		if expertErr != nil {
+			return worker, nil, oracle, expertErr
+		}
+		expert.OnStream = env.progress.stream(fmt.Sprintf("[%s] expert %s", blockData.Name, expert.Name))
+		experts = append(experts, expert)
+	}
+
+	oracle, err = createAssistant(blockData, "oracle", blockData.Oracle, env)
+	return
 }
 
-func createAssistants(blockData Block, provider llm.LLMProvider) (worker assistants.Assistant, experts []assistants.Assistant, oracle assistants.Assistant) {
-	worker = assistants.Assistant{
-		Name:         blockData.Worker.Name,
-		SystemPrompt: blockData.Worker.System,
+// createAssistant creates the assistant of the role, kind is worker, expert or oracle.
+func createAssistant(
+	blockData Block,
+	kind string,
+	role Role,
+	env blockEnv,
+) (assistants.Assistant, error) {
+	providerName := blockData.roleProvider(role)
+	provider, err := env.providers.get(providerName, role.BaseURL)
+	if err != nil {
+		return assistants.Assistant{}, fmt.Errorf("cannot create assistant %s: %w", role.Name, err)
+	}
+	if env.metrics != nil {
+		// calls rejected by the budget are not sent, so they are not counted
+		model := valueOrDefault(role.Model, env.providers.setups[providerName].defaultModel())
+		provider = env.metrics.Wrap(provider, providerName, model)
+	}
+	if env.budget != nil {
+		provider = env.budget.Wrap(provider)
+	}
+	// calls rejected by the budget are recorded as well
+	provider = transcript.Wrap(provider, kind, role.Name)
+
+	if setup := env.providers.setups[providerName]; len(role.Tools) > 0 && !setup.supportsTools() {
+		return assistants.Assistant{}, fmt.Errorf("cannot create assistant %s: %w", role.Name, setup.toolsError(providerName))
+	}
+	tools, err := createTools(blockData, role)
+	if err != nil {
+		return assistants.Assistant{}, fmt.Errorf("cannot create assistant %s: %w", role.Name, err)
+	}
+
+	return assistants.Assistant{
+		Name:         role.Name,
+		SystemPrompt: role.System,
 		Llm:          provider,
+		Model:        role.Model,
+		Temperature:  role.Temperature,
+		MaxTokens:    role.MaxTokens,
+		Tools:        tools,
+		MaxToolSteps: role.MaxToolSteps,
+	}, nil
+}
+
+// createTools returns the built-in tools listed by the role, nil when there are none.
+func createTools(blockData Block, role Role) (*assistants.ToolRegistry, error) {
+	if len(role.Tools) == 0 {
+		return nil, nil
+	}
+	if blockData.InputDirectory == "" {
+		return nil, fmt.Errorf("tools need the inputDirectory of block %s", blockData.Name)
 	}
 
-	for _, a := range blockData.Experts {
-		experts = append(experts, assistants.Assistant{
-			Name:         a.Name,
-			SystemPrompt: a.System,
-			Llm:          provider,
-		},
-		)
+	builtin := map[string]assistants.Tool{}
+	for _, t := range assistants.FileTools(blockData.InputDirectory) {
+		builtin[t.Name] = t
 	}
 
-	oracle = assistants.Assistant{
-		Name: blockData.Oracle.Name,
-		Llm:  provider,
+	var tools []assistants.Tool
+	for _, name := range role.Tools {
+		t, ok := builtin[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown tool %s", name)
+		}
+		tools = append(tools, t)
 	}
+	return assistants.NewToolRegistry(tools...)
+}
 
-	return
+func blockDirName(bn int, name string) string {
+	return fileutils.ToKebabCase(fmt.Sprintf("%03d %s", bn, name))
+}
+
+// collectInputFiles reads the files selected by the setup as a fileutils.FileList JSON.
+func collectInputFiles(ctx context.Context, setup fileutils.CollectSetup) (string, error) {
+	logger := loggerutils.GetLogger(ctx)
+
+	fileList, skipped, err := fileutils.CollectFiles(setup)
+	if err != nil {
+		return "", err
+	}
+	for _, s := range skipped {
+		logger.Warn("Skipping input file", "file", s.FileName, "reason", s.Reason)
+	}
+	logger.Info("Read input files", "root", setup.Root, "files", len(fileList.Files), "skipped", len(skipped))
+
+	return fileutils.MarshalFileList(fileList)
+}
+
+// inputFilesDirectory returns the output files directory of the only input block with
+// filesOutput, or an empty string when there is not exactly one such block.
+func inputFilesDirectory(appSetup AppSetup, opts runOptions, inputNames []string) string {
+	dir := ""
+	for bn, b := range appSetup.Blocks {
+		if !b.FilesOutput || !slices.Contains(inputNames, b.Name) {
+			continue
+		}
+		if dir != "" {
+			return ""
+		}
+		dir = filepath.Join(opts.OutputDir, "answers", blockDirName(bn, b.Name))
+	}
+	return dir
 }
 
 func SaveBlockAnswer(
@@ -233,11 +732,23 @@ func SaveBlockAnswer(
 			logger.Error("error writing to file", "error", err)
 		}
 
+		if pa.WorkerEdits != "" {
+			editsFileName := fileutils.CreateTxtFilename(outputDir, paIdx, "1-"+blockData.Worker.Name, "edits")
+			edits := pa.WorkerEdits
+			for _, f := range pa.FailedEdits {
+				edits += "\nFAILED: " + f.String()
+			}
+			err = fileutils.WriteToFile(editsFileName, edits)
+			if err != nil {
+				logger.Error("error writing to file", "error", err)
+			}
+		}
+
 		for ean, ea := range pa.ExpertAnswers {
 			ansFileName = fileutils.CreateTxtFilename(
 				outputDir,
 				paIdx,
-				"2-"+blockData.Experts[ean].Name,
+				"2-"+pa.ExpertNames[ean],
 				"response",
 			)
 			err = fileutils.WriteToFile(ansFileName, ea)
@@ -251,6 +762,34 @@ func SaveBlockAnswer(
 		if err != nil {
 			logger.Error("error writing to file", "error", err)
 		}
+
+		if pa.Verification != nil {
+			verificationFileName := fileutils.CreateTxtFilename(outputDir, paIdx, "1-"+blockData.Worker.Name, "verification")
+			err = fileutils.WriteToFile(verificationFileName, pa.Verification.String())
+			if err != nil {
+				logger.Error("error writing to file", "error", err)
+			}
+
+			// the same results for tools, e.g. CI checks of the exit codes
+			verification, err := json.MarshalIndent(pa.Verification, "", "  ")
+			if err == nil {
+				err = os.WriteFile(strings.TrimSuffix(verificationFileName, ".txt")+".json", verification, 0o644)
+			}
+			if err != nil {
+				logger.Error("error writing verification results", "error", err)
+			}
+		}
+
+		verdict, err := json.MarshalIndent(pa.OracleVerdict, "", "  ")
+		if err != nil {
+			logger.Error("error marshaling oracle verdict", "error", err)
+			continue
+		}
+		verdictFileName := fileutils.CreateTxtFilename(outputDir, paIdx, "3-"+blockData.Oracle.Name, "verdict")
+		err = fileutils.WriteToFile(verdictFileName, string(verdict))
+		if err != nil {
+			logger.Error("error writing to file", "error", err)
+		}
 	}
 
 	return nil
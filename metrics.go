@@ -0,0 +1,23 @@
+package main
+
+import (
+	"context"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/metrics"
+	thinkingblock "github.com/aszmajdzinski/llm-feedback-loop-executor/thinking_block"
+)
+
+// observeIterations returns onIteration counting the verdict of every finished iteration
+// before it. A verdict counts as accepted when it finishes the loop, like a score reaching
+// acceptScore.
+func observeIterations(
+	m *metrics.Metrics,
+	blockName string,
+	acceptScore float64,
+	onIteration func(context.Context, int, thinkingblock.Prompts, thinkingblock.PartialAnswer) error,
+) func(context.Context, int, thinkingblock.Prompts, thinkingblock.PartialAnswer) error {
+	return func(ctx context.Context, iteration int, prompts thinkingblock.Prompts, answer thinkingblock.PartialAnswer) error {
+		m.ObserveVerdict(blockName, answer.Accepted(acceptScore))
+		return onIteration(ctx, iteration, prompts, answer)
+	}
+}
@@ -0,0 +1,164 @@
+// Package metrics exposes Prometheus metrics of a run, so long runs can be monitored and
+// alerted on. All methods of a nil *Metrics do nothing.
+package metrics
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/collectors"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+const namespace = "llm_loop"
+
+// Status label values, failed API requests use the HTTP status code instead of statusError.
+const (
+	statusOK    = "ok"
+	statusError = "error"
+)
+
+type Metrics struct {
+	registry *prometheus.Registry
+
+	llmCalls     *prometheus.CounterVec
+	llmTokens    *prometheus.CounterVec
+	llmDuration  *prometheus.HistogramVec
+	expertAnswer *prometheus.CounterVec
+	verdicts     *prometheus.CounterVec
+	iterations   *prometheus.HistogramVec
+}
+
+// New creates the metrics in a registry of their own, with the Go runtime and process
+// metrics next to them.
+func New() *Metrics {
+	m := &Metrics{
+		registry: prometheus.NewRegistry(),
+		llmCalls: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "llm_calls_total",
+			Help:      "LLM calls by provider, model and status: ok, error or the HTTP status code of a failed request.",
+		}, []string{"provider", "model", "status"}),
+		llmTokens: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "llm_tokens_total",
+			Help:      "Tokens of successful LLM calls by provider, model and type: input or output.",
+		}, []string{"provider", "model", "type"}),
+		llmDuration: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "llm_call_duration_seconds",
+			Help:      "Latency of LLM calls including retries.",
+			Buckets:   []float64{0.5, 1, 2.5, 5, 10, 20, 40, 80, 160, 320},
+		}, []string{"provider", "model"}),
+		expertAnswer: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "expert_answers_total",
+			Help:      "Answers of experts by block, expert and status: ok or error.",
+		}, []string{"block", "expert", "status"}),
+		verdicts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Name:      "oracle_verdicts_total",
+			Help:      "Oracle verdicts by block and whether the solution was accepted.",
+		}, []string{"block", "accepted"}),
+		iterations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Name:      "block_iterations",
+			Help:      "Iterations a finished block took by its stop reason.",
+			Buckets:   prometheus.LinearBuckets(1, 1, 10),
+		}, []string{"block", "stop_reason"}),
+	}
+
+	m.registry.MustRegister(
+		collectors.NewGoCollector(),
+		collectors.NewProcessCollector(collectors.ProcessCollectorOpts{}),
+		m.llmCalls,
+		m.llmTokens,
+		m.llmDuration,
+		m.expertAnswer,
+		m.verdicts,
+		m.iterations,
+	)
+	return m
+}
+
+// Handler serves the metrics in the Prometheus text format.
+func (m *Metrics) Handler() http.Handler {
+	return promhttp.HandlerFor(m.registry, promhttp.HandlerOpts{Registry: m.registry})
+}
+
+// Listen serves the metrics on /metrics of addr until shutdown is called. Errors of the
+// address are returned right away, so a wrong flag fails the run before it starts.
+func (m *Metrics) Listen(addr string) (shutdown func(context.Context) error, err error) {
+	listener, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", m.Handler())
+	server := &http.Server{Handler: mux, ReadHeaderTimeout: 10 * time.Second}
+	// Serve returns ErrServerClosed after Shutdown, other errors show as a failed scrape
+	go func() { _ = server.Serve(listener) }()
+
+	return server.Shutdown, nil
+}
+
+// ObserveLLMCall counts a call of a provider, the tokens are counted for successful calls.
+func (m *Metrics) ObserveLLMCall(provider string, model string, tokens llm.TokenUsage, d time.Duration, err error) {
+	if m == nil {
+		return
+	}
+
+	m.llmCalls.WithLabelValues(provider, model, callStatus(err)).Inc()
+	m.llmDuration.WithLabelValues(provider, model).Observe(d.Seconds())
+	if err == nil {
+		m.llmTokens.WithLabelValues(provider, model, "input").Add(float64(tokens.InputTokens))
+		m.llmTokens.WithLabelValues(provider, model, "output").Add(float64(tokens.OutputTokens))
+	}
+}
+
+// ObserveExpertAnswer counts an answer of an expert, err is set when the expert failed.
+func (m *Metrics) ObserveExpertAnswer(block string, expert string, err error) {
+	if m == nil {
+		return
+	}
+
+	status := statusOK
+	if err != nil {
+		status = statusError
+	}
+	m.expertAnswer.WithLabelValues(block, expert, status).Inc()
+}
+
+// ObserveVerdict counts a verdict of the oracle.
+func (m *Metrics) ObserveVerdict(block string, accepted bool) {
+	if m == nil {
+		return
+	}
+	m.verdicts.WithLabelValues(block, strconv.FormatBool(accepted)).Inc()
+}
+
+// ObserveBlock records the iterations of a finished block.
+func (m *Metrics) ObserveBlock(block string, stopReason string, iterations int) {
+	if m == nil {
+		return
+	}
+	m.iterations.WithLabelValues(block, stopReason).Observe(float64(iterations))
+}
+
+func callStatus(err error) string {
+	if err == nil {
+		return statusOK
+	}
+	var apiErr *llm.APIError
+	if errors.As(err, &apiErr) {
+		return strconv.Itoa(apiErr.StatusCode)
+	}
+	return statusError
+}
@@ -0,0 +1,120 @@
+package metrics
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/assistants"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestWrap(t *testing.T) {
+	m := New()
+	calls := 0
+	provider := m.Wrap(&llm.MockStructuredLLMProvider{
+		GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+			calls++
+			if calls > 1 {
+				return llm.ChatResponse{}, &llm.APIError{StatusCode: 429, Body: "slow down"}
+			}
+			return llm.ChatResponse{Model: "gpt-4o-2024", TokenUsage: llm.TokenUsage{InputTokens: 10, OutputTokens: 4}}, nil
+		},
+	}, "openai", "gpt-4o")
+
+	structured, ok := provider.(llm.StreamingStructuredLLMProvider)
+	if !ok {
+		t.Fatalf("expected structured streaming support to be kept")
+	}
+	if _, err := structured.GetResponse(context.Background(), llm.StructuredChatRequest{}); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := structured.StreamResponse(context.Background(), llm.StructuredChatRequest{}, func(string) {}); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	if v := testutil.ToFloat64(m.llmCalls.WithLabelValues("openai", "gpt-4o-2024", "ok")); v != 1 {
+		t.Errorf("expected 1 successful call, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.llmCalls.WithLabelValues("openai", "gpt-4o", "429")); v != 1 {
+		t.Errorf("expected 1 rate limited call, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.llmTokens.WithLabelValues("openai", "gpt-4o-2024", "input")); v != 10 {
+		t.Errorf("expected 10 input tokens, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.llmTokens.WithLabelValues("openai", "gpt-4o-2024", "output")); v != 4 {
+		t.Errorf("expected 4 output tokens, got %v", v)
+	}
+	if n := testutil.CollectAndCount(m.llmDuration); n != 2 {
+		t.Errorf("expected latencies of 2 models, got %d", n)
+	}
+}
+
+func TestWrapExperts(t *testing.T) {
+	m := New()
+	team := m.WrapExperts(assistants.MockExpertsTeam{
+		AskFunc: func(ctx context.Context, prompt string) []assistants.ExpertAnswer {
+			return []assistants.ExpertAnswer{
+				{Expert: "reviewer", Answer: "fine"},
+				{Expert: "tester", Error: errors.New("timeout")},
+			}
+		},
+	}, "code")
+
+	if answers := team.Ask(context.Background(), "review"); len(answers) != 2 {
+		t.Fatalf("expected the answers of the team, got %v", answers)
+	}
+	if v := testutil.ToFloat64(m.expertAnswer.WithLabelValues("code", "reviewer", "ok")); v != 1 {
+		t.Errorf("expected 1 answer, got %v", v)
+	}
+	if v := testutil.ToFloat64(m.expertAnswer.WithLabelValues("code", "tester", "error")); v != 1 {
+		t.Errorf("expected 1 error, got %v", v)
+	}
+}
+
+func TestHandler(t *testing.T) {
+	m := New()
+	m.ObserveVerdict("code", false)
+	m.ObserveVerdict("code", true)
+	m.ObserveBlock("code", "accepted", 2)
+	m.ObserveLLMCall("ollama", "llama3.2", llm.TokenUsage{}, time.Second, errors.New("connection refused"))
+
+	server := httptest.NewServer(m.Handler())
+	defer server.Close()
+	resp, err := server.Client().Get(server.URL)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	for _, s := range []string{
+		`llm_loop_oracle_verdicts_total{accepted="false",block="code"} 1`,
+		`llm_loop_oracle_verdicts_total{accepted="true",block="code"} 1`,
+		`llm_loop_block_iterations_bucket{block="code",stop_reason="accepted",le="2"} 1`,
+		`llm_loop_block_iterations_count{block="code",stop_reason="accepted"} 1`,
+		`llm_loop_llm_calls_total{model="llama3.2",provider="ollama",status="error"} 1`,
+		`llm_loop_llm_call_duration_seconds_sum{model="llama3.2",provider="ollama"} 1`,
+		"go_goroutines",
+	} {
+		if !strings.Contains(string(body), s) {
+			t.Errorf("expected the metrics to contain %s", s)
+		}
+	}
+}
+
+func TestNilMetrics(t *testing.T) {
+	var m *Metrics
+	m.ObserveLLMCall("openai", "gpt-4o", llm.TokenUsage{}, time.Second, nil)
+	m.ObserveExpertAnswer("code", "reviewer", nil)
+	m.ObserveVerdict("code", true)
+	m.ObserveBlock("code", "accepted", 1)
+}
@@ -0,0 +1,44 @@
+package metrics
+
+import (
+	"context"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/assistants"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+)
+
+// Wrap returns a provider counting its calls in the metrics, see llm.Intercept. model
+// labels failed calls, successful ones use the model of the response.
+func (m *Metrics) Wrap(provider llm.LLMProvider, providerName string, model string) llm.LLMProvider {
+	return llm.Intercept(provider, func(_ context.Context, _ llm.Call, send func() (llm.ChatResponse, error)) (llm.ChatResponse, error) {
+		start := time.Now()
+		resp, err := send()
+
+		callModel := model
+		if resp.Model != "" {
+			callModel = resp.Model
+		}
+		m.ObserveLLMCall(providerName, callModel, resp.TokenUsage, time.Since(start), err)
+		return resp, err
+	})
+}
+
+// WrapExperts returns a team counting the answers and errors of its experts.
+func (m *Metrics) WrapExperts(team assistants.ExpertsTeamInterface, block string) assistants.ExpertsTeamInterface {
+	return observedExpertsTeam{team: team, metrics: m, block: block}
+}
+
+type observedExpertsTeam struct {
+	team    assistants.ExpertsTeamInterface
+	metrics *Metrics
+	block   string
+}
+
+func (t observedExpertsTeam) Ask(ctx context.Context, prompt string) []assistants.ExpertAnswer {
+	answers := t.team.Ask(ctx, prompt)
+	for _, a := range answers {
+		t.metrics.ObserveExpertAnswer(t.block, a.Expert, a.Error)
+	}
+	return answers
+}
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+)
+
+// Sizes the token estimate of a plan is based on. The real sizes depend on the answers,
+// so the estimate only tells the order of magnitude of a run.
+const (
+	charsPerToken = 4
+	// estimatedSolutionTokens is the answer of a worker without maxTokens.
+	estimatedSolutionTokens = 2000
+	// estimatedReviewTokens is the answer of an expert or the oracle without maxTokens.
+	estimatedReviewTokens = 500
+)
+
+// planBlock is a block as it is going to run. Blocks of the same stage can run
+// concurrently, a block starts after the blocks of the earlier stages it depends on.
+type planBlock struct {
+	Name        string
+	Stage       int
+	Inputs      []string
+	Iterations  int
+	FilesOutput bool
+	Roles       []planRole
+}
+
+type planRole struct {
+	Kind     string
+	Name     string
+	Provider string
+	Model    string
+	// Estimate holds the calls and tokens of all iterations with their cost.
+	Estimate usage.Record
+}
+
+// planCommand prints the blocks of an app setup with their roles, models and the
+// estimated token cost, without calling any API.
+func planCommand(_ context.Context, args []string) error {
+	fs := newFlagSet("plan")
+	appSetupFile := fs.String("config", "", "Path to the app setup file")
+	_ = fs.Parse(args)
+
+	appSetup, err := loadAppSetupFlag(fs, *appSetupFile)
+	if err != nil {
+		return err
+	}
+
+	blocks, err := newPlan(appSetup)
+	if err != nil {
+		return err
+	}
+	return writePlan(os.Stdout, blocks)
+}
+
+// newPlan estimates every iteration of every block to run, so it is an upper bound for
+// blocks accepted early.
+func newPlan(appSetup AppSetup) ([]planBlock, error) {
+	deps, err := blockDependencies(appSetup.Blocks)
+	if err != nil {
+		return nil, err
+	}
+
+	pricing := usage.DefaultPricing().Override(appSetup.Pricing)
+	providers := providerSetups(appSetup.Providers)
+
+	// blocks are planned in the order of their stages, so the inputs are always planned
+	stages := blockStages(deps)
+	order := make([]int, 0, len(appSetup.Blocks))
+	for stage := 1; len(order) < len(appSetup.Blocks); stage++ {
+		for bn := range appSetup.Blocks {
+			if stages[bn] == stage {
+				order = append(order, bn)
+			}
+		}
+	}
+
+	blocks := make([]planBlock, len(appSetup.Blocks))
+	solutionTokens := make([]int, len(appSetup.Blocks))
+	for _, bn := range order {
+		b := appSetup.Blocks[bn]
+		dataTokens := inputFilesTokens(b.Inputs.Files)
+		var inputs []string
+		for _, d := range deps[bn] {
+			inputs = append(inputs, appSetup.Blocks[d].Name)
+			dataTokens += solutionTokens[d]
+		}
+
+		blocks[bn] = planBlock{
+			Name:        b.Name,
+			Stage:       stages[bn],
+			Inputs:      inputs,
+			Iterations:  b.Iterations,
+			FilesOutput: b.FilesOutput,
+			Roles:       planRoles(b, dataTokens, providers, pricing),
+		}
+		solutionTokens[bn] = outputTokens(b.Worker.Role, estimatedSolutionTokens)
+	}
+
+	return blocks, nil
+}
+
+// blockStages returns the stage of every block, 1 for blocks without inputs and one more
+// than the latest of its inputs otherwise.
+func blockStages(deps [][]int) []int {
+	stages := make([]int, len(deps))
+	var stage func(bn int) int
+	stage = func(bn int) int {
+		if stages[bn] == 0 {
+			stages[bn] = 1
+			for _, d := range deps[bn] {
+				stages[bn] = max(stages[bn], stage(d)+1)
+			}
+		}
+		return stages[bn]
+	}
+	for bn := range deps {
+		stage(bn)
+	}
+	return stages
+}
+
+// planRoles estimates the calls of the roles of a block. Every iteration the worker gets
+// the task and the data, and from the second iteration on its previous solution with the
+// reviews; the experts review the solution and the oracle judges it with the reviews.
+// Tool calls are not included.
+func planRoles(b Block, dataTokens int, providers map[string]ProviderSetup, pricing usage.Pricing) []planRole {
+	task := textTokens(b.Worker.Prompt)
+	solution := outputTokens(b.Worker.Role, estimatedSolutionTokens)
+	reviews := 0
+	for _, e := range b.Experts {
+		reviews += outputTokens(e, estimatedReviewTokens)
+	}
+	verdict := outputTokens(b.Oracle, estimatedReviewTokens)
+
+	var roles []planRole
+	for _, r := range b.roles() {
+		input := textTokens(r.System) + task + dataTokens
+		output := outputTokens(r.Role, estimatedReviewTokens)
+		switch r.kind {
+		case "worker":
+			output = solution
+		case "expert":
+			input += solution
+		case "oracle":
+			input += solution + reviews
+		}
+
+		tokens := llm.TokenUsage{InputTokens: input * b.Iterations, OutputTokens: output * b.Iterations}
+		if r.kind == "worker" && b.Iterations > 1 {
+			tokens.InputTokens += (solution + reviews + verdict) * (b.Iterations - 1)
+		}
+		tokens.TotalTokens = tokens.InputTokens + tokens.OutputTokens
+
+		providerName := b.roleProvider(r.Role)
+		model := valueOrDefault(r.Model, providers[providerName].defaultModel())
+		roles = append(roles, planRole{
+			Kind:     r.kind,
+			Name:     r.Name,
+			Provider: providerName,
+			Model:    model,
+			Estimate: usage.NewRecord(b.Name, -1, r.kind, r.Name, llm.Usage{
+				Model:      model,
+				Calls:      b.Iterations,
+				TokenUsage: tokens,
+			}, pricing),
+		})
+	}
+	return roles
+}
+
+func textTokens(s string) int {
+	return (len(s) + charsPerToken - 1) / charsPerToken
+}
+
+// outputTokens returns maxTokens of the role, it is the most a call can answer with.
+func outputTokens(role Role, estimate int) int {
+	if role.MaxTokens > 0 {
+		return role.MaxTokens
+	}
+	return estimate
+}
+
+// inputFilesTokens returns the size of the input files, 0 when they cannot be read.
+func inputFilesTokens(setup fileutils.CollectSetup) int {
+	if setup.Root == "" {
+		return 0
+	}
+	fileList, _, err := fileutils.CollectFiles(setup)
+	if err != nil {
+		return 0
+	}
+	files, err := fileutils.MarshalFileList(fileList)
+	if err != nil {
+		return 0
+	}
+	return textTokens(files)
+}
+
+// writePlan prints the blocks with their inputs and the estimates of their roles.
+func writePlan(w io.Writer, blocks []planBlock) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "STAGE\tBLOCK\tINPUTS\tITERATIONS\tFILES OUTPUT")
+	for _, b := range blocks {
+		inputs := strings.Join(b.Inputs, ", ")
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%d\t%t\n", b.Stage, b.Name, valueOrDefault(inputs, "-"), b.Iterations, b.FilesOutput)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w)
+	var records []usage.Record
+	fmt.Fprintln(tw, "BLOCK\tROLE\tASSISTANT\tPROVIDER\tMODEL\tCALLS\tINPUT\tOUTPUT\tCOST USD")
+	for _, b := range blocks {
+		for _, r := range b.Roles {
+			records = append(records, r.Estimate)
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%d\t%d\t%d\t%s\n",
+				b.Name,
+				r.Kind,
+				r.Name,
+				r.Provider,
+				r.Model,
+				r.Estimate.Calls,
+				r.Estimate.InputTokens,
+				r.Estimate.OutputTokens,
+				r.Estimate.FormatCost(),
+			)
+		}
+	}
+	total := usage.NewReport(records).Total
+	fmt.Fprintf(tw, "TOTAL\t\t\t\t\t%d\t%d\t%d\t%s\n",
+		total.Calls, total.InputTokens, total.OutputTokens, total.FormatCost())
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nThe estimate assumes all iterations run and answers of maxTokens, or %d tokens "+
+		"for workers and %d for experts and oracles without it.\n", estimatedSolutionTokens, estimatedReviewTokens)
+	return nil
+}
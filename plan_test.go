@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+)
+
+func TestNewPlan(t *testing.T) {
+	appSetup := AppSetup{
+		Pricing: usage.Pricing{"test-model": {Input: 1, Output: 2}},
+		Blocks: []Block{
+			{Name: "design", Iterations: 2, Experts: []Role{{Name: "reviewer", System: "abcd", MaxTokens: 10}}},
+			{Name: "docs", Iterations: 1},
+		},
+	}
+	appSetup.Blocks[0].Worker.Prompt = "abcdefgh"
+	appSetup.Blocks[0].Worker.Model = "test-model"
+	appSetup.Blocks[0].Worker.MaxTokens = 100
+	appSetup.Blocks[1].Worker.Model = "gpt-4o"
+
+	blocks, err := newPlan(appSetup)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if blocks[0].Stage != 1 || blocks[1].Stage != 2 || blocks[1].Inputs[0] != "design" {
+		t.Errorf("expected docs to run after design, got %+v", blocks)
+	}
+
+	type estimate struct {
+		kind, model   string
+		input, output int
+	}
+	expected := [][]estimate{
+		{
+			// the second iteration gets the previous solution, the review and the verdict
+			{"worker", "test-model", 2*2 + 100 + 10 + 500, 2 * 100},
+			{"expert", "gpt-4o-mini", 2 * (1 + 2 + 100), 2 * 10},
+			{"oracle", "gpt-4o-mini", 2 * (2 + 100 + 10), 2 * 500},
+		},
+		{
+			// the data is the solution of design
+			{"worker", "gpt-4o", 100, 2000},
+			{"oracle", "gpt-4o-mini", 100 + 2000, 500},
+		},
+	}
+	for bn, b := range blocks {
+		if len(b.Roles) != len(expected[bn]) {
+			t.Fatalf("block %s: expected %d roles, got %+v", b.Name, len(expected[bn]), b.Roles)
+		}
+		for i, r := range b.Roles {
+			e := expected[bn][i]
+			if r.Kind != e.kind || r.Model != e.model || r.Provider != "openai" ||
+				r.Estimate.InputTokens != e.input || r.Estimate.OutputTokens != e.output {
+				t.Errorf("block %s: expected %+v, got %+v", b.Name, e, r)
+			}
+			if r.Estimate.Calls != b.Iterations {
+				t.Errorf("block %s: expected %d calls, got %d", b.Name, b.Iterations, r.Estimate.Calls)
+			}
+		}
+	}
+
+	if cost := blocks[0].Roles[0].Estimate.CostUSD; cost != (614+2*200)/1e6 {
+		t.Errorf("expected the cost of the worker tokens, got %v", cost)
+	}
+
+	var buf bytes.Buffer
+	if err := writePlan(&buf, blocks); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(buf.String(), "2      docs    design") {
+		t.Errorf("expected the blocks table, got:\n%s", buf.String())
+	}
+}
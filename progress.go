@@ -0,0 +1,69 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+	"unicode/utf8"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+)
+
+const (
+	progressInterval = 2 * time.Second
+	progressTailSize = 60
+)
+
+// progressPrinter shows the live progress of streamed responses. Every response prints
+// a line when it starts and then at most once per interval with its size so far and the
+// latest text, so concurrent streams of different assistants do not garble each other.
+type progressPrinter struct {
+	w        io.Writer
+	interval time.Duration
+
+	mu sync.Mutex
+}
+
+func newProgressPrinter(w io.Writer) *progressPrinter {
+	return &progressPrinter{w: w, interval: progressInterval}
+}
+
+// stream returns the OnStream callback of an assistant. A nil printer disables streaming.
+func (p *progressPrinter) stream(label string) func() llm.StreamHandler {
+	if p == nil {
+		return nil
+	}
+
+	return func() llm.StreamHandler {
+		// a handler is called from a single goroutine, only the writer is shared
+		var received int
+		var tail string
+		var lastPrint time.Time
+
+		return func(delta string) {
+			received += utf8.RuneCountInString(delta)
+			tail = lastRunes(tail+whitespaceReplacer.Replace(delta), progressTailSize)
+			if time.Since(lastPrint) < p.interval {
+				return
+			}
+			lastPrint = time.Now()
+
+			p.mu.Lock()
+			defer p.mu.Unlock()
+			fmt.Fprintf(p.w, "%s: %d chars | %s\n", label, received, strings.TrimSpace(tail))
+		}
+	}
+}
+
+// whitespaceReplacer keeps a progress line on a single line.
+var whitespaceReplacer = strings.NewReplacer("\r\n", " ", "\n", " ", "\r", " ", "\t", " ")
+
+func lastRunes(s string, n int) string {
+	r := []rune(s)
+	if len(r) <= n {
+		return s
+	}
+	return string(r[len(r)-n:])
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProgressPrinter(t *testing.T) {
+	var out strings.Builder
+	p := &progressPrinter{w: &out, interval: time.Hour}
+
+	onDelta := p.stream("[block] worker dev")()
+	onDelta("Hello\n")
+	onDelta("world")
+
+	// the first delta is printed right away, the next ones wait for the interval
+	expected := "[block] worker dev: 6 chars | Hello\n"
+	if out.String() != expected {
+		t.Errorf("expected %q, got %q", expected, out.String())
+	}
+
+	p.interval = 0
+	onDelta("!ż")
+	if !strings.HasSuffix(out.String(), "[block] worker dev: 13 chars | Hello world!ż\n") {
+		t.Errorf("unexpected progress %q", out.String())
+	}
+
+	var disabled *progressPrinter
+	if disabled.stream("label") != nil {
+		t.Errorf("expected no streaming for a nil printer")
+	}
+}
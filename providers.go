@@ -0,0 +1,203 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"maps"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+)
+
+const defaultProviderName = "openai"
+
+// ProviderSetup defines a named provider instance in the `providers:` section.
+type ProviderSetup struct {
+	Type      string `yaml:"type"`
+	Model     string `yaml:"model"`
+	BaseURL   string `yaml:"baseURL"`
+	APIKeyEnv string `yaml:"apiKeyEnv"`
+	// Retry overrides the default retry policy.
+	Retry *RetrySetup `yaml:"retry"`
+}
+
+// RetrySetup configures retries of failed requests, durations are given like "2s".
+type RetrySetup struct {
+	MaxAttempts     int           `yaml:"maxAttempts"`
+	InitialBackoff  time.Duration `yaml:"initialBackoff"`
+	MaxBackoff      time.Duration `yaml:"maxBackoff"`
+	MaxElapsedTime  time.Duration `yaml:"maxElapsedTime"`
+	RetryableStatus []int         `yaml:"retryableStatus"`
+}
+
+// providerRegistry holds the provider definitions of an app and lazily creates provider
+// instances. Instances are shared between roles using the same provider and base URL.
+type providerRegistry struct {
+	setups map[string]ProviderSetup
+
+	mu        sync.Mutex
+	instances map[string]llm.LLMProvider
+}
+
+// newProviderRegistry combines the built-in providers with the ones defined in the app
+// setup. Providers defined in the app setup override built-in ones with the same name.
+func newProviderRegistry(setups map[string]ProviderSetup) (*providerRegistry, error) {
+	r := &providerRegistry{
+		setups:    providerSetups(setups),
+		instances: map[string]llm.LLMProvider{},
+	}
+
+	// create all providers upfront so configuration errors are reported before any block runs
+	for name := range r.setups {
+		if _, err := r.get(name, ""); err != nil {
+			return nil, err
+		}
+	}
+
+	return r, nil
+}
+
+// providerSetups returns the built-in provider definitions overridden by the ones from the
+// app setup.
+func providerSetups(setups map[string]ProviderSetup) map[string]ProviderSetup {
+	all := map[string]ProviderSetup{
+		"openai": {Type: "openai"},
+	}
+	if os.Getenv("ANTHROPIC_API_KEY") != "" {
+		all["anthropic"] = ProviderSetup{Type: "anthropic"}
+	}
+	maps.Copy(all, setups)
+	return all
+}
+
+// get returns the named provider, baseURL overrides the URL from the provider definition.
+func (r *providerRegistry) get(name, baseURL string) (llm.LLMProvider, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	key := name + "|" + baseURL
+	if provider, ok := r.instances[key]; ok {
+		return provider, nil
+	}
+
+	setup, ok := r.setups[name]
+	if !ok {
+		return nil, fmt.Errorf("unknown provider %s", name)
+	}
+	if baseURL != "" {
+		setup.BaseURL = baseURL
+	}
+
+	provider, err := newProvider(setup)
+	if err != nil {
+		return nil, fmt.Errorf("error creating provider %s: %w", name, err)
+	}
+	r.instances[key] = provider
+
+	return provider, nil
+}
+
+// providerAPIKeys returns the API keys of the built-in providers and the ones defined in
+// the app setup, so they can be masked in logs.
+func providerAPIKeys(setups map[string]ProviderSetup) []string {
+	envs := []string{"OPENAI_API_KEY", "ANTHROPIC_API_KEY"}
+	for _, setup := range setups {
+		if setup.APIKeyEnv != "" {
+			envs = append(envs, setup.APIKeyEnv)
+		}
+	}
+
+	var keys []string
+	for _, env := range envs {
+		if key := os.Getenv(env); key != "" {
+			keys = append(keys, key)
+		}
+	}
+	return keys
+}
+
+func newProvider(setup ProviderSetup) (llm.LLMProvider, error) {
+	provider, err := newProviderOfType(setup)
+	if err != nil {
+		return nil, err
+	}
+
+	if setup.Retry != nil {
+		if p, ok := provider.(interface{ SetRetryPolicy(llm.RetryPolicy) }); ok {
+			p.SetRetryPolicy(setup.Retry.policy())
+		}
+	}
+
+	return provider, nil
+}
+
+func newProviderOfType(setup ProviderSetup) (llm.LLMProvider, error) {
+	switch setup.Type {
+	case "openai":
+		apiKey := os.Getenv(valueOrDefault(setup.APIKeyEnv, "OPENAI_API_KEY"))
+		return llm.NewOpenAIWithStructuredOutputProvider(apiKey, setup.defaultModel(), setup.BaseURL), nil
+	case "anthropic":
+		apiKey := os.Getenv(valueOrDefault(setup.APIKeyEnv, "ANTHROPIC_API_KEY"))
+		return llm.NewAnthropicProvider(apiKey, setup.defaultModel(), setup.BaseURL), nil
+	case "ollama":
+		if setup.Model == "" {
+			return nil, errors.New("ollama provider requires a model")
+		}
+		return llm.NewOllamaProvider(setup.Model, setup.BaseURL), nil
+	default:
+		return nil, fmt.Errorf("unknown provider type %q", setup.Type)
+	}
+}
+
+// defaultModel returns the model used by roles without their own model.
+func (setup ProviderSetup) defaultModel() string {
+	switch setup.Type {
+	case "openai":
+		return valueOrDefault(setup.Model, "gpt-4o-mini")
+	case "anthropic":
+		return valueOrDefault(setup.Model, "claude-3-5-haiku-latest")
+	default:
+		return setup.Model
+	}
+}
+
+// supportsTools tells whether roles of the provider can call tools, the other providers
+// fail the requests with tools.
+func (setup ProviderSetup) supportsTools() bool {
+	return setup.Type == "openai"
+}
+
+// toolsError returns the error of a role with tools on a provider that does not support them.
+func (setup ProviderSetup) toolsError(name string) error {
+	return fmt.Errorf("tools are not supported by provider %s of type %s", name, setup.Type)
+}
+
+// policy applies the settings on top of the default retry policy.
+func (rs RetrySetup) policy() llm.RetryPolicy {
+	policy := llm.DefaultRetryPolicy()
+	if rs.MaxAttempts > 0 {
+		policy.MaxAttempts = rs.MaxAttempts
+	}
+	if rs.InitialBackoff > 0 {
+		policy.InitialBackoff = rs.InitialBackoff
+	}
+	if rs.MaxBackoff > 0 {
+		policy.MaxBackoff = rs.MaxBackoff
+	}
+	if rs.MaxElapsedTime > 0 {
+		policy.MaxElapsedTime = rs.MaxElapsedTime
+	}
+	if rs.RetryableStatus != nil {
+		policy.RetryableStatus = rs.RetryableStatus
+	}
+	return policy
+}
+
+func valueOrDefault(value, defaultValue string) string {
+	if value == "" {
+		return defaultValue
+	}
+	return value
+}
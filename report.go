@@ -0,0 +1,420 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
+	thinkingblock "github.com/aszmajdzinski/llm-feedback-loop-executor/thinking_block"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+)
+
+const (
+	reportFileName = "report.html"
+	// diffContext is the number of unchanged lines shown around a change, longer runs of
+	// unchanged lines are collapsed.
+	diffContext = 3
+)
+
+// diffRowKind tells how a row of a side-by-side diff is shown.
+type diffRowKind string
+
+const (
+	rowEqual   diffRowKind = "equal"
+	rowChanged diffRowKind = "changed"
+	rowDeleted diffRowKind = "deleted"
+	rowAdded   diffRowKind = "added"
+	// rowSkipped stands for collapsed unchanged lines.
+	rowSkipped diffRowKind = "skipped"
+)
+
+// diffRow is a row of a side-by-side diff, line numbers are 0 for a missing side.
+type diffRow struct {
+	Kind    diffRowKind
+	LeftNo  int
+	Left    string
+	RightNo int
+	Right   string
+	// Skipped is the number of unchanged lines of a skipped row.
+	Skipped int
+}
+
+// reportFile is the diff of a single file, or of the whole solution of a text block.
+type reportFile struct {
+	Name    string
+	Status  string
+	Added   int
+	Removed int
+	Rows    []diffRow
+}
+
+type reportExpert struct {
+	Name   string
+	Answer string
+}
+
+type reportIteration struct {
+	Index      int
+	Final      bool
+	Unfinished bool
+	Files      []reportFile
+	// Solution is set for the first iteration of a text block, which has nothing to
+	// compare with.
+	Solution     string
+	Edits        string
+	FailedEdits  []string
+	Experts      []reportExpert
+	Verdict      thinkingblock.OracleVerdict
+	Verification string
+	Verified     bool
+	Usage        []usage.Record
+	Total        usage.Record
+}
+
+type reportBlock struct {
+	Name        string
+	FilesOutput bool
+	StopReason  thinkingblock.StopReason
+	Error       string
+	Iterations  []reportIteration
+	Total       usage.Record
+}
+
+type reportData struct {
+	Generated time.Time
+	Blocks    []reportBlock
+	Total     usage.Record
+}
+
+// writeReport writes report.html to the output directory with every iteration of every
+// block and the changes of the worker solution between iterations.
+func writeReport(
+	ctx context.Context,
+	appSetup AppSetup,
+	opts runOptions,
+	answers []thinkingblock.ThinkingBlockOutput,
+	errs []error,
+) {
+	logger := loggerutils.GetLogger(ctx)
+	pricing := usage.DefaultPricing().Override(appSetup.Pricing)
+
+	data := newReportData(appSetup, answers, errs, pricing)
+	data.Generated = time.Now()
+
+	f, err := os.Create(filepath.Join(opts.OutputDir, reportFileName))
+	if err != nil {
+		logger.Error("error writing run report", "error", err)
+		return
+	}
+	defer f.Close()
+
+	err = renderReport(f, data)
+	if err != nil {
+		logger.Error("error writing run report", "error", err)
+	}
+}
+
+func newReportData(
+	appSetup AppSetup,
+	answers []thinkingblock.ThinkingBlockOutput,
+	errs []error,
+	pricing usage.Pricing,
+) reportData {
+	var data reportData
+	var allRecords []usage.Record
+	for bn, b := range appSetup.Blocks {
+		ans := answers[bn]
+		records := usageRecords(b, ans, pricing)
+		allRecords = append(allRecords, records...)
+
+		block := reportBlock{
+			Name:        b.Name,
+			FilesOutput: b.FilesOutput,
+			StopReason:  ans.StopReason,
+			Total:       usage.NewReport(records).Total,
+		}
+		switch {
+		case errors.Is(errs[bn], errDependencyFailed):
+			block.Error = "not run, an input block failed"
+		case errors.Is(errs[bn], errNotSelected):
+			block.Error = "not run, not selected"
+		case errors.Is(errs[bn], errRunCanceled):
+			block.Error = "stopped, another block failed"
+		case errs[bn] != nil:
+			block.Error = errs[bn].Error()
+		}
+
+		previous := ""
+		for i, pa := range ans.PartAnswers {
+			iteration := reportIteration{
+				Index:      i,
+				Final:      i == ans.FinalIteration && ans.FinalAnswer != "",
+				Unfinished: pa.Unfinished,
+				Edits:      pa.WorkerEdits,
+				Verdict:    pa.OracleVerdict,
+			}
+			if b.FilesOutput {
+				iteration.Files = diffSolutions(previous, pa.WorkerSolution)
+			} else if i == 0 {
+				iteration.Solution = pa.WorkerSolution
+			} else {
+				iteration.Files = []reportFile{diffFile("", previous, pa.WorkerSolution)}
+			}
+			for _, f := range pa.FailedEdits {
+				iteration.FailedEdits = append(iteration.FailedEdits, f.String())
+			}
+			for eIdx, answer := range pa.ExpertAnswers {
+				name := ""
+				if eIdx < len(pa.ExpertNames) {
+					name = pa.ExpertNames[eIdx]
+				}
+				iteration.Experts = append(iteration.Experts, reportExpert{Name: name, Answer: answer})
+			}
+			if pa.Verification != nil {
+				iteration.Verification = pa.Verification.String()
+				iteration.Verified = pa.Verification.Passed()
+			}
+			for _, r := range records {
+				if r.Iteration == i {
+					iteration.Usage = append(iteration.Usage, r)
+				}
+			}
+			iteration.Total = usage.NewReport(iteration.Usage).Total
+
+			block.Iterations = append(block.Iterations, iteration)
+			previous = pa.WorkerSolution
+		}
+		data.Blocks = append(data.Blocks, block)
+	}
+	data.Total = usage.NewReport(allRecords).Total
+
+	return data
+}
+
+// diffSolutions compares two files solutions per file, in the order of the new solution
+// followed by the deleted files. A solution that is not a file list is compared as text.
+func diffSolutions(oldSolution, newSolution string) []reportFile {
+	oldFiles, oldErr := parseFileList(oldSolution)
+	newFiles, newErr := parseFileList(newSolution)
+	if oldErr != nil || newErr != nil {
+		return []reportFile{diffFile("", oldSolution, newSolution)}
+	}
+
+	oldContent := make(map[string]string, len(oldFiles.Files))
+	for _, f := range oldFiles.Files {
+		oldContent[f.FileName] = f.FileContent
+	}
+
+	var files []reportFile
+	var names []string
+	for _, f := range newFiles.Files {
+		old, ok := oldContent[f.FileName]
+		file := diffFile(f.FileName, old, f.FileContent)
+		if !ok {
+			file.Status = "new"
+		}
+		files = append(files, file)
+		names = append(names, f.FileName)
+	}
+	for _, f := range oldFiles.Files {
+		if slices.Contains(names, f.FileName) {
+			continue
+		}
+		file := diffFile(f.FileName, f.FileContent, "")
+		file.Status = "deleted"
+		files = append(files, file)
+	}
+	return files
+}
+
+func parseFileList(solution string) (fileutils.FileList, error) {
+	var fileList fileutils.FileList
+	if solution == "" {
+		return fileList, nil
+	}
+	err := json.Unmarshal([]byte(solution), &fileList)
+	return fileList, err
+}
+
+// diffFile compares two versions of a file as side-by-side rows, an unchanged file has
+// no rows.
+func diffFile(name string, oldText, newText string) reportFile {
+	file := reportFile{Name: name, Status: "modified"}
+	lines := fileutils.DiffLines(fileutils.SplitLines(oldText), fileutils.SplitLines(newText))
+
+	var rows []diffRow
+	leftNo, rightNo := 1, 1
+	for i := 0; i < len(lines); {
+		if lines[i].Op == fileutils.DiffEqual {
+			rows = append(rows, diffRow{Kind: rowEqual, LeftNo: leftNo, Left: lines[i].Text, RightNo: rightNo, Right: lines[i].Text})
+			leftNo++
+			rightNo++
+			i++
+			continue
+		}
+
+		// the deleted and inserted lines of a change are paired side by side
+		var deleted, inserted []string
+		for ; i < len(lines) && lines[i].Op != fileutils.DiffEqual; i++ {
+			if lines[i].Op == fileutils.DiffDelete {
+				deleted = append(deleted, lines[i].Text)
+			} else {
+				inserted = append(inserted, lines[i].Text)
+			}
+		}
+		file.Removed += len(deleted)
+		file.Added += len(inserted)
+		for j := range max(len(deleted), len(inserted)) {
+			row := diffRow{Kind: rowChanged}
+			if j < len(deleted) {
+				row.LeftNo, row.Left = leftNo, deleted[j]
+				leftNo++
+			} else {
+				row.Kind = rowAdded
+			}
+			if j < len(inserted) {
+				row.RightNo, row.Right = rightNo, inserted[j]
+				rightNo++
+			} else {
+				row.Kind = rowDeleted
+			}
+			rows = append(rows, row)
+		}
+	}
+
+	if file.Added == 0 && file.Removed == 0 {
+		file.Status = "unchanged"
+		return file
+	}
+	file.Rows = collapseUnchanged(rows)
+	return file
+}
+
+// collapseUnchanged replaces unchanged rows further than diffContext from a change with
+// a single skipped row.
+func collapseUnchanged(rows []diffRow) []diffRow {
+	var res []diffRow
+	for i := 0; i < len(rows); {
+		if rows[i].Kind != rowEqual {
+			res = append(res, rows[i])
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(rows) && rows[end].Kind == rowEqual {
+			end++
+		}
+		keepBefore, keepAfter := diffContext, diffContext
+		if i == 0 {
+			keepBefore = 0
+		}
+		if end == len(rows) {
+			keepAfter = 0
+		}
+		if end-i <= keepBefore+keepAfter+1 {
+			res = append(res, rows[i:end]...)
+		} else {
+			res = append(res, rows[i:i+keepBefore]...)
+			res = append(res, diffRow{Kind: rowSkipped, Skipped: end - i - keepBefore - keepAfter})
+			res = append(res, rows[end-keepAfter:end]...)
+		}
+		i = end
+	}
+	return res
+}
+
+func renderReport(w io.Writer, data reportData) error {
+	return reportTemplate.Execute(w, data)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{
+	"latency": func(d time.Duration) time.Duration { return d.Round(time.Millisecond) },
+}).Parse(reportHTML))
+
+// reportHTML is self-contained, so the report can be shared as a single file.
+const reportHTML = `<!DOCTYPE html>
+<html lang="en">
+<head>
+<meta charset="utf-8">
+<title>Run report</title>
+<style>
+body { font-family: system-ui, sans-serif; margin: 2em; color: #222; }
+h2 { border-bottom: 2px solid #ccc; padding-bottom: .2em; }
+details { margin: .5em 0; }
+summary { cursor: pointer; }
+.iteration { border: 1px solid #ddd; border-radius: 6px; padding: .5em 1em; margin: 1em 0; }
+.final { border-color: #2a7; }
+.badge { display: inline-block; padding: 0 .5em; border-radius: 4px; background: #eee; font-size: .85em; }
+.ok { background: #cfc; }
+.fail { background: #fcc; }
+.error { color: #b00; }
+pre, .diff td { font-family: ui-monospace, monospace; font-size: .85em; white-space: pre-wrap; word-break: break-all; }
+pre { background: #f7f7f7; padding: .5em; }
+table { border-collapse: collapse; }
+.stats td, .stats th { padding: .1em .6em; text-align: right; }
+.stats td:nth-child(-n+3), .stats th:nth-child(-n+3) { text-align: left; }
+.diff { width: 100%; table-layout: fixed; }
+.diff td { vertical-align: top; padding: 0 .4em; }
+.diff td.no { width: 3em; color: #999; text-align: right; }
+.diff tr.deleted td.l, .diff tr.changed td.l { background: #fdd; }
+.diff tr.added td.r, .diff tr.changed td.r { background: #dfd; }
+.diff tr.skipped td { background: #eef; color: #669; text-align: center; }
+</style>
+</head>
+<body>
+<h1>Run report</h1>
+<p>Generated {{.Generated.Format "2006-01-02 15:04:05"}}, {{.Total.Calls}} LLM calls, {{.Total.TotalTokens}} tokens, cost USD {{.Total.FormatCost}}</p>
+{{range .Blocks}}
+<h2>{{.Name}}</h2>
+<p>
+{{if .Error}}<span class="error">{{.Error}}</span>{{else}}<span class="badge">{{.StopReason}}</span>{{end}}
+{{len .Iterations}} iterations, {{.Total.TotalTokens}} tokens, {{latency .Total.Latency}}, cost USD {{.Total.FormatCost}}
+</p>
+{{range .Iterations}}
+<div class="iteration{{if .Final}} final{{end}}">
+<h3>Iteration {{.Index}}
+{{if .Final}}<span class="badge ok">final</span>{{end}}
+{{if .Unfinished}}<span class="badge fail">unfinished</span>{{else}}<span class="badge{{if .Verdict.Accept}} ok{{end}}">score {{.Verdict.Score}}{{if .Verdict.Accept}}, accepted{{end}}</span>{{end}}
+</h3>
+<details open><summary>Worker solution</summary>
+{{if .Solution}}<pre>{{.Solution}}</pre>{{end}}
+{{range .Files}}
+<details{{if .Rows}} open{{end}}><summary>{{if .Name}}{{.Name}} {{end}}<span class="badge">{{.Status}}</span> +{{.Added}} -{{.Removed}}</summary>
+{{if .Rows}}<table class="diff">
+{{range .Rows}}{{if eq .Kind "skipped"}}<tr class="skipped"><td colspan="4">{{.Skipped}} unchanged lines</td></tr>
+{{else}}<tr class="{{.Kind}}"><td class="no">{{if .LeftNo}}{{.LeftNo}}{{end}}</td><td class="l">{{.Left}}</td><td class="no">{{if .RightNo}}{{.RightNo}}{{end}}</td><td class="r">{{.Right}}</td></tr>
+{{end}}{{end}}</table>{{end}}
+</details>
+{{end}}
+</details>
+{{if .Edits}}<details><summary>Worker edits</summary><pre>{{.Edits}}</pre>
+{{range .FailedEdits}}<p class="error">FAILED: {{.}}</p>{{end}}
+</details>{{end}}
+{{if .Verification}}<details><summary>Verification <span class="badge {{if .Verified}}ok{{else}}fail{{end}}">{{if .Verified}}passed{{else}}failed{{end}}</span></summary><pre>{{.Verification}}</pre></details>{{end}}
+{{range .Experts}}<details><summary>Expert {{.Name}}</summary><pre>{{.Answer}}</pre></details>
+{{end}}
+{{if not .Unfinished}}<details open><summary>Oracle</summary>
+<p>{{.Verdict.Summary}}</p>
+{{if .Verdict.MustFix}}<p>Must fix:</p><ul>{{range .Verdict.MustFix}}<li>{{.}}</li>{{end}}</ul>{{end}}
+</details>{{end}}
+<details><summary>Usage: {{.Total.TotalTokens}} tokens, {{latency .Total.Latency}}, cost USD {{.Total.FormatCost}}</summary>
+<table class="stats">
+<tr><th>Role</th><th>Assistant</th><th>Model</th><th>Calls</th><th>Input</th><th>Output</th><th>Total</th><th>Latency</th><th>Cost USD</th></tr>
+{{range .Usage}}<tr><td>{{.Role}}</td><td>{{.Assistant}}</td><td>{{.Model}}</td><td>{{.Calls}}</td><td>{{.InputTokens}}</td><td>{{.OutputTokens}}</td><td>{{.TotalTokens}}</td><td>{{latency .Latency}}</td><td>{{.FormatCost}}</td></tr>
+{{end}}</table>
+</details>
+</div>
+{{end}}
+{{end}}
+</body>
+</html>
+`
@@ -0,0 +1,135 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	thinkingblock "github.com/aszmajdzinski/llm-feedback-loop-executor/thinking_block"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+)
+
+func TestDiffFile(t *testing.T) {
+	var oldLines, newLines []string
+	for i := 1; i <= 20; i++ {
+		oldLines = append(oldLines, fmt.Sprintf("line %d", i))
+	}
+	newLines = append(newLines, oldLines...)
+	newLines[9] = "changed 10"
+	newLines = append(newLines[:15], newLines[16:]...)
+	newLines = append(newLines, "added")
+
+	file := diffFile("a.txt", strings.Join(oldLines, "\n"), strings.Join(newLines, "\n"))
+	if file.Status != "modified" || file.Added != 2 || file.Removed != 2 {
+		t.Errorf("unexpected file %s +%d -%d", file.Status, file.Added, file.Removed)
+	}
+
+	var kinds []string
+	for _, r := range file.Rows {
+		kind := string(r.Kind)
+		if r.Kind == rowSkipped {
+			kind = fmt.Sprintf("skipped %d", r.Skipped)
+		}
+		kinds = append(kinds, kind)
+	}
+	expected := []string{
+		"skipped 6", "equal", "equal", "equal", "changed", "equal", "equal", "equal", "equal", "equal",
+		"deleted", "equal", "equal", "equal", "equal", "added",
+	}
+	if strings.Join(kinds, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected rows %v", kinds)
+	}
+
+	changed := file.Rows[4]
+	if changed.LeftNo != 10 || changed.Left != "line 10" || changed.RightNo != 10 || changed.Right != "changed 10" {
+		t.Errorf("unexpected changed row %+v", changed)
+	}
+	added := file.Rows[len(file.Rows)-1]
+	if added.LeftNo != 0 || added.RightNo != 20 || added.Right != "added" {
+		t.Errorf("unexpected added row %+v", added)
+	}
+
+	if file := diffFile("", "same\n", "same\n"); file.Status != "unchanged" || file.Rows != nil {
+		t.Errorf("expected an unchanged file without rows, got %+v", file)
+	}
+}
+
+func TestDiffSolutions(t *testing.T) {
+	files := diffSolutions(
+		`{"files": [{"fileName": "a.go", "fileContent": "a"}, {"fileName": "b.go", "fileContent": "b"}, {"fileName": "c.go", "fileContent": "c"}]}`,
+		`{"files": [{"fileName": "d.go", "fileContent": "d"}, {"fileName": "b.go", "fileContent": "b"}, {"fileName": "a.go", "fileContent": "a2"}]}`,
+	)
+
+	var got []string
+	for _, f := range files {
+		got = append(got, fmt.Sprintf("%s %s +%d -%d", f.Name, f.Status, f.Added, f.Removed))
+	}
+	expected := []string{"d.go new +1 -0", "b.go unchanged +0 -0", "a.go modified +1 -1", "c.go deleted +0 -1"}
+	if strings.Join(got, ",") != strings.Join(expected, ",") {
+		t.Errorf("unexpected files %v", got)
+	}
+
+	files = diffSolutions("", "not json")
+	if len(files) != 1 || files[0].Name != "" || files[0].Added != 1 {
+		t.Errorf("expected a text diff of a solution that is not a file list, got %+v", files)
+	}
+}
+
+func TestRenderReport(t *testing.T) {
+	var appSetup AppSetup
+	appSetup.Blocks = []Block{{Name: "code", FilesOutput: true}, {Name: "docs"}}
+	appSetup.Blocks[0].Worker.Name = "coder"
+	appSetup.Blocks[0].Oracle.Name = "judge"
+
+	answers := []thinkingblock.ThinkingBlockOutput{
+		{
+			PartAnswers: []thinkingblock.PartialAnswer{
+				{
+					WorkerSolution: `{"files": [{"fileName": "main.go", "fileContent": "package main\n"}]}`,
+					ExpertNames:    []string{"reviewer"},
+					ExpertAnswers:  []string{"use <b>fmt</b>"},
+					OracleVerdict:  thinkingblock.OracleVerdict{Score: 4, Summary: "missing fmt", MustFix: []string{"import fmt"}},
+					WorkerUsage:    llm.Usage{Model: "gpt-4o", Calls: 1, TokenUsage: llm.TokenUsage{TotalTokens: 1200}},
+				},
+				{
+					WorkerSolution: `{"files": [{"fileName": "main.go", "fileContent": "package main\n\nimport \"fmt\"\n"}]}`,
+					OracleVerdict:  thinkingblock.OracleVerdict{Accept: true, Score: 9, Summary: "good"},
+				},
+			},
+			FinalAnswer:    "{}",
+			FinalIteration: 1,
+			StopReason:     thinkingblock.StopAccepted,
+		},
+		{},
+	}
+	errs := []error{nil, errDependencyFailed}
+
+	var buf bytes.Buffer
+	err := renderReport(&buf, newReportData(appSetup, answers, errs, usage.DefaultPricing()))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	report := buf.String()
+
+	for _, s := range []string{
+		"<h2>code</h2>",
+		"Iteration 1",
+		`main.go <span class="badge">modified</span> +2 -0`,
+		`<td class="r">import &#34;fmt&#34;</td>`,
+		"use &lt;b&gt;fmt&lt;/b&gt;",
+		"<li>import fmt</li>",
+		"<td>worker</td><td>coder</td><td>gpt-4o</td><td>1</td>",
+		"score 9, accepted",
+		"<h2>docs</h2>",
+		"not run, an input block failed",
+	} {
+		if !strings.Contains(report, s) {
+			t.Errorf("expected the report to contain %q", s)
+		}
+	}
+	if strings.Contains(report, "<b>fmt</b>") {
+		t.Errorf("expected the answers to be escaped")
+	}
+}
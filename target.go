@@ -0,0 +1,72 @@
+package main
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
+	"gopkg.in/yaml.v3"
+)
+
+// targetChangesFileName is the pre-write summary saved next to the block conversation.
+const targetChangesFileName = "target-changes.txt"
+
+// BlockTarget is the `target:` section of a block, either a directory or a mapping with
+// `dir` and `policy`.
+type BlockTarget struct {
+	// Dir is an existing project directory the output files are written to, in addition to
+	// the answers directory of the run. A relative path is used as given, relative to the
+	// working directory of the process.
+	Dir string `yaml:"dir"`
+	// Policy decides what happens to existing files, fail-if-exists by default.
+	Policy fileutils.WritePolicy `yaml:"policy"`
+}
+
+func (t *BlockTarget) UnmarshalYAML(value *yaml.Node) error {
+	if value.Kind == yaml.ScalarNode {
+		t.Dir = value.Value
+		return nil
+	}
+
+	type plain BlockTarget
+	return value.Decode((*plain)(t))
+}
+
+// writeBlockTarget writes the output files of a block to its target directory. The
+// changes are logged and saved to conversationsDir before anything is written, backups
+// go to backupDir.
+func writeBlockTarget(
+	ctx context.Context,
+	target BlockTarget,
+	answer string,
+	limits fileutils.SaveLimits,
+	conversationsDir string,
+	backupDir string,
+) error {
+	logger := loggerutils.GetLogger(ctx)
+
+	var fileList fileutils.FileList
+	if err := json.Unmarshal([]byte(answer), &fileList); err != nil {
+		return fmt.Errorf("cannot parse output files: %w", err)
+	}
+
+	plan, err := fileutils.PlanTarget(target.Dir, fileList, target.Policy, limits)
+	if err != nil {
+		return err
+	}
+	for _, s := range plan.Skipped {
+		logger.Warn("Rejected output file", "file", s.FileName, "reason", s.Reason)
+	}
+
+	summary := plan.Changes.Summary()
+	logger.Info("Writing output files to target", "dir", target.Dir, "policy", plan.Policy, "changes", summary)
+	err = fileutils.WriteToFile(filepath.Join(conversationsDir, targetChangesFileName), summary)
+	if err != nil {
+		return fmt.Errorf("error saving target changes: %w", err)
+	}
+
+	return plan.Write(backupDir)
+}
@@ -0,0 +1,61 @@
+package main
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+	"gopkg.in/yaml.v3"
+)
+
+func TestBlockTargetUnmarshal(t *testing.T) {
+	var blocks []Block
+	err := yaml.Unmarshal([]byte(`
+- name: a
+  target: ./project
+- name: b
+  target:
+    dir: ./other
+    policy: backup
+`), &blocks)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if blocks[0].Target != (BlockTarget{Dir: "./project"}) {
+		t.Errorf("unexpected target %+v", blocks[0].Target)
+	}
+	if blocks[1].Target != (BlockTarget{Dir: "./other", Policy: fileutils.PolicyBackup}) {
+		t.Errorf("unexpected target %+v", blocks[1].Target)
+	}
+}
+
+func TestWriteBlockTarget(t *testing.T) {
+	dir := t.TempDir()
+	conversationsDir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "main.go"), []byte("old\n"), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	answer := `{"files": [{"fileName": "main.go", "fileContent": "new\n"}]}`
+
+	err := writeBlockTarget(context.Background(), BlockTarget{Dir: dir}, answer, fileutils.SaveLimits{}, conversationsDir, "")
+	if err == nil || !strings.Contains(err.Error(), "main.go") {
+		t.Errorf("expected the existing file to fail the default policy, got %v", err)
+	}
+
+	summary, err := os.ReadFile(filepath.Join(conversationsDir, targetChangesFileName))
+	if err != nil || !strings.Contains(string(summary), "modified   main.go (+1 -1)") {
+		t.Errorf("expected the changes to be saved before writing, got %q (%v)", summary, err)
+	}
+
+	target := BlockTarget{Dir: dir, Policy: fileutils.PolicyOverwrite}
+	if err := writeBlockTarget(context.Background(), target, answer, fileutils.SaveLimits{}, conversationsDir, ""); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if data, _ := os.ReadFile(filepath.Join(dir, "main.go")); string(data) != "new\n" {
+		t.Errorf("expected the file to be overwritten, got %q", data)
+	}
+}
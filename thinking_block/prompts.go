@@ -0,0 +1,201 @@
+package thinkingblock
+
+import (
+	"fmt"
+	"strings"
+	"text/template"
+)
+
+// Templates holds the text/template sources of the prompts sent in the loop. Empty fields
+// fall back to the built-in defaults. Templates are executed with PromptData.
+type Templates struct {
+	// Worker is used in the first iteration to ask for a solution.
+	Worker string `yaml:"worker"`
+	// WorkerRefine is used in the next iterations to ask for a refined solution.
+	WorkerRefine string `yaml:"workerRefine"`
+	// WorkerEdit replaces WorkerRefine in the edits refinement, it asks for edits of the
+	// solution files.
+	WorkerEdit string `yaml:"workerEdit"`
+	// Expert is sent to every expert to review a solution.
+	Expert string `yaml:"expert"`
+	// Oracle asks the oracle to summarize the reviews.
+	Oracle string `yaml:"oracle"`
+}
+
+// PromptData is available in all templates. Solution, Summary and Reviews are empty when
+// they are not known yet, e.g. Summary in the first iteration. Verification holds the
+// results of the verifier commands run against Solution, empty without a verifier.
+// FailedEdits lists the edits of the previous worker response that could not be applied
+// in the edits refinement.
+type PromptData struct {
+	Task         string
+	Data         string
+	Solution     string
+	Summary      string
+	Reviews      string
+	Verification string
+	FailedEdits  string
+	Iteration    int
+}
+
+const defaultWorkerTemplate string = "" +
+	"{{if .Data}}You will be given a TASK and some DATA. " +
+	"Your job is to provide a solution to the TASK using the provided DATA. " +
+	"{{else}}You will be given a TASK. " +
+	"Your job is to provide a solution to the TASK. " +
+	"{{end}}" +
+	"Ensure that your solution is as accurate and complete as possible.\n" +
+	"TASK: {{.Task}}\n" +
+	"{{if .Data}}DATA: {{.Data}}\n{{end}}"
+
+const defaultWorkerRefineTemplate string = "" +
+	"{{if .Data}}You will be given a TASK, some DATA, a SOLUTION, and a SUMMARY of feedback from experts. " +
+	"Your job is to refine the SOLUTION based on the feedback provided and the DATA. " +
+	"Ensure that the final solution is accurate, complete, and incorporates all the improvements " +
+	"suggested by the experts while utilizing the provided DATA." +
+	"{{else}}You will be given a TASK, a SOLUTION and a SUMMARY of feedback from experts. " +
+	"Your job is to refine the SOLUTION based on the feedback provided. " +
+	"Ensure that the final solution is accurate, complete, and incorporates all the improvements " +
+	"suggested by the experts." +
+	"{{end}}\n" +
+	"TASK: {{.Task}}\n" +
+	"SOLUTION: {{.Solution}}\n" +
+	"SUMMARY: {{.Summary}}\n" +
+	"{{if .Verification}}VERIFICATION (commands run against the SOLUTION, every failure must be fixed):\n" +
+	"{{.Verification}}\n{{end}}" +
+	"{{if .Data}}DATA: {{.Data}}\n{{end}}"
+
+const defaultWorkerEditTemplate string = "" +
+	"{{if .Data}}You will be given a TASK, some DATA, a SOLUTION made of files, and a SUMMARY of feedback from experts. " +
+	"Your job is to refine the SOLUTION based on the feedback provided and the DATA. " +
+	"{{else}}You will be given a TASK, a SOLUTION made of files and a SUMMARY of feedback from experts. " +
+	"Your job is to refine the SOLUTION based on the feedback provided. " +
+	"{{end}}" +
+	"Ensure that the final solution is accurate, complete, and incorporates all the improvements " +
+	"suggested by the experts.\n" +
+	"Do not repeat the whole SOLUTION, return only the edits of the files that change. " +
+	"To change a part of a file use the modify action with hunks: every search text must be copied exactly " +
+	"from the file and must appear in it only once, include enough surrounding lines to make it unique. " +
+	"To create a file or replace all of its content use the write action with the whole fileContent. " +
+	"To remove a file use the delete action. Files without edits are kept as they are.\n" +
+	"TASK: {{.Task}}\n" +
+	"SOLUTION: {{.Solution}}\n" +
+	"SUMMARY: {{.Summary}}\n" +
+	"{{if .Verification}}VERIFICATION (commands run against the SOLUTION, every failure must be fixed):\n" +
+	"{{.Verification}}\n{{end}}" +
+	"{{if .FailedEdits}}FAILED EDITS (edits of your previous response that could not be applied, " +
+	"the SOLUTION does not contain them):\n{{.FailedEdits}}\n{{end}}" +
+	"{{if .Data}}DATA: {{.Data}}\n{{end}}"
+
+const defaultExpertTemplate string = "" +
+	"{{if .Data}}You will be given a TASK, some DATA, and a SOLUTION. " +
+	"Your job is to review the SOLUTION using the provided TASK and DATA and provide feedback on its accuracy, " +
+	"{{else}}You will be given a TASK and a SOLUTION. " +
+	"Your job is to review the SOLUTION and provide feedback on its accuracy, " +
+	"{{end}}" +
+	"completeness, and any improvements that can be made. " +
+	"Remember that you are an expert with all the needed knowledge and experience.\n" +
+	"TASK: {{.Task}}\n" +
+	"{{if .Data}}DATA: {{.Data}}\n{{end}}" +
+	"SOLUTION: {{.Solution}}"
+
+const defaultOracleTemplate string = "" +
+	"{{if .Data}}You will be given a SOLUTION, its REVIEWS, and some DATA. " +
+	"Your job is to summarize the key points from the reviews, " +
+	"highlighting strengths, weaknesses, and suggestions for improvement while considering the provided DATA. " +
+	"{{else}}You will be given a SOLUTION and its REVIEWS. " +
+	"Your job is to summarize the key points from the reviews, " +
+	"highlighting strengths, weaknesses, and suggestions for improvement. " +
+	"{{end}}" +
+	"Provide a concise and clear summary. " +
+	"Put the summary in the summary field. " +
+	"Score the SOLUTION from 0 (useless) to 10 (perfect) in the score field. " +
+	"List every issue that has to be fixed before the SOLUTION can be accepted in the mustFix field. " +
+	"Do not overthink, if you see that those reviews are positive enough and nothing more should be added " +
+	"to a SOLUTION, then set accept to true and leave mustFix empty. " +
+	"Review will start with <REVIEW number>.\n" +
+	"{{if .Verification}}VERIFICATION lists the results of commands run against the SOLUTION. " +
+	"It is objective: every failed command must be listed in mustFix and the SOLUTION cannot be accepted.\n{{end}}" +
+	"SOLUTION: {{.Solution}}\n" +
+	"{{if .Data}}DATA: {{.Data}}\n{{end}}" +
+	"REVIEWS: {{.Reviews}}\n" +
+	"{{if .Verification}}VERIFICATION:\n{{.Verification}}\n{{end}}"
+
+// DefaultTemplates returns the built-in prompt templates.
+func DefaultTemplates() Templates {
+	return Templates{
+		Worker:       defaultWorkerTemplate,
+		WorkerRefine: defaultWorkerRefineTemplate,
+		WorkerEdit:   defaultWorkerEditTemplate,
+		Expert:       defaultExpertTemplate,
+		Oracle:       defaultOracleTemplate,
+	}
+}
+
+// Override returns a copy of t with the non-empty fields of override applied.
+func (t Templates) Override(override Templates) Templates {
+	if override.Worker != "" {
+		t.Worker = override.Worker
+	}
+	if override.WorkerRefine != "" {
+		t.WorkerRefine = override.WorkerRefine
+	}
+	if override.WorkerEdit != "" {
+		t.WorkerEdit = override.WorkerEdit
+	}
+	if override.Expert != "" {
+		t.Expert = override.Expert
+	}
+	if override.Oracle != "" {
+		t.Oracle = override.Oracle
+	}
+	return t
+}
+
+type promptTemplates struct {
+	worker       *template.Template
+	workerRefine *template.Template
+	workerEdit   *template.Template
+	expert       *template.Template
+	oracle       *template.Template
+}
+
+// parse parses the templates, empty ones are replaced with the defaults.
+func (t Templates) parse() (promptTemplates, error) {
+	t = DefaultTemplates().Override(t)
+
+	var pt promptTemplates
+	var err error
+	for _, tmpl := range []struct {
+		name   string
+		source string
+		target **template.Template
+	}{
+		{"worker", t.Worker, &pt.worker},
+		{"workerRefine", t.WorkerRefine, &pt.workerRefine},
+		{"workerEdit", t.WorkerEdit, &pt.workerEdit},
+		{"expert", t.Expert, &pt.expert},
+		{"oracle", t.Oracle, &pt.oracle},
+	} {
+		*tmpl.target, err = template.New(tmpl.name).Parse(tmpl.source)
+		if err != nil {
+			return promptTemplates{}, fmt.Errorf("cannot parse %s template: %w", tmpl.name, err)
+		}
+	}
+
+	return pt, nil
+}
+
+// Validate reports template syntax errors without running the loop.
+func (t Templates) Validate() error {
+	_, err := t.parse()
+	return err
+}
+
+func render(tmpl *template.Template, data PromptData) (string, error) {
+	var builder strings.Builder
+	if err := tmpl.Execute(&builder, data); err != nil {
+		return "", fmt.Errorf("cannot render %s template: %w", tmpl.Name(), err)
+	}
+	return builder.String(), nil
+}
@@ -2,60 +2,145 @@ package thinkingblock
 
 import (
 	"context"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strings"
 
 	"github.com/aszmajdzinski/llm-feedback-loop-executor/assistants"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/budget"
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
 	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/transcript"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/verifier"
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/trace"
 )
 
-const initialWorkerPrompt string = "You will be given a TASK. " +
-	"Your job is to provide a solution to the TASK. " +
-	"Ensure that your solution is as accurate and complete as possible."
+var tracer = otel.Tracer("github.com/aszmajdzinski/llm-feedback-loop-executor/thinking_block")
 
-const initialWorkerPromptWithData string = "You will be given a TASK and some DATA. " +
-	"Your job is to provide a solution to the TASK using the provided DATA. " +
-	"Ensure that your solution is as accurate and complete as possible."
+// StopReason tells why the loop of a thinking block ended.
+type StopReason string
 
-const workerPromptWithSummary string = "You will be given a TASK, a SOLUTION and a SUMMARY of feedback from experts. " +
-	"Your job is to refine the SOLUTION based on the feedback provided. " +
-	"Ensure that the final solution is accurate, complete, and incorporates all the improvements suggested by the experts."
-
-const workerPromptWithSummaryAndData string = "You will be given a TASK, some DATA, a SOLUTION, and a SUMMARY of feedback from experts. " +
-	"Your job is to refine the SOLUTION based on the feedback provided and the DATA. " +
-	"Ensure that the final solution is accurate, complete, and incorporates all the improvements suggested by the experts while utilizing the provided DATA."
-
-const expertPrompt string = "You will be given a TASK and a SOLUTION. " +
-	"Your job is to review the SOLUTION and provide feedback on its accuracy, " +
-	"completeness, and any improvements that can be made. " +
-	"Remember that you are an expert with all the needed knowledge and experience."
-
-const expertPromptWithData string = "You will be given a TASK, some DATA, and a SOLUTION. " +
-	"Your job is to review the SOLUTION using the provided TASK and DATA and provide feedback on its accuracy, " +
-	"completeness, and any improvements that can be made. " +
-	"Remember that you are an expert with all the needed knowledge and experience."
+const (
+	StopAccepted       StopReason = "accepted"
+	StopMaxIterations  StopReason = "max iterations"
+	StopBudgetExceeded StopReason = "budget exceeded"
+)
 
-const oraclePrompt string = "You will be given a SOLUTION and its REVIEWS. " +
-	"Your job is to summarize the key points from the reviews, " +
-	"highlighting strengths, weaknesses, and suggestions for improvement. " +
-	"Provide a concise and clear summary. Do not overthink, if you see that those reviews are positive enough and nothing more should be added to a SOLUTION, then simply answer \"OK\", without any other characters." +
-	"Review will start with <REVIEW number>."
+// Refinement tells how the worker refines a files solution after the first iteration.
+type Refinement string
 
-const oraclePromptWithData string = "You will be given a SOLUTION, its REVIEWS, and some DATA. " +
-	"Your job is to summarize the key points from the reviews, " +
-	"highlighting strengths, weaknesses, and suggestions for improvement while considering the provided DATA. " +
-	"Provide a concise and clear summary. Do not overthink, if you see that those reviews are positive enough and nothing more should be added to a SOLUTION, then simply answer \"OK\", without any other characters." +
-	"Review will start with <REVIEW number>."
+const (
+	// RefineFull asks the worker for all files again, it is the default.
+	RefineFull Refinement = "full"
+	// RefineEdits asks the worker for edits applied to the previous solution, which saves
+	// output tokens on large solutions.
+	RefineEdits Refinement = "edits"
+)
 
 type ThinkingBlockOutput struct {
 	Prompts     []Prompts
 	PartAnswers []PartialAnswer
 	FinalAnswer string
+	// FinalIteration is the index of the iteration FinalAnswer comes from.
+	FinalIteration int
+	StopReason     StopReason
+	// Usage sums up all LLM calls of the block.
+	Usage llm.Usage
 }
 
 type PartialAnswer struct {
 	WorkerSolution string
-	ExpertAnswers  []string
-	OracleSummary  string
+	// ExpertNames holds the names of the experts who answered, in ExpertAnswers order.
+	ExpertNames   []string
+	ExpertAnswers []string
+	OracleSummary string
+	OracleVerdict OracleVerdict
+	// Verification holds the results of the verifier commands, nil without a verifier.
+	Verification *verifier.Report
+	// WorkerEdits is the response of the worker in the edits refinement, WorkerSolution
+	// holds the files with the edits applied.
+	WorkerEdits string `json:",omitempty"`
+	// FailedEdits lists the edits of WorkerEdits that could not be applied.
+	FailedEdits []fileutils.FailedEdit `json:",omitempty"`
+
+	// Unfinished marks an iteration interrupted by the budget, it has no oracle verdict.
+	Unfinished bool
+
+	WorkerUsage llm.Usage
+	// ExpertsUsage is in ExpertAnswers order.
+	ExpertsUsage []llm.Usage
+	OracleUsage  llm.Usage
+	// FailedExperts are the experts without a review that made calls before failing,
+	// FailedExpertsUsage is in the same order.
+	FailedExperts      []string    `json:",omitempty"`
+	FailedExpertsUsage []llm.Usage `json:",omitempty"`
+}
+
+// Usage sums up all LLM calls of the iteration.
+func (pa PartialAnswer) Usage() llm.Usage {
+	usage := pa.WorkerUsage
+	for _, eu := range pa.ExpertsUsage {
+		usage = usage.Add(eu)
+	}
+	for _, eu := range pa.FailedExpertsUsage {
+		usage = usage.Add(eu)
+	}
+	return usage.Add(pa.OracleUsage)
+}
+
+// OracleVerdict is the structured answer of the oracle, Score is in the 0-10 range.
+type OracleVerdict struct {
+	Accept  bool     `json:"accept"`
+	Score   float64  `json:"score"`
+	Summary string   `json:"summary"`
+	MustFix []string `json:"mustFix"`
+}
+
+// Feedback formats the verdict as a summary for the worker.
+func (v OracleVerdict) Feedback() string {
+	if len(v.MustFix) == 0 {
+		return v.Summary
+	}
+
+	feedback := v.Summary + "\nMUST FIX:"
+	for _, mf := range v.MustFix {
+		feedback += "\n- " + mf
+	}
+	return feedback
+}
+
+// verificationFailed tells whether the verifier rejected the solution.
+func (pa PartialAnswer) verificationFailed() bool {
+	return pa.Verification != nil && !pa.Verification.Passed()
+}
+
+// verificationText is the verification report passed to the prompts.
+func (pa PartialAnswer) verificationText() string {
+	if pa.Verification == nil {
+		return ""
+	}
+	return pa.Verification.String()
+}
+
+// failedEditsText is the list of failed edits passed to the prompts.
+func (pa PartialAnswer) failedEditsText() string {
+	var lines []string
+	for _, f := range pa.FailedEdits {
+		lines = append(lines, "- "+f.String())
+	}
+	return strings.Join(lines, "\n")
+}
+
+// Verifier checks a worker solution with real commands, e.g. builds and tests of the
+// generated files. A failed verification keeps the loop going whatever the oracle says.
+type Verifier interface {
+	Verify(ctx context.Context, solution string) (verifier.Report, error)
 }
 
 type Prompts struct {
@@ -68,6 +153,20 @@ type ThinkingBlock struct {
 	Worker      assistants.Assistant
 	ExpertsTeam assistants.ExpertsTeamInterface
 	Oracle      assistants.Assistant
+	// AcceptScore stops the loop once the oracle scores a solution at least this high,
+	// even if it did not accept it. Zero disables the threshold.
+	AcceptScore float64
+	// Verifier, when set, runs after the worker and its report goes to the oracle and to
+	// the worker in the next iteration.
+	Verifier Verifier
+	// Refinement tells how files solutions are refined, RefineFull when empty.
+	Refinement Refinement
+	// Templates overrides the built-in prompts.
+	Templates Templates
+	// Previous holds iterations finished by an interrupted run, the loop continues after them.
+	Previous ThinkingBlockOutput
+	// OnIteration, when set, is called after every finished iteration, e.g. to checkpoint it.
+	OnIteration func(ctx context.Context, iteration int, prompts Prompts, answer PartialAnswer) error
 }
 
 func (tb *ThinkingBlock) Run(
@@ -76,36 +175,61 @@ func (tb *ThinkingBlock) Run(
 	data string,
 	saveOutputFiles bool,
 	iterations int,
+) (ThinkingBlockOutput, error) {
+	ctx, span := tracer.Start(ctx, "ThinkingBlock.Run", trace.WithAttributes(attribute.Int("maxIterations", iterations)))
+	defer span.End()
+
+	out, err := tb.run(ctx, taskDescription, data, saveOutputFiles, iterations)
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+		return out, err
+	}
+	span.SetAttributes(
+		attribute.Int("iterations", len(out.PartAnswers)),
+		attribute.Int("finalIteration", out.FinalIteration),
+		attribute.String("stopReason", string(out.StopReason)),
+	)
+	return out, nil
+}
+
+func (tb *ThinkingBlock) run(
+	ctx context.Context,
+	taskDescription string,
+	data string,
+	saveOutputFiles bool,
+	iterations int,
 ) (ThinkingBlockOutput, error) {
 	logger := loggerutils.GetLogger(ctx)
-	blockOutput := ThinkingBlockOutput{}
-
-	// a different prompt is used depending on whether additional data is provided
-	// worker prompts
-	var wPrompt, wPromptSummary string
-	if data != "" {
-		wPrompt = initialWorkerPromptWithData
-		wPromptSummary = workerPromptWithSummaryAndData
-	} else {
-		wPrompt = initialWorkerPrompt
-		wPromptSummary = workerPromptWithSummary
+	blockOutput := ThinkingBlockOutput{
+		Prompts:     slices.Clone(tb.Previous.Prompts),
+		PartAnswers: slices.Clone(tb.Previous.PartAnswers),
+		StopReason:  StopMaxIterations,
 	}
 
-	// experts prompts
-	// a different prompt is used depending on whether additional data is provided
-	var ePrompt string
-	if data != "" {
-		ePrompt = expertPromptWithData
-	} else {
-		ePrompt = expertPrompt
+	// continue an interrupted run unless its last iteration already finished the loop
+	start := len(blockOutput.PartAnswers)
+	if start > 0 {
+		logger.Info("Thinking block: resuming", "finishedIterations", start)
+		if tb.isAccepted(blockOutput.PartAnswers[start-1]) {
+			start = iterations
+			blockOutput.StopReason = StopAccepted
+		}
 	}
 
-	// oracle prompts
-	var oPrompt string
-	if data != "" {
-		oPrompt = oraclePromptWithData
-	} else {
-		oPrompt = oraclePrompt
+	templates, err := tb.Templates.parse()
+	if err != nil {
+		return ThinkingBlockOutput{}, err
+	}
+
+	switch tb.Refinement {
+	case "", RefineFull:
+	case RefineEdits:
+		if !saveOutputFiles {
+			return ThinkingBlockOutput{}, fmt.Errorf("%s refinement needs files output", RefineEdits)
+		}
+	default:
+		return ThinkingBlockOutput{}, fmt.Errorf("unknown refinement %q", tb.Refinement)
 	}
 
 	// use json schema for structured output or don't care about output format
@@ -114,129 +238,284 @@ func (tb *ThinkingBlock) Run(
 		s = &schema
 	}
 
-	for i := range iterations {
+	for i := start; i < iterations; i++ {
 		logger.Debug("Thinking block: iteration", "number", i)
-		currentIterationAnswer := PartialAnswer{}
-		currentIterationPrompts := Prompts{}
-
-		// 1. Prepare worker prompt depending on whether it's a first attempt to complete a task
-		// or it is making corrections according to review
-		var wP string
-		if i == 0 {
-			// 1a. If it is a first iteration, ask worker to provide solution
-			if data != "" {
-				// using provided data
-				wP = fmt.Sprintf("%s\nTASK: %s\nDATA: %s\n", wPrompt, taskDescription, data)
-			} else {
-				// no data provided, just a task
-				wP = fmt.Sprintf("%s\nTASK: %s\n", wPrompt, taskDescription)
-			}
-		} else {
-			// 1b. Else ask worker to refine a solution
-			if data != "" {
-				// using provided data
-				wP = fmt.Sprintf(
-					"%s\nTASK: %s\nSOLUTION: %s\nSUMMARY: %s\n DATA: %s\n",
-					wPromptSummary,
-					taskDescription,
-					blockOutput.PartAnswers[i-1].WorkerSolution,
-					blockOutput.PartAnswers[i-1].OracleSummary,
-					data,
-				)
-			} else {
-				// no data provided, just a task and a solution
-				wP = fmt.Sprintf(
-					"%s\nTASK: %s\nSOLUTION: %s\nSUMMARY: %s",
-					wPromptSummary,
-					taskDescription,
-					blockOutput.PartAnswers[i-1].WorkerSolution,
-					blockOutput.PartAnswers[i-1].OracleSummary,
-				)
+		ctx := transcript.WithIteration(ctx, i)
+
+		promptData := PromptData{
+			Task:      taskDescription,
+			Data:      data,
+			Iteration: i,
+		}
+		if i > 0 {
+			promptData.Solution = blockOutput.PartAnswers[i-1].WorkerSolution
+			promptData.Summary = blockOutput.PartAnswers[i-1].OracleSummary
+			promptData.Verification = blockOutput.PartAnswers[i-1].verificationText()
+			promptData.FailedEdits = blockOutput.PartAnswers[i-1].failedEditsText()
+		}
+
+		iterationCtx, iterationSpan := tracer.Start(ctx, "ThinkingBlock.iteration", trace.WithAttributes(attribute.Int("iteration", i)))
+		currentIterationAnswer, currentIterationPrompts, err := tb.runIteration(iterationCtx, templates, promptData, s)
+		endIterationSpan(iterationSpan, currentIterationAnswer, err)
+		if errors.Is(err, budget.ErrExceeded) {
+			logger.Warn("Thinking block: budget exceeded, keeping the best answer so far", "error", err)
+			// the solution of an unfinished iteration is kept, it is used only when there
+			// is no finished one
+			if currentIterationAnswer.WorkerSolution != "" {
+				currentIterationAnswer.Unfinished = true
+				blockOutput.PartAnswers = append(blockOutput.PartAnswers, currentIterationAnswer)
+				blockOutput.Prompts = append(blockOutput.Prompts, currentIterationPrompts)
 			}
+			blockOutput.StopReason = StopBudgetExceeded
+			break
+		}
+		if err != nil {
+			return ThinkingBlockOutput{}, err
 		}
 
-		// 2. Chat with worker and get solution proposal
-		currentIterationPrompts.WorkerPrompt = wP
-		solution, err := chat(ctx, tb.Worker, wP, s)
+		blockOutput.PartAnswers = append(blockOutput.PartAnswers, currentIterationAnswer)
+		blockOutput.Prompts = append(blockOutput.Prompts, currentIterationPrompts)
 
-		if err != nil {
-			return ThinkingBlockOutput{}, fmt.Errorf("error chatting with worker: %w", err)
+		if tb.OnIteration != nil {
+			err = tb.OnIteration(ctx, i, currentIterationPrompts, currentIterationAnswer)
+			if err != nil {
+				return ThinkingBlockOutput{}, fmt.Errorf("error handling iteration %d: %w", i, err)
+			}
+		}
+
+		verdict := currentIterationAnswer.OracleVerdict
+		if currentIterationAnswer.verificationFailed() {
+			logger.Debug("Thinking block: verification failed, the solution cannot be accepted")
+			continue
+		}
+		if verdict.Accept {
+			logger.Debug("Thinking block: Oracle accepted the solution")
+			blockOutput.StopReason = StopAccepted
+			break
+		}
+		if tb.AcceptScore > 0 && verdict.Score >= tb.AcceptScore {
+			logger.Debug("Thinking block: Oracle score reached the threshold", "threshold", tb.AcceptScore)
+			blockOutput.StopReason = StopAccepted
+			break
 		}
-		currentIterationAnswer.WorkerSolution = solution
-
-		// 3. Ask experts to review the proposal
-		var eP string
-		if data != "" {
-			// using provided data
-			eP = fmt.Sprintf(
-				"%s\nTASK: %s\nDATA: %s\nSOLUTION %s",
-				ePrompt,
-				taskDescription,
-				data,
-				solution,
-			)
-		} else {
-			// no data provided, just a task and a solution
-			eP = fmt.Sprintf("%s\nTASK: %s\nSOLUTION %s", ePrompt, taskDescription, solution)
+	}
+
+	if len(blockOutput.PartAnswers) == 0 {
+		if blockOutput.StopReason == StopBudgetExceeded {
+			return ThinkingBlockOutput{}, fmt.Errorf("no solution was produced: %w", budget.ErrExceeded)
 		}
+		return ThinkingBlockOutput{}, fmt.Errorf("no solution was produced in %d iterations", iterations)
+	}
 
-		currentIterationPrompts.ExpertsPrompt = eP
-		expertsAnswers := tb.ExpertsTeam.Ask(
-			ctx,
-			eP,
+	blockOutput.FinalIteration = len(blockOutput.PartAnswers) - 1
+	if blockOutput.StopReason == StopBudgetExceeded {
+		blockOutput.FinalIteration = bestIteration(blockOutput.PartAnswers)
+	}
+	blockOutput.FinalAnswer = blockOutput.PartAnswers[blockOutput.FinalIteration].WorkerSolution
+	for _, pa := range blockOutput.PartAnswers {
+		blockOutput.Usage = blockOutput.Usage.Add(pa.Usage())
+	}
+
+	return blockOutput, nil
+}
+
+func endIterationSpan(span trace.Span, answer PartialAnswer, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	} else {
+		span.SetAttributes(
+			attribute.Bool("accept", answer.OracleVerdict.Accept),
+			attribute.Float64("score", answer.OracleVerdict.Score),
+			attribute.Int("experts", len(answer.ExpertAnswers)),
 		)
+	}
+	span.End()
+}
 
-		var reviews string
-		for i, ea := range expertsAnswers {
-			if ea.Error != nil {
-				logger.Error("error chatting with expert", "error", ea.Error)
-				continue
+// runIteration asks the worker for a solution, the experts for reviews and the oracle for
+// a verdict. On error it returns what has been done so far.
+func (tb *ThinkingBlock) runIteration(
+	ctx context.Context,
+	templates promptTemplates,
+	promptData PromptData,
+	s *map[string]any,
+) (PartialAnswer, Prompts, error) {
+	logger := loggerutils.GetLogger(ctx)
+	answer := PartialAnswer{}
+	prompts := Prompts{}
+
+	// 1. Prepare worker prompt depending on whether it's a first attempt to complete a task
+	// or it is making corrections according to review
+	wTemplate := templates.worker
+	editing := false
+	if promptData.Iteration > 0 {
+		wTemplate = templates.workerRefine
+		if tb.Refinement == RefineEdits {
+			// edits need files to apply to, otherwise the worker writes all the files again
+			var previous fileutils.FileList
+			if err := json.Unmarshal([]byte(promptData.Solution), &previous); err != nil {
+				logger.Warn("Thinking block: previous solution is not valid files JSON, refining it in full", "error", err)
+			} else {
+				wTemplate = templates.workerEdit
+				s = &editsSchema
+				editing = true
 			}
-
-			reviews += fmt.Sprintf("<REVIEW %d> %s\n", i, ea.Answer)
-			currentIterationAnswer.ExpertAnswers = append(
-				currentIterationAnswer.ExpertAnswers,
-				ea.Answer,
-			)
 		}
+	}
+	wP, err := render(wTemplate, promptData)
+	if err != nil {
+		return answer, prompts, err
+	}
+
+	// 2. Chat with worker and get solution proposal
+	prompts.WorkerPrompt = wP
+	workerAnswer, err := chat(ctx, tb.Worker, wP, s)
+	if err != nil {
+		answer.WorkerUsage = assistants.UsageSoFar(workerAnswer)
+		return answer, prompts, fmt.Errorf("error chatting with worker: %w", err)
+	}
+	solution := workerAnswer.Response
+	answer.WorkerUsage = workerAnswer.Usage()
 
-		// 4. Provide those reviews to Oracle to sum up
-		var oP string
-		if data != "" {
-			// using provided data
-			oP = fmt.Sprintf(
-				"%s\nSOLUTION: %s\nDATA: %s\nREVIEWS: %s\n",
-				oPrompt,
-				solution,
-				data,
-				reviews,
-			)
-		} else {
-			// no data provided, just a solution and reviews
-			oP = fmt.Sprintf("%s\nSOLUTION: %s\nREVIEWS: %s\n", oPrompt, solution, reviews)
+	// 2a. Apply the edits to the previous solution
+	if editing {
+		answer.WorkerEdits = solution
+		solution, answer.FailedEdits, err = fileutils.ApplyEditsJson(promptData.Solution, solution)
+		if err != nil {
+			return answer, prompts, fmt.Errorf("error applying worker edits: %w", err)
 		}
-		summary, err := tb.Oracle.Chat(
-			ctx,
-			oP,
-		)
+		if len(answer.FailedEdits) > 0 {
+			logger.Warn("Thinking block: some worker edits could not be applied", "failed", len(answer.FailedEdits))
+		}
+	}
+	answer.WorkerSolution = solution
+
+	// 2b. Check the solution with real commands
+	if tb.Verifier != nil {
+		report, err := tb.Verifier.Verify(ctx, solution)
 		if err != nil {
-			return ThinkingBlockOutput{}, fmt.Errorf("error chatting with oracle %w", err)
+			return answer, prompts, fmt.Errorf("error verifying solution: %w", err)
 		}
-		currentIterationAnswer.OracleSummary = summary
-		currentIterationPrompts.OraclePrompt = oP
+		answer.Verification = &report
+		transcript.Record(ctx, transcript.Event{
+			Type: transcript.EventVerification,
+			Data: transcript.Verification{Passed: report.Passed(), Report: report},
+		})
+	}
 
-		blockOutput.PartAnswers = append(blockOutput.PartAnswers, currentIterationAnswer)
-		blockOutput.Prompts = append(blockOutput.Prompts, currentIterationPrompts)
+	// 3. Ask experts to review the proposal
+	promptData.Solution = solution
+	promptData.Summary = ""
+	promptData.Verification = answer.verificationText()
+	eP, err := render(templates.expert, promptData)
+	if err != nil {
+		return answer, prompts, err
+	}
 
-		if summary == "OK" {
-			logger.Debug("Thinking block: Oracle told OK")
-			break
+	prompts.ExpertsPrompt = eP
+	expertsAnswers := tb.ExpertsTeam.Ask(
+		ctx,
+		eP,
+	)
+
+	var reviews string
+	for i, ea := range expertsAnswers {
+		if ea.Error != nil {
+			logger.Error("error chatting with expert", "error", ea.Error)
+			transcript.Record(ctx, transcript.Event{
+				Type:      transcript.EventExpertError,
+				Role:      "expert",
+				Assistant: ea.Expert,
+				Data:      transcript.ExpertError{Error: ea.Error.Error()},
+			})
+			if ea.Usage.Calls > 0 {
+				answer.FailedExperts = append(answer.FailedExperts, ea.Expert)
+				answer.FailedExpertsUsage = append(answer.FailedExpertsUsage, ea.Usage)
+			}
+			continue
 		}
 
+		reviews += fmt.Sprintf("<REVIEW %d> %s\n", i, ea.Answer)
+		answer.ExpertNames = append(answer.ExpertNames, ea.Expert)
+		answer.ExpertAnswers = append(answer.ExpertAnswers, ea.Answer)
+		answer.ExpertsUsage = append(answer.ExpertsUsage, ea.Usage)
 	}
-	blockOutput.FinalAnswer = blockOutput.PartAnswers[len(blockOutput.PartAnswers)-1].WorkerSolution
 
-	return blockOutput, nil
+	// 4. Provide those reviews to Oracle to sum up
+	promptData.Reviews = reviews
+	oP, err := render(templates.oracle, promptData)
+	if err != nil {
+		return answer, prompts, err
+	}
+	prompts.OraclePrompt = oP
+
+	verdict, oracleUsage, err := askOracle(ctx, tb.Oracle, oP)
+	answer.OracleUsage = oracleUsage
+	if err != nil {
+		return answer, prompts, fmt.Errorf("error chatting with oracle %w", err)
+	}
+	logger.Info("Thinking block: oracle verdict",
+		"iteration", promptData.Iteration,
+		"accept", verdict.Accept,
+		"score", verdict.Score,
+		"mustFix", len(verdict.MustFix),
+	)
+	answer.OracleVerdict = verdict
+	answer.OracleSummary = verdict.Feedback()
+	transcript.Record(ctx, transcript.Event{
+		Type:      transcript.EventOracleVerdict,
+		Role:      "oracle",
+		Assistant: tb.Oracle.Name,
+		Data: transcript.OracleVerdict{
+			Accept:  verdict.Accept,
+			Score:   verdict.Score,
+			Summary: verdict.Summary,
+			MustFix: verdict.MustFix,
+		},
+	})
+
+	return answer, prompts, nil
+}
+
+// bestIteration returns the finished iteration with the highest oracle score, later ones
+// win ties and verified solutions win over the ones failing verification. Unfinished
+// iterations are used only when there is no finished one.
+func bestIteration(answers []PartialAnswer) int {
+	best := len(answers) - 1
+	found := false
+	for i, pa := range answers {
+		if pa.Unfinished {
+			continue
+		}
+		if !found || !betterAnswer(answers[best], pa) {
+			best = i
+			found = true
+		}
+	}
+	return best
+}
+
+// betterAnswer tells whether a is better than b.
+func betterAnswer(a, b PartialAnswer) bool {
+	if a.verificationFailed() != b.verificationFailed() {
+		return b.verificationFailed()
+	}
+	return a.OracleVerdict.Score > b.OracleVerdict.Score
+}
+
+func (tb *ThinkingBlock) isAccepted(answer PartialAnswer) bool {
+	return answer.Accepted(tb.AcceptScore)
+}
+
+// Accepted tells whether the iteration finishes the loop: the oracle accepted the solution
+// or scored it at least acceptScore, when it is set, and the verification did not fail.
+func (pa PartialAnswer) Accepted(acceptScore float64) bool {
+	if pa.verificationFailed() {
+		return false
+	}
+	verdict := pa.OracleVerdict
+	return verdict.Accept || (acceptScore > 0 && verdict.Score >= acceptScore)
 }
 
 var schema = map[string]any{
@@ -266,7 +545,97 @@ var schema = map[string]any{
 	"additionalProperties": false,
 }
 
-func chat(ctx context.Context, assistant assistants.Assistant, msg string, schema *map[string]any) (string, error) {
+var editsSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"edits": map[string]any{
+			"type": "array",
+			"items": map[string]any{
+				"type": "object",
+				"properties": map[string]any{
+					"fileName": map[string]any{
+						"type":        "string",
+						"description": "File name",
+					},
+					"action": map[string]any{
+						"type":        "string",
+						"enum":        []string{string(fileutils.EditModify), string(fileutils.EditWrite), string(fileutils.EditDelete)},
+						"description": "modify applies the hunks, write sets the whole fileContent, delete removes the file",
+					},
+					"fileContent": map[string]any{
+						"type":        "string",
+						"description": "Whole file content for the write action, empty otherwise",
+					},
+					"hunks": map[string]any{
+						"type": "array",
+						"items": map[string]any{
+							"type": "object",
+							"properties": map[string]any{
+								"search": map[string]any{
+									"type":        "string",
+									"description": "Exact text of the file to replace, unique in the file",
+								},
+								"replace": map[string]any{
+									"type":        "string",
+									"description": "Text replacing the search text",
+								},
+							},
+							"required":             []string{"search", "replace"},
+							"additionalProperties": false,
+						},
+						"description": "Replacements for the modify action, empty otherwise",
+					},
+				},
+				"required":             []string{"fileName", "action", "fileContent", "hunks"},
+				"additionalProperties": false,
+			},
+			"description": "Changes of the files",
+		},
+	},
+	"required":             []string{"edits"},
+	"additionalProperties": false,
+}
+
+var oracleVerdictSchema = map[string]any{
+	"type": "object",
+	"properties": map[string]any{
+		"accept": map[string]any{
+			"type":        "boolean",
+			"description": "Whether the solution is good enough to be accepted",
+		},
+		"score": map[string]any{
+			"type":        "number",
+			"description": "Solution quality from 0 to 10",
+		},
+		"summary": map[string]any{
+			"type":        "string",
+			"description": "Summary of the reviews",
+		},
+		"mustFix": map[string]any{
+			"type":        "array",
+			"items":       map[string]any{"type": "string"},
+			"description": "Issues that have to be fixed",
+		},
+	},
+	"required":             []string{"accept", "score", "summary", "mustFix"},
+	"additionalProperties": false,
+}
+
+func askOracle(ctx context.Context, oracle assistants.Assistant, msg string) (OracleVerdict, llm.Usage, error) {
+	ans, err := oracle.StructuredChat(ctx, msg, "oracle_verdict", oracleVerdictSchema)
+	if err != nil {
+		return OracleVerdict{}, assistants.UsageSoFar(ans), err
+	}
+
+	var verdict OracleVerdict
+	if err := json.Unmarshal([]byte(ans.Response), &verdict); err != nil {
+		return OracleVerdict{}, ans.Usage(), fmt.Errorf("cannot parse oracle verdict: %w", err)
+	}
+
+	return verdict, ans.Usage(), nil
+}
+
+func chat(ctx context.Context, assistant assistants.Assistant, msg string, schema *map[string]any) (llm.ChatResponse, error) {
 	if schema != nil {
 		return assistant.StructuredChat(ctx, msg, assistant.Name, *schema)
 	} else {
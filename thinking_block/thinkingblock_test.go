@@ -2,17 +2,28 @@ package thinkingblock
 
 import (
 	"context"
+	"errors"
+	"fmt"
+	"strings"
 	"testing"
 
 	"github.com/aszmajdzinski/llm-feedback-loop-executor/assistants"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/budget"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/executor"
 	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/tracing/tracingtest"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/verifier"
 )
 
 func TestThinkingBlock_Run(t *testing.T) {
 	// Mock Worker Assistant
 	mockWorker := &llm.MockLLMProvider{
 		GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
-			return llm.ChatResponse{Response: "Worker solution"}, nil
+			return llm.ChatResponse{
+				Response:   "Worker solution",
+				Model:      "worker-model",
+				TokenUsage: llm.TokenUsage{TotalTokens: 10},
+			}, nil
 		},
 	}
 
@@ -20,19 +31,18 @@ func TestThinkingBlock_Run(t *testing.T) {
 	mockExpertsTeam := assistants.MockExpertsTeam{
 		AskFunc: func(ctx context.Context, prompt string) []assistants.ExpertAnswer {
 			return []assistants.ExpertAnswer{
-				{Answer: "Expert review 1", Error: nil},
-				{Answer: "Expert review 2", Error: nil},
+				{Expert: "Expert 1", Answer: "Expert review 1", Usage: llm.Usage{Calls: 1}, Error: nil},
+				{Expert: "Expert 2", Answer: "Expert review 2", Usage: llm.Usage{Calls: 1}, Error: nil},
 			}
 		},
 	}
 
 	// Mock Oracle Assistant
-	mockOracle := &llm.MockLLMProvider{
-		GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
-			if req.BaseChatRequest.Messages[0].Content == "OK" {
-				return llm.ChatResponse{Response: "OK"}, nil
-			}
-			return llm.ChatResponse{Response: "Oracle summary"}, nil
+	mockOracle := &llm.MockStructuredLLMProvider{
+		GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+			return llm.ChatResponse{
+				Response: `{"accept": false, "score": 5, "summary": "Oracle summary", "mustFix": []}`,
+			}, nil
 		},
 	}
 
@@ -77,4 +87,537 @@ func TestThinkingBlock_Run(t *testing.T) {
 	if output.PartAnswers[0].OracleSummary != "Oracle summary" {
 		t.Errorf("expected oracle summary to be 'Oracle summary', got '%s'", output.PartAnswers[0].OracleSummary)
 	}
+
+	if len(output.PartAnswers) != 3 {
+		t.Errorf("expected all 3 iterations to run, got %d", len(output.PartAnswers))
+	}
+
+	if output.PartAnswers[0].WorkerUsage.Model != "worker-model" || len(output.PartAnswers[0].ExpertsUsage) != 2 {
+		t.Errorf("expected usage of every assistant, got %+v", output.PartAnswers[0])
+	}
+	// worker, 2 experts and oracle in 3 iterations
+	if output.Usage.Calls != 12 || output.Usage.TokenUsage.TotalTokens != 30 {
+		t.Errorf("expected 12 calls and 30 tokens in block usage, got %+v", output.Usage)
+	}
+}
+
+func TestThinkingBlock_RunStopsOnOracleVerdict(t *testing.T) {
+	tests := []struct {
+		name               string
+		verdicts           []string
+		acceptScore        float64
+		expectedIterations int
+	}{
+		{
+			name: "accepted",
+			verdicts: []string{
+				`{"accept": false, "score": 4, "summary": "Needs work", "mustFix": ["add tests"]}`,
+				`{"accept": true, "score": 6, "summary": "Good", "mustFix": []}`,
+			},
+			expectedIterations: 2,
+		},
+		{
+			name: "score threshold reached",
+			verdicts: []string{
+				`{"accept": false, "score": 8.5, "summary": "Almost", "mustFix": ["typo"]}`,
+			},
+			acceptScore:        8,
+			expectedIterations: 1,
+		},
+		{
+			name: "threshold disabled",
+			verdicts: []string{
+				`{"accept": false, "score": 9, "summary": "Almost", "mustFix": ["typo"]}`,
+				`{"accept": false, "score": 9, "summary": "Almost", "mustFix": ["typo"]}`,
+				`{"accept": false, "score": 9, "summary": "Almost", "mustFix": ["typo"]}`,
+			},
+			expectedIterations: 3,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			call := 0
+			mockOracle := &llm.MockStructuredLLMProvider{
+				GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+					verdict := tt.verdicts[call]
+					call++
+					return llm.ChatResponse{Response: verdict}, nil
+				},
+			}
+
+			tb := ThinkingBlock{
+				Worker: assistants.Assistant{
+					Llm: &llm.MockLLMProvider{
+						GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+							return llm.ChatResponse{Response: "Worker solution"}, nil
+						},
+					},
+				},
+				ExpertsTeam: assistants.MockExpertsTeam{
+					AskFunc: func(ctx context.Context, prompt string) []assistants.ExpertAnswer {
+						return []assistants.ExpertAnswer{{Answer: "Expert review"}}
+					},
+				},
+				Oracle:      assistants.Assistant{Llm: mockOracle},
+				AcceptScore: tt.acceptScore,
+			}
+
+			output, err := tb.Run(context.Background(), "Test task", "", false, 3)
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if len(output.PartAnswers) != tt.expectedIterations {
+				t.Errorf("expected %d iterations, got %d", tt.expectedIterations, len(output.PartAnswers))
+			}
+		})
+	}
+}
+
+func TestThinkingBlock_RunUsesTemplates(t *testing.T) {
+	var workerPrompts []string
+	tb := ThinkingBlock{
+		Worker: assistants.Assistant{
+			Llm: &llm.MockLLMProvider{
+				GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+					workerPrompts = append(workerPrompts, req.Messages[1].Content)
+					return llm.ChatResponse{Response: "Worker solution"}, nil
+				},
+			},
+		},
+		ExpertsTeam: assistants.MockExpertsTeam{
+			AskFunc: func(ctx context.Context, prompt string) []assistants.ExpertAnswer {
+				return []assistants.ExpertAnswer{{Answer: "Expert review"}}
+			},
+		},
+		Oracle: assistants.Assistant{
+			Llm: &llm.MockStructuredLLMProvider{
+				GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+					return llm.ChatResponse{
+						Response: `{"accept": false, "score": 5, "summary": "Fix it", "mustFix": []}`,
+					}, nil
+				},
+			},
+		},
+		Templates: Templates{
+			WorkerRefine: "#{{.Iteration}} {{.Task}} | {{.Solution}} | {{.Summary}}",
+		},
+	}
+
+	_, err := tb.Run(context.Background(), "Test task", "", false, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(workerPrompts) != 2 {
+		t.Fatalf("expected 2 worker prompts, got %d", len(workerPrompts))
+	}
+	if workerPrompts[0] != "You will be given a TASK. Your job is to provide a solution to the TASK. "+
+		"Ensure that your solution is as accurate and complete as possible.\nTASK: Test task\n" {
+		t.Errorf("expected default worker template in first iteration, got '%s'", workerPrompts[0])
+	}
+	if workerPrompts[1] != "#1 Test task | Worker solution | Fix it" {
+		t.Errorf("expected custom refine template, got '%s'", workerPrompts[1])
+	}
+}
+
+func TestThinkingBlock_RunInvalidTemplate(t *testing.T) {
+	tb := ThinkingBlock{Templates: Templates{Expert: "{{.Task"}}
+
+	_, err := tb.Run(context.Background(), "Test task", "", false, 1)
+	if err == nil {
+		t.Fatalf("expected template error")
+	}
+}
+
+func TestThinkingBlock_RunResumesFromPreviousIterations(t *testing.T) {
+	var workerPrompts []string
+	var savedIterations []int
+	tb := ThinkingBlock{
+		Worker: assistants.Assistant{
+			Llm: &llm.MockLLMProvider{
+				GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+					workerPrompts = append(workerPrompts, req.Messages[1].Content)
+					return llm.ChatResponse{Response: "Refined solution"}, nil
+				},
+			},
+		},
+		ExpertsTeam: assistants.MockExpertsTeam{
+			AskFunc: func(ctx context.Context, prompt string) []assistants.ExpertAnswer {
+				return []assistants.ExpertAnswer{{Answer: "Expert review"}}
+			},
+		},
+		Oracle: assistants.Assistant{
+			Llm: &llm.MockStructuredLLMProvider{
+				GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+					return llm.ChatResponse{
+						Response: `{"accept": false, "score": 5, "summary": "Fix it", "mustFix": []}`,
+					}, nil
+				},
+			},
+		},
+		Templates: Templates{WorkerRefine: "{{.Iteration}} {{.Solution}}"},
+		Previous: ThinkingBlockOutput{
+			Prompts:     []Prompts{{WorkerPrompt: "first prompt"}},
+			PartAnswers: []PartialAnswer{{WorkerSolution: "First solution"}},
+		},
+		OnIteration: func(ctx context.Context, iteration int, prompts Prompts, answer PartialAnswer) error {
+			savedIterations = append(savedIterations, iteration)
+			return nil
+		},
+	}
+
+	output, err := tb.Run(context.Background(), "Test task", "", false, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(output.PartAnswers) != 3 || output.PartAnswers[0].WorkerSolution != "First solution" {
+		t.Fatalf("expected previous iteration to be kept, got %+v", output.PartAnswers)
+	}
+	if len(workerPrompts) != 2 || workerPrompts[0] != "1 First solution" {
+		t.Errorf("expected loop to continue from the second iteration, got %v", workerPrompts)
+	}
+	if len(savedIterations) != 2 || savedIterations[0] != 1 || savedIterations[1] != 2 {
+		t.Errorf("expected iterations 1 and 2 to be reported, got %v", savedIterations)
+	}
+}
+
+func TestThinkingBlock_RunStopsWhenBudgetExceeded(t *testing.T) {
+	tests := []struct {
+		name string
+		// workerCalls is the number of worker calls allowed before the budget is exceeded
+		workerCalls        int
+		verdicts           []string
+		expectedAnswer     string
+		expectedIterations int
+		expectErr          bool
+	}{
+		{
+			name:        "best scored iteration is kept",
+			workerCalls: 2,
+			verdicts: []string{
+				`{"accept": false, "score": 7, "summary": "Good", "mustFix": ["typo"]}`,
+				`{"accept": false, "score": 3, "summary": "Worse", "mustFix": ["regression"]}`,
+			},
+			expectedAnswer:     "solution 1",
+			expectedIterations: 2,
+		},
+		{
+			name:               "unfinished iteration is kept when nothing else is available",
+			workerCalls:        1,
+			expectedAnswer:     "solution 1",
+			expectedIterations: 1,
+		},
+		{
+			name:      "nothing produced",
+			expectErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			workerCall := 0
+			oracleCall := 0
+			tb := ThinkingBlock{
+				Worker: assistants.Assistant{
+					Llm: &llm.MockLLMProvider{
+						GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+							if workerCall >= tt.workerCalls {
+								return llm.ChatResponse{}, budget.ErrExceeded
+							}
+							workerCall++
+							return llm.ChatResponse{Response: fmt.Sprintf("solution %d", workerCall)}, nil
+						},
+					},
+				},
+				ExpertsTeam: assistants.MockExpertsTeam{
+					AskFunc: func(ctx context.Context, prompt string) []assistants.ExpertAnswer {
+						return []assistants.ExpertAnswer{{Answer: "Expert review"}}
+					},
+				},
+				Oracle: assistants.Assistant{Llm: &llm.MockStructuredLLMProvider{
+					GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+						if oracleCall >= len(tt.verdicts) {
+							return llm.ChatResponse{}, budget.ErrExceeded
+						}
+						oracleCall++
+						return llm.ChatResponse{Response: tt.verdicts[oracleCall-1]}, nil
+					},
+				}},
+			}
+
+			output, err := tb.Run(context.Background(), "Test task", "", false, 5)
+			if tt.expectErr {
+				if !errors.Is(err, budget.ErrExceeded) {
+					t.Fatalf("expected budget error, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("unexpected error: %v", err)
+			}
+
+			if output.StopReason != StopBudgetExceeded {
+				t.Errorf("expected stop reason %q, got %q", StopBudgetExceeded, output.StopReason)
+			}
+			if output.FinalAnswer != tt.expectedAnswer {
+				t.Errorf("expected final answer %q, got %q", tt.expectedAnswer, output.FinalAnswer)
+			}
+			if len(output.PartAnswers) != tt.expectedIterations {
+				t.Errorf("expected %d iterations, got %d", tt.expectedIterations, len(output.PartAnswers))
+			}
+		})
+	}
+}
+
+type mockVerifier struct {
+	reports []verifier.Report
+	calls   int
+}
+
+func (m *mockVerifier) Verify(ctx context.Context, solution string) (verifier.Report, error) {
+	report := m.reports[m.calls]
+	m.calls++
+	return report, nil
+}
+
+func TestThinkingBlock_RunVerifiesSolutions(t *testing.T) {
+	var workerPrompts []string
+	tb := ThinkingBlock{
+		Worker: assistants.Assistant{
+			Llm: &llm.MockLLMProvider{
+				GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+					workerPrompts = append(workerPrompts, req.Messages[1].Content)
+					return llm.ChatResponse{Response: "Worker solution"}, nil
+				},
+			},
+		},
+		ExpertsTeam: assistants.MockExpertsTeam{
+			AskFunc: func(ctx context.Context, prompt string) []assistants.ExpertAnswer {
+				return []assistants.ExpertAnswer{{Answer: "Looks great"}}
+			},
+		},
+		// the oracle accepts everything, only the verifier keeps the loop going
+		Oracle: assistants.Assistant{Llm: &llm.MockStructuredLLMProvider{
+			GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+				if !strings.Contains(req.Messages[1].Content, "VERIFICATION") {
+					t.Errorf("oracle prompt without verification: %s", req.Messages[1].Content)
+				}
+				return llm.ChatResponse{Response: `{"accept": true, "score": 9, "summary": "Good", "mustFix": []}`}, nil
+			},
+		}},
+		Verifier: &mockVerifier{reports: []verifier.Report{
+			{Results: []executor.Result{{Command: "go build ./...", ExitCode: 1, Stderr: "undefined: foo"}}},
+			{Results: []executor.Result{{Command: "go build ./..."}}},
+		}},
+	}
+
+	output, err := tb.Run(context.Background(), "Test task", "", false, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(output.PartAnswers) != 2 {
+		t.Fatalf("expected 2 iterations, got %d", len(output.PartAnswers))
+	}
+	if output.StopReason != StopAccepted {
+		t.Errorf("expected stop reason %q, got %q", StopAccepted, output.StopReason)
+	}
+	if output.PartAnswers[0].Verification == nil || output.PartAnswers[0].Verification.Passed() {
+		t.Errorf("expected failed verification in the first iteration")
+	}
+	if !strings.Contains(workerPrompts[1], "undefined: foo") {
+		t.Errorf("worker refine prompt without verification output: %s", workerPrompts[1])
+	}
+}
+
+func TestThinkingBlock_RunRefinesWithEdits(t *testing.T) {
+	responses := []string{
+		`{"files": [{"fileName": "main.go", "fileContent": "package main\n\nfunc main() {}\n"}, {"fileName": "old.go", "fileContent": "old"}]}`,
+		`{"edits": [
+			{"fileName": "main.go", "action": "modify", "fileContent": "", "hunks": [
+				{"search": "func main() {}", "replace": "func main() { run() }"},
+				{"search": "missing", "replace": "x"}
+			]},
+			{"fileName": "run.go", "action": "write", "fileContent": "package main\n\nfunc run() {}\n", "hunks": []},
+			{"fileName": "old.go", "action": "delete", "fileContent": "", "hunks": []}
+		]}`,
+		`{"edits": []}`,
+	}
+	var requests []llm.StructuredChatRequest
+	tb := ThinkingBlock{
+		Worker: assistants.Assistant{
+			Name: "worker",
+			Llm: &llm.MockStructuredLLMProvider{
+				GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+					requests = append(requests, req)
+					return llm.ChatResponse{Response: responses[len(requests)-1]}, nil
+				},
+			},
+		},
+		ExpertsTeam: assistants.MockExpertsTeam{
+			AskFunc: func(ctx context.Context, prompt string) []assistants.ExpertAnswer {
+				return []assistants.ExpertAnswer{{Answer: "Review"}}
+			},
+		},
+		Oracle: assistants.Assistant{Llm: &llm.MockStructuredLLMProvider{
+			GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+				return llm.ChatResponse{Response: `{"accept": false, "score": 5, "summary": "Summary", "mustFix": []}`}, nil
+			},
+		}},
+		Refinement: RefineEdits,
+	}
+
+	output, err := tb.Run(context.Background(), "Test task", "", true, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if requests[0].Schema.(map[string]any)["required"].([]string)[0] != "files" {
+		t.Errorf("expected the files schema in the first iteration")
+	}
+	if requests[1].Schema.(map[string]any)["required"].([]string)[0] != "edits" {
+		t.Errorf("expected the edits schema in the next iterations")
+	}
+
+	expected := `{"files":[{"fileName":"main.go","fileContent":"package main\n\nfunc main() { run() }\n"},` +
+		`{"fileName":"run.go","fileContent":"package main\n\nfunc run() {}\n"}]}`
+	if output.PartAnswers[1].WorkerSolution != expected {
+		t.Errorf("expected edits applied to the previous solution, got %s", output.PartAnswers[1].WorkerSolution)
+	}
+	if output.PartAnswers[1].WorkerEdits != responses[1] {
+		t.Errorf("expected the worker edits to be kept")
+	}
+	if failed := output.PartAnswers[1].FailedEdits; len(failed) != 1 || failed[0].Hunk != 1 {
+		t.Errorf("expected the second hunk to fail, got %+v", failed)
+	}
+	if !strings.Contains(requests[2].Messages[1].Content, "main.go, hunk 1: search text not found") {
+		t.Errorf("failed edits not reported to the worker: %s", requests[2].Messages[1].Content)
+	}
+	if output.FinalAnswer != expected {
+		t.Errorf("expected empty edits to keep the solution, got %s", output.FinalAnswer)
+	}
+}
+
+func TestThinkingBlock_RunEditsAfterInvalidFiles(t *testing.T) {
+	responses := []string{
+		`{"files": [{"fileName": "main.go"`,
+		`{"files": [{"fileName": "main.go", "fileContent": "package main\n"}]}`,
+		`{"edits": []}`,
+	}
+	var requests []llm.StructuredChatRequest
+	tb := ThinkingBlock{
+		Worker: assistants.Assistant{
+			Name: "worker",
+			Llm: &llm.MockStructuredLLMProvider{
+				GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+					requests = append(requests, req)
+					return llm.ChatResponse{Response: responses[len(requests)-1]}, nil
+				},
+			},
+		},
+		ExpertsTeam: assistants.MockExpertsTeam{},
+		Oracle: assistants.Assistant{Llm: &llm.MockStructuredLLMProvider{
+			GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+				return llm.ChatResponse{Response: `{"accept": false, "score": 5, "summary": "Summary", "mustFix": []}`}, nil
+			},
+		}},
+		Refinement: RefineEdits,
+	}
+
+	output, err := tb.Run(context.Background(), "Test task", "", true, 3)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if requests[1].Schema.(map[string]any)["required"].([]string)[0] != "files" {
+		t.Errorf("expected the files schema after an invalid solution")
+	}
+	if requests[2].Schema.(map[string]any)["required"].([]string)[0] != "edits" {
+		t.Errorf("expected the edits schema after a valid solution")
+	}
+	if output.PartAnswers[1].WorkerEdits != "" || output.PartAnswers[1].WorkerSolution != responses[1] {
+		t.Errorf("expected the full solution in the second iteration, got %+v", output.PartAnswers[1])
+	}
+}
+
+func TestThinkingBlock_RunEditsNeedFilesOutput(t *testing.T) {
+	tb := ThinkingBlock{Refinement: RefineEdits}
+	if _, err := tb.Run(context.Background(), "Test task", "", false, 1); err == nil {
+		t.Errorf("expected an error for edits refinement without files output")
+	}
+}
+
+func TestThinkingBlock_RunSpans(t *testing.T) {
+	exporter := tracingtest.InMemory()
+	exporter.Reset()
+
+	tb := ThinkingBlock{
+		Worker: assistants.Assistant{Llm: &llm.MockLLMProvider{
+			GetCompletionFunc: func(ctx context.Context, req llm.ChatRequest) (llm.ChatResponse, error) {
+				return llm.ChatResponse{Response: "solution"}, nil
+			},
+		}},
+		ExpertsTeam: assistants.MockExpertsTeam{},
+		Oracle: assistants.Assistant{Llm: &llm.MockStructuredLLMProvider{
+			GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+				return llm.ChatResponse{Response: `{"accept": false, "score": 8, "summary": "good enough", "mustFix": []}`}, nil
+			},
+		}},
+		AcceptScore: 8,
+	}
+	if _, err := tb.Run(context.Background(), "task", "", false, 3); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	spans := exporter.GetSpans()
+	if len(spans) != 2 || spans[0].Name != "ThinkingBlock.iteration" || spans[1].Name != "ThinkingBlock.Run" {
+		t.Fatalf("expected an iteration span and the run span, got %v", spans.Snapshots())
+	}
+	if spans[0].Parent.SpanID() != spans[1].SpanContext.SpanID() {
+		t.Errorf("expected the iteration span under the run span")
+	}
+
+	attrs := map[string]string{}
+	for _, s := range spans {
+		for _, a := range s.Attributes {
+			attrs[s.Name+" "+string(a.Key)] = a.Value.Emit()
+		}
+	}
+	for key, value := range map[string]string{
+		"ThinkingBlock.iteration iteration": "0",
+		"ThinkingBlock.iteration score":     "8",
+		"ThinkingBlock.Run maxIterations":   "3",
+		"ThinkingBlock.Run iterations":      "1",
+		"ThinkingBlock.Run stopReason":      string(StopAccepted),
+	} {
+		if attrs[key] != value {
+			t.Errorf("expected %s = %s, got %q", key, value, attrs[key])
+		}
+	}
+}
+
+func TestPartialAnswerAccepted(t *testing.T) {
+	failed := &verifier.Report{Results: []executor.Result{{Command: "go build ./...", ExitCode: 1}}}
+	tests := []struct {
+		name        string
+		answer      PartialAnswer
+		acceptScore float64
+		accepted    bool
+	}{
+		{"accepted by the oracle", PartialAnswer{OracleVerdict: OracleVerdict{Accept: true, Score: 5}}, 0, true},
+		{"score reaches acceptScore", PartialAnswer{OracleVerdict: OracleVerdict{Score: 8}}, 8, true},
+		{"score below acceptScore", PartialAnswer{OracleVerdict: OracleVerdict{Score: 7}}, 8, false},
+		{"score without acceptScore", PartialAnswer{OracleVerdict: OracleVerdict{Score: 10}}, 0, false},
+		{"failed verification", PartialAnswer{OracleVerdict: OracleVerdict{Accept: true, Score: 9}, Verification: failed}, 8, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := tt.answer.Accepted(tt.acceptScore); got != tt.accepted {
+				t.Errorf("expected accepted=%v, got %v", tt.accepted, got)
+			}
+		})
+	}
 }
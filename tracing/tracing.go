@@ -0,0 +1,85 @@
+// Package tracing sets up the OpenTelemetry tracer provider the spans of a run are
+// exported with. Without a setup the spans go to the no-op provider of otel.
+package tracing
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/exporters/stdout/stdouttrace"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.34.0"
+)
+
+const serviceName = "llm-feedback-loop-executor"
+
+// Setup tells where the spans are exported to, both exporters can be used at once.
+type Setup struct {
+	// OTLP exports the spans over OTLP/HTTP, the endpoint and headers are configured with
+	// the standard OTEL_EXPORTER_OTLP_* environment variables.
+	OTLP bool
+	// File writes the spans as JSON, one per line.
+	File string
+}
+
+// Start sets the global tracer provider for the setup. The returned shutdown flushes the
+// spans not exported yet, it has to be called before the program exits.
+func Start(ctx context.Context, setup Setup) (shutdown func(context.Context) error, err error) {
+	var opts []sdktrace.TracerProviderOption
+	var closers []func(context.Context) error
+
+	if setup.File != "" {
+		f, err := os.Create(setup.File)
+		if err != nil {
+			return nil, fmt.Errorf("error creating trace file: %w", err)
+		}
+		exporter, err := stdouttrace.New(stdouttrace.WithWriter(f))
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("error creating trace file exporter: %w", err)
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+		closers = append(closers, func(context.Context) error { return f.Close() })
+	}
+
+	// closeAll closes what was created when the setup fails part way
+	closeAll := func(err error) error {
+		for _, c := range closers {
+			err = errors.Join(err, c(ctx))
+		}
+		return err
+	}
+
+	if setup.OTLP {
+		exporter, err := otlptracehttp.New(ctx)
+		if err != nil {
+			return nil, closeAll(fmt.Errorf("error creating OTLP exporter: %w", err))
+		}
+		opts = append(opts, sdktrace.WithBatcher(exporter))
+	}
+
+	if len(opts) == 0 {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	res, err := resource.Merge(resource.Default(), resource.NewSchemaless(semconv.ServiceName(serviceName)))
+	if err != nil {
+		return nil, closeAll(fmt.Errorf("error creating trace resource: %w", err))
+	}
+	provider := sdktrace.NewTracerProvider(append(opts, sdktrace.WithResource(res))...)
+	otel.SetTracerProvider(provider)
+
+	return func(ctx context.Context) error {
+		// the provider flushes the exporters, the file is closed after them
+		err := provider.Shutdown(ctx)
+		for _, c := range closers {
+			err = errors.Join(err, c(ctx))
+		}
+		return err
+	}, nil
+}
@@ -0,0 +1,60 @@
+package tracing
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+)
+
+func TestStartFile(t *testing.T) {
+	fileName := filepath.Join(t.TempDir(), "trace.jsonl")
+	shutdown, err := Start(context.Background(), Setup{File: fileName})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	_, span := otel.Tracer("test").Start(context.Background(), "RunApp")
+	span.End()
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, err := os.ReadFile(fileName)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	var exported struct {
+		Name     string
+		Resource []struct {
+			Key   string
+			Value struct{ Value any }
+		}
+	}
+	if err := json.Unmarshal(data, &exported); err != nil {
+		t.Fatalf("expected a span as JSON, got %s: %v", data, err)
+	}
+	if exported.Name != "RunApp" {
+		t.Errorf("unexpected span %s", data)
+	}
+	found := false
+	for _, r := range exported.Resource {
+		found = found || (r.Key == "service.name" && r.Value.Value == serviceName)
+	}
+	if !found {
+		t.Errorf("expected the service name in the resource, got %s", data)
+	}
+}
+
+func TestStartWithoutExporters(t *testing.T) {
+	shutdown, err := Start(context.Background(), Setup{})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if err := shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
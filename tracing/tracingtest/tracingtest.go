@@ -0,0 +1,24 @@
+// Package tracingtest keeps the spans of tests in memory, so the tests can check the spans
+// of the code they run.
+package tracingtest
+
+import (
+	"sync"
+
+	"go.opentelemetry.io/otel"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	"go.opentelemetry.io/otel/sdk/trace/tracetest"
+)
+
+var inMemory = sync.OnceValue(func() *tracetest.InMemoryExporter {
+	exporter := tracetest.NewInMemoryExporter()
+	otel.SetTracerProvider(sdktrace.NewTracerProvider(sdktrace.WithSyncer(exporter)))
+	return exporter
+})
+
+// InMemory sets a global tracer provider keeping the ended spans in memory. The tracers
+// of otel delegate only to the first global provider that is set, so all calls return
+// the same exporter; tests reset it before running.
+func InMemory() *tracetest.InMemoryExporter {
+	return inMemory()
+}
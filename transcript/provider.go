@@ -0,0 +1,54 @@
+package transcript
+
+import (
+	"context"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+)
+
+// Wrap returns a provider recording every call as an llm_request and an llm_response
+// event of the assistant, see llm.Intercept.
+func Wrap(provider llm.LLMProvider, role string, assistant string) llm.LLMProvider {
+	return llm.Intercept(provider, func(ctx context.Context, call llm.Call, send func() (llm.ChatResponse, error)) (llm.ChatResponse, error) {
+		r := FromContext(ctx)
+		if r == nil {
+			return send()
+		}
+
+		request := LLMRequest{Model: call.Request.Model, Schema: call.Schema}
+		for _, m := range call.Request.Messages {
+			request.Messages = append(request.Messages, Message{
+				Role:       m.Role,
+				Content:    m.Content,
+				ToolCalls:  toolCalls(m.ToolCalls),
+				ToolCallID: m.ToolCallID,
+			})
+		}
+		for _, t := range call.Request.Tools {
+			request.Tools = append(request.Tools, t.Name)
+		}
+		r.record(ctx, Event{Type: EventLLMRequest, Role: role, Assistant: assistant, Data: request})
+
+		resp, err := send()
+
+		response := LLMResponse{
+			Response:  resp.Response,
+			ToolCalls: toolCalls(resp.ToolCalls),
+			Usage:     r.Usage(resp.Usage()),
+		}
+		if err != nil {
+			response.Error = err.Error()
+		}
+		r.record(ctx, Event{Type: EventLLMResponse, Role: role, Assistant: assistant, Data: response})
+
+		return resp, err
+	})
+}
+
+func toolCalls(calls []llm.ToolCall) []ToolCall {
+	var res []ToolCall
+	for _, c := range calls {
+		res = append(res, ToolCall{ID: c.ID, Name: c.Name, Arguments: c.Arguments})
+	}
+	return res
+}
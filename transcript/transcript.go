@@ -0,0 +1,247 @@
+// Package transcript writes a run as a stream of JSON events, one per line (run.jsonl).
+// The schema of the events is documented in the README.
+package transcript
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+)
+
+// FileName is the name of the transcript in the output directory of a run.
+const FileName = "run.jsonl"
+
+// EventType tells what happened, it decides the type of Event.Data.
+type EventType string
+
+const (
+	EventRunStarted    EventType = "run_started"
+	EventRunFinished   EventType = "run_finished"
+	EventBlockStarted  EventType = "block_started"
+	EventBlockFinished EventType = "block_finished"
+	EventLLMRequest    EventType = "llm_request"
+	EventLLMResponse   EventType = "llm_response"
+	EventExpertError   EventType = "expert_error"
+	EventVerification  EventType = "verification"
+	EventOracleVerdict EventType = "oracle_verdict"
+)
+
+// Event is a single line of the transcript. Block and Iteration come from the context
+// the event is recorded with, Role and Assistant are set for the events of an assistant.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Type      EventType `json:"type"`
+	Block     string    `json:"block,omitempty"`
+	Iteration *int      `json:"iteration,omitempty"`
+	Role      string    `json:"role,omitempty"`
+	Assistant string    `json:"assistant,omitempty"`
+	Data      any       `json:"data,omitempty"`
+}
+
+type RunStarted struct {
+	Blocks []string `json:"blocks"`
+	Resume bool     `json:"resume"`
+}
+
+type RunFinished struct {
+	Error string `json:"error,omitempty"`
+}
+
+type BlockStarted struct {
+	Inputs []string `json:"inputs"`
+}
+
+type BlockFinished struct {
+	StopReason     string `json:"stopReason,omitempty"`
+	FinalIteration int    `json:"finalIteration"`
+	Iterations     int    `json:"iterations"`
+	Usage          Usage  `json:"usage"`
+	Error          string `json:"error,omitempty"`
+}
+
+type LLMRequest struct {
+	Model    string    `json:"model,omitempty"`
+	Messages []Message `json:"messages"`
+	Tools    []string  `json:"tools,omitempty"`
+	// Schema is the name of the requested structured output, empty for text.
+	Schema string `json:"schema,omitempty"`
+}
+
+type LLMResponse struct {
+	Response  string     `json:"response"`
+	ToolCalls []ToolCall `json:"toolCalls,omitempty"`
+	Usage     Usage      `json:"usage"`
+	Error     string     `json:"error,omitempty"`
+}
+
+type ExpertError struct {
+	Error string `json:"error"`
+}
+
+type Verification struct {
+	Passed bool `json:"passed"`
+	Report any  `json:"report"`
+}
+
+type OracleVerdict struct {
+	Accept  bool     `json:"accept"`
+	Score   float64  `json:"score"`
+	Summary string   `json:"summary"`
+	MustFix []string `json:"mustFix"`
+}
+
+type Message struct {
+	Role       string     `json:"role"`
+	Content    string     `json:"content"`
+	ToolCalls  []ToolCall `json:"toolCalls,omitempty"`
+	ToolCallID string     `json:"toolCallId,omitempty"`
+}
+
+type ToolCall struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Arguments string `json:"arguments"`
+}
+
+// Usage of LLM calls, CostUSD is nil for models without a known price.
+type Usage struct {
+	Model        string   `json:"model,omitempty"`
+	Calls        int      `json:"calls"`
+	InputTokens  int      `json:"inputTokens"`
+	OutputTokens int      `json:"outputTokens"`
+	TotalTokens  int      `json:"totalTokens"`
+	LatencyMs    int64    `json:"latencyMs"`
+	CostUSD      *float64 `json:"costUSD,omitempty"`
+}
+
+// Recorder writes events to a transcript, it is safe for concurrent use. A nil Recorder
+// discards the events.
+type Recorder struct {
+	mu      sync.Mutex
+	w       io.Writer
+	closer  io.Closer
+	pricing usage.Pricing
+	now     func() time.Time
+}
+
+// New returns a recorder writing to w, the pricing is used for the costs of LLM calls.
+func New(w io.Writer, pricing usage.Pricing) *Recorder {
+	return &Recorder{w: w, pricing: pricing, now: time.Now}
+}
+
+// Create returns a recorder writing to the file, appended to when resuming a run.
+func Create(fileName string, appendTo bool, pricing usage.Pricing) (*Recorder, error) {
+	flags := os.O_CREATE | os.O_WRONLY | os.O_TRUNC
+	if appendTo {
+		flags = os.O_CREATE | os.O_WRONLY | os.O_APPEND
+	}
+	f, err := os.OpenFile(fileName, flags, 0o644)
+	if err != nil {
+		return nil, err
+	}
+
+	r := New(f, pricing)
+	r.closer = f
+	return r, nil
+}
+
+func (r *Recorder) Close() error {
+	if r == nil || r.closer == nil {
+		return nil
+	}
+	return r.closer.Close()
+}
+
+// Usage converts the usage of LLM calls, with the cost when the model has a price.
+func (r *Recorder) Usage(u llm.Usage) Usage {
+	res := Usage{
+		Model:        u.Model,
+		Calls:        u.Calls,
+		InputTokens:  u.TokenUsage.InputTokens,
+		OutputTokens: u.TokenUsage.OutputTokens,
+		TotalTokens:  u.TokenUsage.TotalTokens,
+		LatencyMs:    u.TimeTaken.Milliseconds(),
+	}
+	if r != nil {
+		if cost, ok := r.pricing.Cost(u.Model, u.TokenUsage); ok {
+			res.CostUSD = &cost
+		}
+	}
+	return res
+}
+
+func (r *Recorder) record(ctx context.Context, e Event) {
+	if r == nil {
+		return
+	}
+
+	fields := fieldsFromContext(ctx)
+	e.Time = r.now().UTC()
+	if e.Block == "" {
+		e.Block = fields.block
+	}
+	if e.Iteration == nil && fields.iteration != nil {
+		iteration := *fields.iteration
+		e.Iteration = &iteration
+	}
+
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	// a failed write must not stop the run, the transcript is best effort
+	_, _ = r.w.Write(append(line, '\n'))
+}
+
+type recorderKey struct{}
+
+type fieldsKey struct{}
+
+type fields struct {
+	block     string
+	iteration *int
+}
+
+func WithRecorder(ctx context.Context, r *Recorder) context.Context {
+	return context.WithValue(ctx, recorderKey{}, r)
+}
+
+// FromContext returns the recorder of the context, nil when there is none.
+func FromContext(ctx context.Context) *Recorder {
+	r, _ := ctx.Value(recorderKey{}).(*Recorder)
+	return r
+}
+
+// Record writes the event to the recorder of the context, if any.
+func Record(ctx context.Context, e Event) {
+	FromContext(ctx).record(ctx, e)
+}
+
+// WithBlock sets the block of the events recorded with the context.
+func WithBlock(ctx context.Context, block string) context.Context {
+	f := fieldsFromContext(ctx)
+	f.block = block
+	f.iteration = nil
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+// WithIteration sets the iteration of the events recorded with the context.
+func WithIteration(ctx context.Context, iteration int) context.Context {
+	f := fieldsFromContext(ctx)
+	f.iteration = &iteration
+	return context.WithValue(ctx, fieldsKey{}, f)
+}
+
+func fieldsFromContext(ctx context.Context) fields {
+	f, _ := ctx.Value(fieldsKey{}).(fields)
+	return f
+}
@@ -0,0 +1,120 @@
+package transcript
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/usage"
+)
+
+func newTestRecorder() (*Recorder, *bytes.Buffer) {
+	var buf bytes.Buffer
+	r := New(&buf, usage.Pricing{"test-model": {Input: 1, Output: 2}})
+	r.now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+	return r, &buf
+}
+
+func TestRecord(t *testing.T) {
+	r, buf := newTestRecorder()
+	ctx := WithRecorder(context.Background(), r)
+
+	Record(ctx, Event{Type: EventRunStarted, Data: RunStarted{Blocks: []string{"a"}}})
+	blockCtx := WithBlock(ctx, "a")
+	Record(WithIteration(blockCtx, 2), Event{Type: EventOracleVerdict, Role: "oracle", Data: OracleVerdict{Accept: true, Score: 9}})
+	Record(blockCtx, Event{Type: EventBlockFinished, Data: BlockFinished{StopReason: "accepted", FinalIteration: 2, Iterations: 3}})
+
+	expected := `{"time":"2025-01-02T03:04:05Z","type":"run_started","data":{"blocks":["a"],"resume":false}}
+{"time":"2025-01-02T03:04:05Z","type":"oracle_verdict","block":"a","iteration":2,"role":"oracle","data":{"accept":true,"score":9,"summary":"","mustFix":null}}
+{"time":"2025-01-02T03:04:05Z","type":"block_finished","block":"a","data":{"stopReason":"accepted","finalIteration":2,"iterations":3,"usage":{"calls":0,"inputTokens":0,"outputTokens":0,"totalTokens":0,"latencyMs":0}}}
+`
+	if buf.String() != expected {
+		t.Errorf("unexpected transcript:\n%s\nexpected:\n%s", buf, expected)
+	}
+
+	// without a recorder events are dropped
+	Record(context.Background(), Event{Type: EventRunFinished})
+}
+
+func TestWrap(t *testing.T) {
+	r, buf := newTestRecorder()
+	ctx := WithIteration(WithBlock(WithRecorder(context.Background(), r), "docs"), 0)
+
+	calls := 0
+	provider := Wrap(&llm.MockStructuredLLMProvider{
+		GetResponseFunc: func(ctx context.Context, req llm.StructuredChatRequest) (llm.ChatResponse, error) {
+			calls++
+			if calls > 1 {
+				return llm.ChatResponse{}, errors.New("rate limited")
+			}
+			return llm.ChatResponse{
+				Response:   `{"files": []}`,
+				Model:      "test-model",
+				TokenUsage: llm.TokenUsage{InputTokens: 1_000_000, OutputTokens: 500_000, TotalTokens: 1_500_000},
+				TimeTaken:  1500 * time.Millisecond,
+			}, nil
+		},
+	}, "worker", "coder")
+
+	structured, ok := provider.(llm.StreamingStructuredLLMProvider)
+	if !ok {
+		t.Fatalf("expected structured streaming support to be kept")
+	}
+	req := llm.StructuredChatRequest{
+		BaseChatRequest: llm.BaseChatRequest{Messages: []llm.ChatMessage{{Role: "user", Content: "write code"}}},
+		Name:            "files",
+	}
+	if _, err := structured.GetResponse(ctx, req); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if _, err := structured.StreamResponse(ctx, req, func(string) {}); err == nil {
+		t.Fatalf("expected an error")
+	}
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("expected 4 events, got:\n%s", buf)
+	}
+
+	var request struct {
+		Type      EventType
+		Block     string
+		Iteration *int
+		Role      string
+		Assistant string
+		Data      LLMRequest
+	}
+	if err := json.Unmarshal([]byte(lines[0]), &request); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if request.Type != EventLLMRequest || request.Block != "docs" || request.Iteration == nil || *request.Iteration != 0 ||
+		request.Role != "worker" || request.Assistant != "coder" || request.Data.Schema != "files" ||
+		request.Data.Messages[0].Content != "write code" {
+		t.Errorf("unexpected request event %s", lines[0])
+	}
+
+	var response struct {
+		Type EventType
+		Data LLMResponse
+	}
+	if err := json.Unmarshal([]byte(lines[1]), &response); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	u := response.Data.Usage
+	if response.Type != EventLLMResponse || response.Data.Response != `{"files": []}` || u.InputTokens != 1_000_000 ||
+		u.LatencyMs != 1500 || u.CostUSD == nil || *u.CostUSD != 2 {
+		t.Errorf("unexpected response event %s", lines[1])
+	}
+
+	if err := json.Unmarshal([]byte(lines[3]), &response); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if response.Data.Error != "rate limited" {
+		t.Errorf("expected the error in the response event, got %s", lines[3])
+	}
+}
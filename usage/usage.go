@@ -0,0 +1,211 @@
+package usage
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"maps"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+)
+
+const tokensPerPriceUnit = 1_000_000
+
+// Price is the cost of a model in USD per 1M tokens.
+type Price struct {
+	Input  float64 `yaml:"input" json:"input"`
+	Output float64 `yaml:"output" json:"output"`
+}
+
+// Pricing maps model names to prices. A model without an exact entry uses the longest
+// entry that is a prefix of its name, e.g. "claude-3-5-haiku" for "claude-3-5-haiku-latest".
+type Pricing map[string]Price
+
+// DefaultPricing returns list prices of popular models. They change over time, so they
+// can be overridden in the app setup.
+func DefaultPricing() Pricing {
+	return Pricing{
+		"gpt-4o":            {Input: 2.5, Output: 10},
+		"gpt-4o-mini":       {Input: 0.15, Output: 0.6},
+		"gpt-4.1":           {Input: 2, Output: 8},
+		"gpt-4.1-mini":      {Input: 0.4, Output: 1.6},
+		"gpt-4.1-nano":      {Input: 0.1, Output: 0.4},
+		"o3-mini":           {Input: 1.1, Output: 4.4},
+		"o4-mini":           {Input: 1.1, Output: 4.4},
+		"claude-3-5-haiku":  {Input: 0.8, Output: 4},
+		"claude-3-5-sonnet": {Input: 3, Output: 15},
+		"claude-3-7-sonnet": {Input: 3, Output: 15},
+		"claude-sonnet-4":   {Input: 3, Output: 15},
+		"claude-opus-4":     {Input: 15, Output: 75},
+	}
+}
+
+// Override returns a copy of p with the prices from override applied.
+func (p Pricing) Override(override Pricing) Pricing {
+	pricing := maps.Clone(p)
+	if pricing == nil {
+		pricing = Pricing{}
+	}
+	maps.Copy(pricing, override)
+	return pricing
+}
+
+func (p Pricing) price(model string) (Price, bool) {
+	if price, ok := p[model]; ok {
+		return price, true
+	}
+
+	bestMatch := ""
+	for m := range p {
+		if strings.HasPrefix(model, m) && len(m) > len(bestMatch) {
+			bestMatch = m
+		}
+	}
+	if bestMatch == "" {
+		return Price{}, false
+	}
+	return p[bestMatch], true
+}
+
+// Cost returns the estimated cost in USD, ok is false when the model has no price.
+func (p Pricing) Cost(model string, tokenUsage llm.TokenUsage) (cost float64, ok bool) {
+	price, ok := p.price(model)
+	if !ok {
+		return 0, false
+	}
+
+	return (float64(tokenUsage.InputTokens)*price.Input +
+		float64(tokenUsage.OutputTokens)*price.Output) / tokensPerPriceUnit, true
+}
+
+// Record is the usage of a single assistant in a single iteration of a block.
+type Record struct {
+	Block        string        `json:"block"`
+	Iteration    int           `json:"iteration"`
+	Role         string        `json:"role"`
+	Assistant    string        `json:"assistant"`
+	Model        string        `json:"model"`
+	Calls        int           `json:"calls"`
+	InputTokens  int           `json:"inputTokens"`
+	OutputTokens int           `json:"outputTokens"`
+	TotalTokens  int           `json:"totalTokens"`
+	Latency      time.Duration `json:"latencyNs"`
+	CostUSD      float64       `json:"costUSD"`
+	// Priced is false when the model is not in the pricing table, CostUSD is 0 then.
+	Priced bool `json:"priced"`
+}
+
+// NewRecord prices the usage of an assistant.
+func NewRecord(block string, iteration int, role, assistant string, u llm.Usage, pricing Pricing) Record {
+	cost, priced := pricing.Cost(u.Model, u.TokenUsage)
+	return Record{
+		Block:        block,
+		Iteration:    iteration,
+		Role:         role,
+		Assistant:    assistant,
+		Model:        u.Model,
+		Calls:        u.Calls,
+		InputTokens:  u.TokenUsage.InputTokens,
+		OutputTokens: u.TokenUsage.OutputTokens,
+		TotalTokens:  u.TokenUsage.TotalTokens,
+		Latency:      u.TimeTaken,
+		CostUSD:      cost,
+		Priced:       priced,
+	}
+}
+
+func (r Record) add(other Record) Record {
+	r.Calls += other.Calls
+	r.InputTokens += other.InputTokens
+	r.OutputTokens += other.OutputTokens
+	r.TotalTokens += other.TotalTokens
+	r.Latency += other.Latency
+	r.CostUSD += other.CostUSD
+	r.Priced = r.Priced && other.Priced
+	return r
+}
+
+// Report is the usage of a whole run.
+type Report struct {
+	Records []Record `json:"records"`
+	// ByRole sums up the records per block, role, assistant and model.
+	ByRole []Record `json:"byRole"`
+	Total  Record   `json:"total"`
+}
+
+func NewReport(records []Record) Report {
+	report := Report{
+		Records: records,
+		Total:   Record{Priced: true},
+	}
+
+	byRole := map[string]Record{}
+	var keys []string
+	for _, r := range records {
+		key := strings.Join([]string{r.Block, r.Role, r.Assistant, r.Model}, "\x00")
+		sum, ok := byRole[key]
+		if !ok {
+			keys = append(keys, key)
+			sum = Record{Block: r.Block, Role: r.Role, Assistant: r.Assistant, Model: r.Model, Priced: true}
+		}
+		byRole[key] = sum.add(r)
+		report.Total = report.Total.add(r)
+	}
+
+	sort.SliceStable(keys, func(i, j int) bool { return byRole[keys[i]].Block < byRole[keys[j]].Block })
+	for _, key := range keys {
+		sum := byRole[key]
+		sum.Iteration = -1
+		report.ByRole = append(report.ByRole, sum)
+	}
+	report.Total.Iteration = -1
+
+	return report
+}
+
+func (r Report) WriteJSON(fileName string) error {
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return err
+	}
+	return os.WriteFile(fileName, data, 0o644)
+}
+
+// WriteTable prints the usage per role as a table.
+func (r Report) WriteTable(w io.Writer) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "BLOCK\tROLE\tASSISTANT\tMODEL\tCALLS\tINPUT\tOUTPUT\tTOTAL\tLATENCY\tCOST USD")
+
+	total := r.Total
+	total.Block = "TOTAL"
+	for _, rec := range append(slices.Clone(r.ByRole), total) {
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%d\t%d\t%d\t%s\t%s\n",
+			rec.Block,
+			rec.Role,
+			rec.Assistant,
+			rec.Model,
+			rec.Calls,
+			rec.InputTokens,
+			rec.OutputTokens,
+			rec.TotalTokens,
+			rec.Latency.Round(time.Millisecond),
+			rec.FormatCost(),
+		)
+	}
+	return tw.Flush()
+}
+
+// FormatCost formats the cost in USD, prefixed with >= when it is a lower bound.
+func (r Record) FormatCost() string {
+	if !r.Priced {
+		// part of the usage could not be priced, so the cost is a lower bound
+		return fmt.Sprintf(">=%.4f", r.CostUSD)
+	}
+	return fmt.Sprintf("%.4f", r.CostUSD)
+}
@@ -0,0 +1,75 @@
+package usage
+
+import (
+	"math"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/llm"
+)
+
+func TestPricingCost(t *testing.T) {
+	pricing := Pricing{
+		"gpt-4o":           {Input: 2.5, Output: 10},
+		"gpt-4o-mini":      {Input: 0.15, Output: 0.6},
+		"claude-3-5-haiku": {Input: 0.8, Output: 4},
+	}
+	tokens := llm.TokenUsage{InputTokens: 1_000_000, OutputTokens: 500_000}
+
+	tests := map[string]float64{
+		"gpt-4o":                  7.5,
+		"gpt-4o-mini":             0.45,
+		"gpt-4o-2024-08-06":       7.5,
+		"claude-3-5-haiku-latest": 2.8,
+	}
+	for model, expected := range tests {
+		cost, ok := pricing.Cost(model, tokens)
+		if !ok || math.Abs(cost-expected) > 1e-9 {
+			t.Errorf("model %s: expected %f, got %f (%v)", model, expected, cost, ok)
+		}
+	}
+
+	if _, ok := pricing.Cost("llama3.2", tokens); ok {
+		t.Errorf("expected unknown model not to be priced")
+	}
+}
+
+func TestReport(t *testing.T) {
+	pricing := Pricing{"gpt-4o-mini": {Input: 1, Output: 2}}
+	u := llm.Usage{
+		Model:      "gpt-4o-mini",
+		Calls:      1,
+		TokenUsage: llm.TokenUsage{InputTokens: 100, OutputTokens: 10, TotalTokens: 110},
+		TimeTaken:  time.Second,
+	}
+	local := llm.Usage{Model: "llama3.2", Calls: 1, TokenUsage: llm.TokenUsage{TotalTokens: 5}}
+
+	report := NewReport([]Record{
+		NewRecord("docs", 0, "worker", "writer", u, pricing),
+		NewRecord("docs", 1, "worker", "writer", u, pricing),
+		NewRecord("docs", 0, "expert", "reviewer", local, pricing),
+	})
+
+	if len(report.ByRole) != 2 {
+		t.Fatalf("expected 2 role summaries, got %d", len(report.ByRole))
+	}
+	worker := report.ByRole[0]
+	if worker.Calls != 2 || worker.TotalTokens != 220 || worker.Latency != 2*time.Second || !worker.Priced {
+		t.Errorf("unexpected worker summary: %+v", worker)
+	}
+	if report.Total.TotalTokens != 225 || report.Total.Priced {
+		t.Errorf("unexpected total: %+v", report.Total)
+	}
+	if math.Abs(report.Total.CostUSD-0.00024) > 1e-12 {
+		t.Errorf("expected total cost 0.00024, got %f", report.Total.CostUSD)
+	}
+
+	var table strings.Builder
+	if err := report.WriteTable(&table); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !strings.Contains(table.String(), "TOTAL") || !strings.Contains(table.String(), ">=0.0002") {
+		t.Errorf("unexpected table:\n%s", table.String())
+	}
+}
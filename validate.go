@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"text/template"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/assistants"
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/executor"
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+	thinkingblock "github.com/aszmajdzinski/llm-feedback-loop-executor/thinking_block"
+	"gopkg.in/yaml.v3"
+)
+
+// validateCommand reports the problems of an app setup a run would fail on, without
+// creating any block or calling an API. It fails when there are any, e.g. to lint the
+// setups in CI.
+func validateCommand(_ context.Context, args []string) error {
+	fs := newFlagSet("validate")
+	appSetupFile := fs.String("config", "", "Path to the app setup file")
+	_ = fs.Parse(args)
+
+	appSetup, err := loadAppSetupFlag(fs, *appSetupFile)
+	if err != nil {
+		return err
+	}
+
+	problems := unknownFields(*appSetupFile)
+	problems = append(problems, validateAppSetup(appSetup)...)
+	for _, p := range problems {
+		fmt.Printf("%s: %v\n", *appSetupFile, p)
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s: %d problems found", *appSetupFile, len(problems))
+	}
+
+	fmt.Printf("%s: %d blocks, no problems found\n", *appSetupFile, len(appSetup.Blocks))
+	return nil
+}
+
+// unknownFields reports the fields of the app setup file that are not part of AppSetup,
+// they are ignored by a run and usually are typos.
+func unknownFields(appSetupFile string) []error {
+	data, err := os.ReadFile(appSetupFile)
+	if err != nil {
+		return []error{err}
+	}
+
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(&AppSetup{})
+
+	var typeErr *yaml.TypeError
+	if !errors.As(err, &typeErr) {
+		// other errors are reported when the app setup is loaded
+		return nil
+	}
+	problems := make([]error, 0, len(typeErr.Errors))
+	for _, e := range typeErr.Errors {
+		// the Go type the field is missing in says nothing about the YAML
+		e, _, _ = strings.Cut(e, " in type ")
+		problems = append(problems, errors.New(e))
+	}
+	return problems
+}
+
+// validateAppSetup returns all problems of the app setup found without running it.
+func validateAppSetup(appSetup AppSetup) []error {
+	var problems []error
+	if len(appSetup.Blocks) == 0 {
+		problems = append(problems, errors.New("no blocks"))
+	}
+
+	if _, err := newProviderRegistry(appSetup.Providers); err != nil {
+		problems = append(problems, err)
+	}
+	if err := appSetup.Templates.Validate(); err != nil {
+		problems = append(problems, fmt.Errorf("templates: %w", err))
+	}
+
+	deps, err := blockDependencies(appSetup.Blocks)
+	if err != nil {
+		problems = append(problems, err)
+	}
+
+	providers := providerSetups(appSetup.Providers)
+	verify := false
+	for bn, b := range appSetup.Blocks {
+		if b.Name == "" {
+			problems = append(problems, fmt.Errorf("block %d has no name", bn+1))
+			continue
+		}
+
+		var inputs []int
+		if deps != nil {
+			inputs = deps[bn]
+		}
+		for _, err := range validateBlock(appSetup, b, inputs, providers) {
+			problems = append(problems, fmt.Errorf("block %s: %w", b.Name, err))
+		}
+		verify = verify || len(b.Verify.Commands) > 0
+	}
+
+	if verify {
+		if _, err := executor.New(appSetup.Executor, executor.Limits{}); err != nil {
+			problems = append(problems, fmt.Errorf("executor: %w", err))
+		}
+	}
+
+	return problems
+}
+
+func validateBlock(appSetup AppSetup, b Block, inputs []int, providers map[string]ProviderSetup) []error {
+	var problems []error
+	if b.Iterations < 1 {
+		problems = append(problems, errors.New("iterations must be at least 1"))
+	}
+	if b.AcceptScore < 0 || b.AcceptScore > 10 {
+		problems = append(problems, errors.New("acceptScore must be between 0 and 10"))
+	}
+	if b.Worker.Prompt == "" {
+		problems = append(problems, errors.New("worker has no prompt"))
+	}
+
+	switch b.Refinement {
+	case "", thinkingblock.RefineFull:
+	case thinkingblock.RefineEdits:
+		if !b.FilesOutput {
+			problems = append(problems, fmt.Errorf("%s refinement needs filesOutput", thinkingblock.RefineEdits))
+		}
+	default:
+		problems = append(problems, fmt.Errorf("unknown refinement %q", b.Refinement))
+	}
+
+	if b.Target.Dir != "" && !b.FilesOutput {
+		problems = append(problems, errors.New("target needs filesOutput"))
+	}
+	switch b.Target.Policy {
+	case "", fileutils.PolicyFailIfExists, fileutils.PolicyOverwrite, fileutils.PolicyBackup, fileutils.PolicyMergeNewOnly:
+	default:
+		problems = append(problems, fmt.Errorf("unknown target policy %q", b.Target.Policy))
+	}
+	if b.Git != nil {
+		if err := checkBlockGit(b); err != nil {
+			problems = append(problems, err)
+		}
+	}
+	if len(b.Verify.Commands) > 0 && !b.FilesOutput {
+		problems = append(problems, errors.New("verify commands need filesOutput"))
+	}
+
+	if err := appSetup.Templates.Override(b.Templates).Validate(); err != nil {
+		problems = append(problems, fmt.Errorf("templates: %w", err))
+	}
+	if b.DataTemplate != "" {
+		if _, err := template.New("data").Parse(b.DataTemplate); err != nil {
+			problems = append(problems, fmt.Errorf("cannot parse data template: %w", err))
+		}
+	}
+	if root := b.Inputs.Files.Root; root != "" {
+		if info, err := os.Stat(root); err != nil || !info.IsDir() {
+			problems = append(problems, fmt.Errorf("input files root %s is not a directory", root))
+		}
+	}
+
+	// tools read the input directory, a run finds it the same way
+	hasInputDirectory := b.InputDirectory != "" || b.Inputs.Files.Root != ""
+	filesInputs := 0
+	for _, d := range inputs {
+		if appSetup.Blocks[d].FilesOutput {
+			filesInputs++
+		}
+	}
+	hasInputDirectory = hasInputDirectory || filesInputs == 1
+
+	for _, r := range b.roles() {
+		for _, err := range validateRole(b, r.Role, providers, hasInputDirectory) {
+			problems = append(problems, fmt.Errorf("%s: %w", strings.TrimSpace(r.kind+" "+r.Name), err))
+		}
+	}
+
+	return problems
+}
+
+func validateRole(b Block, role Role, providers map[string]ProviderSetup, hasInputDirectory bool) []error {
+	var problems []error
+
+	providerName := b.roleProvider(role)
+	setup, ok := providers[providerName]
+	if !ok {
+		problems = append(problems, fmt.Errorf("unknown provider %s", providerName))
+	} else if len(role.Tools) > 0 && !setup.supportsTools() {
+		problems = append(problems, setup.toolsError(providerName))
+	}
+
+	if len(role.Tools) > 0 && !hasInputDirectory {
+		problems = append(problems, errors.New("tools need an inputDirectory, input files or a single input block with filesOutput"))
+	}
+	known := map[string]bool{}
+	for _, t := range assistants.FileTools("") {
+		known[t.Name] = true
+	}
+	for _, name := range role.Tools {
+		if !known[name] {
+			problems = append(problems, fmt.Errorf("unknown tool %s", name))
+		}
+	}
+
+	return problems
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestValidateAppSetup(t *testing.T) {
+	var valid AppSetup
+	valid.Blocks = []Block{{Name: "design", Iterations: 2, FilesOutput: true}}
+	valid.Blocks[0].Worker.Prompt = "Design an app"
+	valid.Blocks[0].Worker.Tools = []string{"read_file"}
+	valid.Blocks[0].InputDirectory = "src"
+
+	if problems := validateAppSetup(valid); len(problems) != 0 {
+		t.Fatalf("expected no problems, got %v", problems)
+	}
+
+	var invalid AppSetup
+	invalid.Blocks = []Block{
+		{Name: "design", Iterations: 0, Refinement: "edits", Target: BlockTarget{Dir: "out", Policy: "replace"}},
+		{Name: "docs", Iterations: 1, Inputs: BlockInputs{Blocks: []string{"code"}}},
+	}
+	invalid.Blocks[0].Worker.Prompt = "Design an app"
+	invalid.Blocks[0].Worker.Tools = []string{"grep"}
+	invalid.Blocks[0].Oracle.Provider = "ollama"
+	invalid.Blocks[1].Worker.Prompt = "Document the app"
+	invalid.Blocks[1].DataTemplate = "{{.Inputs"
+	invalid.Blocks[1].InputDirectory = "src"
+	invalid.Blocks[1].Worker.Provider = "local"
+	invalid.Blocks[1].Worker.Tools = []string{"read_file"}
+	invalid.Providers = map[string]ProviderSetup{"local": {Type: "ollama", Model: "llama3"}}
+
+	expected := []string{
+		"block docs: unknown input block code",
+		"block design: iterations must be at least 1",
+		"block design: edits refinement needs filesOutput",
+		"block design: target needs filesOutput",
+		`block design: unknown target policy "replace"`,
+		"block design: worker: tools need an inputDirectory, input files or a single input block with filesOutput",
+		"block design: worker: unknown tool grep",
+		"block design: oracle: unknown provider ollama",
+		"block docs: cannot parse data template: template: data:1: unclosed action",
+		"block docs: worker: tools are not supported by provider local of type ollama",
+	}
+	problems := validateAppSetup(invalid)
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), problems)
+	}
+	for i, p := range problems {
+		if p.Error() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], p.Error())
+		}
+	}
+}
+
+func TestUnknownFields(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "setup.yaml")
+	setup := `
+blocks:
+  - name: design
+    iteration: 2
+    worker:
+      prompt: Design an app
+      experts: []
+`
+	if err := os.WriteFile(file, []byte(setup), 0o644); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	problems := unknownFields(file)
+	expected := []string{"line 4: field iteration not found", "line 7: field experts not found"}
+	if len(problems) != len(expected) {
+		t.Fatalf("expected %d problems, got %v", len(expected), problems)
+	}
+	for i, p := range problems {
+		if p.Error() != expected[i] {
+			t.Errorf("expected %q, got %q", expected[i], p.Error())
+		}
+	}
+}
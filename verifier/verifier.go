@@ -0,0 +1,111 @@
+package verifier
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/executor"
+	fileutils "github.com/aszmajdzinski/llm-feedback-loop-executor/file_utils"
+	loggerutils "github.com/aszmajdzinski/llm-feedback-loop-executor/logger_utils"
+)
+
+// Setup is the `verify:` section of a block.
+type Setup struct {
+	// Commands run with `sh -c` in the directory of the generated files, in order. Every
+	// command starts from a fresh copy of the files.
+	Commands []string `yaml:"commands"`
+	// Limits of every command.
+	executor.Limits `yaml:",inline"`
+}
+
+// Verifier checks the files of a worker solution by running real commands against them.
+type Verifier struct {
+	commands []string
+	executor *executor.Executor
+}
+
+// New returns a verifier running the commands with an executor isolated as set up.
+func New(setup Setup, executorSetup executor.Setup) (*Verifier, error) {
+	e, err := executor.New(executorSetup, setup.Limits)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Verifier{commands: setup.Commands, executor: e}, nil
+}
+
+// Report holds the results of the commands run so far. Commands stop at the first
+// failure, later ones would most likely fail for the same reason. Error is set when the
+// files of the solution could not be read.
+type Report struct {
+	Results []executor.Result `json:"results"`
+	Error   string            `json:"error,omitempty"`
+}
+
+func (r Report) Passed() bool {
+	if r.Error != "" {
+		return false
+	}
+	for _, res := range r.Results {
+		if !res.Passed() {
+			return false
+		}
+	}
+	return true
+}
+
+// String formats the report for prompts.
+func (r Report) String() string {
+	var builder strings.Builder
+	if r.Error != "" {
+		fmt.Fprintf(&builder, "FAILED: %s\n", r.Error)
+	}
+	for _, res := range r.Results {
+		status := "PASSED"
+		switch {
+		case res.TimedOut:
+			status = "TIMED OUT"
+		case res.ExitCode != 0:
+			status = "FAILED"
+		}
+		fmt.Fprintf(&builder, "$ %s\n%s (exit code %d, %s, isolation %s)\n",
+			res.Command, status, res.ExitCode, res.Duration.Round(time.Millisecond), res.Isolation)
+		if res.Stdout != "" {
+			fmt.Fprintf(&builder, "STDOUT:\n%s\n", res.Stdout)
+		}
+		if res.Stderr != "" {
+			fmt.Fprintf(&builder, "STDERR:\n%s\n", res.Stderr)
+		}
+	}
+	return builder.String()
+}
+
+// Verify runs the commands over the files of the solution (a fileutils.FileList JSON).
+// The returned error is set only when the verification could not be run at all;
+// failures of the solution are in the report.
+func (v *Verifier) Verify(ctx context.Context, solution string) (Report, error) {
+	logger := loggerutils.GetLogger(ctx)
+
+	var files fileutils.FileList
+	if err := json.Unmarshal([]byte(solution), &files); err != nil {
+		return Report{Error: fmt.Sprintf("cannot read the files of the solution: %s", err)}, nil
+	}
+
+	var report Report
+	for _, command := range v.commands {
+		res, err := v.executor.Run(ctx, files, command)
+		if err != nil {
+			return report, err
+		}
+		logger.Info("Verifier: command finished", "command", command, "exitCode", res.ExitCode, "timedOut", res.TimedOut)
+
+		report.Results = append(report.Results, res)
+		if !res.Passed() {
+			break
+		}
+	}
+	return report, nil
+}
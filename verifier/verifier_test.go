@@ -0,0 +1,80 @@
+package verifier
+
+import (
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/aszmajdzinski/llm-feedback-loop-executor/executor"
+)
+
+// newTestVerifier runs the commands without a sandbox, which is not available everywhere.
+func newTestVerifier(t *testing.T, setup Setup) *Verifier {
+	v, err := New(setup, executor.Setup{Isolation: executor.IsolationNone})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return v
+}
+
+const solution = `{"files": [{"fileName": "pkg/hello.txt", "fileContent": "hello"}]}`
+
+func TestVerify(t *testing.T) {
+	v := newTestVerifier(t, Setup{Commands: []string{
+		"cat pkg/hello.txt",
+		"echo broken >&2; exit 3",
+		"echo never run",
+	}})
+
+	report, err := v.Verify(context.Background(), solution)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if report.Passed() {
+		t.Errorf("expected failed verification")
+	}
+	if len(report.Results) != 2 {
+		t.Fatalf("expected to stop after the failed command, got %d results", len(report.Results))
+	}
+	if r := report.Results[0]; !r.Passed() || r.Stdout != "hello" {
+		t.Errorf("unexpected first result %+v", r)
+	}
+	if r := report.Results[1]; r.ExitCode != 3 || r.Stderr != "broken\n" {
+		t.Errorf("unexpected second result %+v", r)
+	}
+	if !strings.Contains(report.String(), "FAILED (exit code 3") {
+		t.Errorf("unexpected report:\n%s", report)
+	}
+}
+
+func TestVerifyTimeout(t *testing.T) {
+	v := newTestVerifier(t, Setup{
+		Commands: []string{"sleep 10"},
+		Limits:   executor.Limits{Timeout: 100 * time.Millisecond},
+	})
+
+	start := time.Now()
+	report, err := v.Verify(context.Background(), solution)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	if len(report.Results) != 1 || !report.Results[0].TimedOut {
+		t.Errorf("expected timed out command, got %+v", report.Results)
+	}
+	if time.Since(start) > 5*time.Second {
+		t.Errorf("command was not killed after the timeout")
+	}
+}
+
+func TestVerifyInvalidSolution(t *testing.T) {
+	report, err := newTestVerifier(t, Setup{Commands: []string{"true"}}).Verify(context.Background(), "not json")
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if report.Passed() || report.Error == "" {
+		t.Errorf("expected failed verification, got %+v", report)
+	}
+}